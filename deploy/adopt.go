@@ -0,0 +1,138 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Records a function that was deployed outside the builder as the folder's signed deployment package,
+// so the next run reports the folder as up to date instead of redeploying it.
+//
+//	builder adopt \
+//	    -function=my-live-fn \
+//	    -folder=orders \
+//	    -bucket=kesav-go-lambda-builder-test \
+//	    -signed-prefix=test/signed
+//
+// Assumes the function is running the folder's current source code.
+func adopt() error {
+	folder := *folderFlag
+	if folder == "" {
+		return errors.New(`flag "folder" is required`)
+	}
+	function := orDefault(*functionFlag, functionName(folder))
+	signedBucket := orDefault(*signedBucketFlag, *bucketFlag)
+	if signedBucket == "" {
+		return errors.New(`flag "signed-bucket" or "bucket" is required`)
+	}
+	if *signedPrefixFlag == "" {
+		return errors.New(`flag "signed-prefix" is required`)
+	}
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return err
+	}
+	// hashing the source code lists its dependencies with go list
+	env, err := goBuildEnv()
+	if err != nil {
+		return err
+	}
+	d := &data{
+		configs:        folderConfigs,
+		functionPrefix: *functionPrefixFlag,
+		functionSuffix: *functionSuffixFlag,
+		ctx:            context.TODO(),
+		env:            env,
+		metrics:        runMetrics,
+		s3:             newS3Client(cfg),
+		signedBucket:   regionalBucket(signedBucket, cfg.Region),
+		signedPrefix:   *signedPrefixFlag,
+		lambda:         lambda.NewFromConfig(cfg),
+		alias:          *aliasFlag,
+		tags:           defaultTags,
+	}
+	d.uploader = newUploader(d.s3, *uploadPartSizeFlag, *uploadConcurrencyFlag)
+	return d.adopt(folder, function)
+}
+
+func (d *data) adopt(folder, function string) error {
+	signedKey := objectKey(d.signedPrefix, folder+".zip")
+	unsignedHash, err := d.hashSourceCode(folder)
+	if err != nil {
+		return err
+	}
+	d.logf(folder, "adopt", "Getting code of Lambda function %s.", function)
+	output, err := d.lambda.GetFunction(d.ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(function),
+	})
+	if err != nil {
+		d.failf(folder, "adopt", err, "Failed to get Lambda function: %s", err.Error())
+		return err
+	}
+	arch := "amd64"
+	for goarch, architecture := range architectures {
+		if len(output.Configuration.Architectures) != 0 && output.Configuration.Architectures[0] == architecture {
+			arch = goarch
+		}
+	}
+	codeSha256 := aws.ToString(output.Configuration.CodeSha256)
+	d.logf(folder, "adopt", "Lambda function is running %s for %s.", codeSha256, arch)
+	alias, err := d.lambda.GetAlias(d.ctx, &lambda.GetAliasInput{
+		FunctionName: aws.String(function),
+		Name:         aws.String(d.alias),
+	})
+	if err != nil {
+		d.logf(folder, "adopt", "Failed to get alias %s, continuing: %s", d.alias, err.Error())
+	} else {
+		d.logf(folder, "adopt", "Alias %s points at version %s.", d.alias, aws.ToString(alias.FunctionVersion))
+	}
+	// the upload needs a seekable body to sign the payload
+	code, err := downloadCode(output.Code)
+	if err != nil {
+		d.failf(folder, "adopt", err, "Failed to download code: %s", err.Error())
+		return err
+	}
+	d.logf(folder, "adopt", "Uploading code to s3://%s/%s.", d.signedBucket, signedKey)
+	_, err = d.uploader.Upload(d.ctx, &s3.PutObjectInput{
+		Bucket: aws.String(d.signedBucket),
+		Key:    aws.String(signedKey),
+		Body:   bytes.NewReader(code),
+		Metadata: map[string]string{
+			"unsignedHash":     unsignedHash,
+			"signedHash":       codeSha256,
+			"source-code-hash": codeSha256,
+			"arch":             arch,
+			"adopted-from":     function,
+		},
+		Tagging: objectTagging(d.tags),
+	})
+	if err != nil {
+		d.failf(folder, "adopt", err, "Failed to upload code: %s", err.Error())
+		return err
+	}
+	d.donef(folder, "adopt", "Adopted Lambda function %s.", function)
+	return nil
+}
+
+// Downloads the deployment package of a function, as returned by GetFunction.
+// The presigned URL is only valid for a few minutes.
+func downloadCode(code *lambdaTypes.FunctionCodeLocation) ([]byte, error) {
+	res, err := http.Get(aws.ToString(code.Location))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading code responded with %s", res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
@@ -0,0 +1,183 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Returned when the alias was updated but did not settle on the new version.
+var errPostCondition = errors.New("post-condition failed")
+
+// How often to check the alias while waiting for it to settle.
+const verifyAliasDelay = 2 * time.Second
+
+// Lambda rejects alias descriptions longer than this.
+const maxAliasDescription = 256
+
+// Separates the entries of the rolling history in an alias description.
+const aliasHistorySeparator = " | "
+
+// Returns the alias to point at the new version of the folder's Lambda function.
+func (d *data) aliasFor(folder string) string {
+	if alias, ok := d.aliasOverrides[folder]; ok {
+		return alias
+	}
+	return d.alias
+}
+
+// Returns the description to stamp on an alias when pointing it at version.
+// Keeps up to history entries of the previous description, newest first, dropping the oldest to fit.
+//
+//	v42 at 2022-12-06T00:00:00Z from 0fd104e run 123 | v41 at 2022-12-05T00:00:00Z from 6428e50 run 122
+func (d *data) aliasDescription(previous, version string, now time.Time) string {
+	sha := d.gitSHA
+	if len(sha) > 7 {
+		sha = sha[:7]
+	}
+	entries := []string{fmt.Sprintf("v%s at %s from %s run %s", version, now.UTC().Format(time.RFC3339), sha, d.runID)}
+	if previous != "" && d.aliasHistory > 0 {
+		old := strings.Split(previous, aliasHistorySeparator)
+		if len(old) > d.aliasHistory {
+			old = old[:d.aliasHistory]
+		}
+		entries = append(entries, old...)
+	}
+	description := strings.Join(entries, aliasHistorySeparator)
+	for len(description) > maxAliasDescription && len(entries) > 1 {
+		entries = entries[:len(entries)-1]
+		description = strings.Join(entries, aliasHistorySeparator)
+	}
+	if len(description) > maxAliasDescription {
+		description = description[:maxAliasDescription]
+	}
+	return description
+}
+
+// Lambda rejects version descriptions longer than this.
+const maxVersionDescription = 256
+
+// What -version-description templates can use.
+type versionDescriptionData struct {
+	GitSHA      string
+	ShortSHA    string
+	RunID       string
+	User        string
+	Time        string
+	Folder      string
+	Function    string
+	Hash        string
+	Environment string
+}
+
+// Returns the user deploying, the GitHub actor in GitHub Actions.
+func deployingUser() string {
+	if actor := os.Getenv("GITHUB_ACTOR"); actor != "" {
+		return actor
+	}
+	u, err := user.Current()
+	if err != nil {
+		return "unknown"
+	}
+	return u.Username
+}
+
+// Returns the description to publish the new version of the folder's function with, from -version-description.
+//
+//	-version-description='{{.ShortSHA}} by {{.User}} at {{.Time}}'
+func (d *data) versionDescription(folder, hash string, now time.Time) (string, error) {
+	if d.versionDescriptionTemplate == nil {
+		return "", nil
+	}
+	sha := d.gitSHA
+	if len(sha) > 7 {
+		sha = sha[:7]
+	}
+	b := &strings.Builder{}
+	err := d.versionDescriptionTemplate.Execute(b, versionDescriptionData{
+		GitSHA:      d.gitSHA,
+		ShortSHA:    sha,
+		RunID:       d.runID,
+		User:        d.user,
+		Time:        now.UTC().Format(time.RFC3339),
+		Folder:      folder,
+		Function:    d.functionName(folder),
+		Hash:        hash,
+		Environment: d.environment,
+	})
+	if err != nil {
+		return "", err
+	}
+	description := b.String()
+	if len(description) > maxVersionDescription {
+		description = description[:maxVersionDescription]
+	}
+	return description, nil
+}
+
+// Waits until the alias points only at version and its provisioned concurrency is ready.
+// Returns an error wrapping errPostCondition if it does not settle within the timeout.
+func (d *data) verifyAlias(folder, alias, version string) error {
+	d.logf(folder, "verify", "Verifying alias %s points at version %s.", alias, version)
+	deadline := time.Now().Add(d.verifyAliasTimeout)
+	for {
+		reason, err := d.checkAlias(folder, alias, version)
+		if err != nil {
+			d.failf(folder, "verify", err, "Failed to verify alias %s: %s", alias, err.Error())
+			return err
+		}
+		if reason == "" {
+			d.donef(folder, "verify", "Verified alias %s points at version %s.", alias, version)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			err := fmt.Errorf("%w: alias %s: %s", errPostCondition, alias, reason)
+			d.failf(folder, "verify", err, "Post-condition failed for alias %s: %s.", alias, reason)
+			return err
+		}
+		d.logf(folder, "verify", "Alias %s has not settled, retrying: %s.", alias, reason)
+		time.Sleep(verifyAliasDelay)
+	}
+}
+
+// Returns why the alias has not settled on version, or an empty string if it has.
+func (d *data) checkAlias(folder, alias, version string) (string, error) {
+	output, err := d.lambda.GetAlias(d.ctx, &lambda.GetAliasInput{
+		FunctionName: aws.String(d.functionName(folder)),
+		Name:         aws.String(alias),
+	})
+	if err != nil {
+		return "", err
+	}
+	if actual := aws.ToString(output.FunctionVersion); actual != version {
+		return fmt.Sprintf("points at version %s", actual), nil
+	}
+	if output.RoutingConfig != nil && len(output.RoutingConfig.AdditionalVersionWeights) != 0 {
+		return "still routes traffic to other versions", nil
+	}
+	pc, err := d.lambda.GetProvisionedConcurrencyConfig(d.ctx, &lambda.GetProvisionedConcurrencyConfigInput{
+		FunctionName: aws.String(d.functionName(folder)),
+		Qualifier:    aws.String(alias),
+	})
+	var notFound *lambdaTypes.ProvisionedConcurrencyConfigNotFoundException
+	if errors.As(err, &notFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	switch pc.Status {
+	case lambdaTypes.ProvisionedConcurrencyStatusEnumReady:
+		return "", nil
+	case lambdaTypes.ProvisionedConcurrencyStatusEnumFailed:
+		return "", fmt.Errorf("%w: provisioned concurrency failed: %s", errPostCondition, aws.ToString(pc.StatusReason))
+	}
+	return fmt.Sprintf("provisioned concurrency is %s", pc.Status), nil
+}
@@ -0,0 +1,237 @@
+// Package deploy builds, signs, and deploys folders of Go Lambda functions.
+// It is the builder command, see Main, and can deploy a folder from other Go programs with DeployFolder.
+package deploy
+
+import (
+	"compress/flate"
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/signer"
+)
+
+// Options of DeployFolder, named after the flags of the builder they stand for.
+// Options left empty default like their flags do, and flags without an option keep their defaults.
+type Options struct {
+	// the folder to deploy, relative to the working directory
+	Folder string
+	// -bucket, or the bucket of each step with -unsigned-bucket, -staging-bucket, and -signed-bucket
+	Bucket         string
+	UnsignedBucket string
+	StagingBucket  string
+	SignedBucket   string
+	UnsignedPrefix string
+	StagingPrefix  string
+	SignedPrefix   string
+	// -signing-profile, empty to deploy unsigned deployment packages
+	SigningProfile string
+	// -alias, TEST if empty, and -create-alias
+	Alias       string
+	CreateAlias bool
+	// -arch, amd64 or arm64, amd64 if empty
+	Arch     string
+	Force    bool
+	ReadOnly bool
+	// the AWS config to deploy with, nil to load the default config of the environment
+	AWSConfig *aws.Config
+	// called with every line of output of the deploy, as it is printed
+	OnStatus func(Status)
+}
+
+// A line of output of DeployFolder, as its step starts, makes progress, and ends.
+type Status struct {
+	Time   time.Time
+	Folder string
+	Step   string
+	// running until the step is done, failed, or skipped
+	Status  string
+	Message string
+	// why the step failed, with secrets scrubbed
+	Error string
+	// how long the step has been running
+	Duration time.Duration
+}
+
+// What DeployFolder did to the folder.
+type Result struct {
+	// deployed, up to date, or planned in read-only mode
+	Status string
+	// the version published, empty if the function was up to date
+	Version         string
+	Alias           string
+	PreviousVersion string
+	UnsignedHash    string
+	SignedHash      string
+}
+
+// Deploys the folder the way the builder does with -include, e.g. for a deploy bot:
+//
+//	result, err := deploy.DeployFolder(ctx, deploy.Options{
+//	    Folder:         "testLambda01",
+//	    Bucket:         "kesav-go-lambda-builder-test",
+//	    UnsignedPrefix: "test/unsigned",
+//	    StagingPrefix:  "test/staging",
+//	    SignedPrefix:   "test/signed",
+//	    SigningProfile: "main",
+//	    Alias:          "LIVE",
+//	    OnStatus: func(s deploy.Status) {
+//	        log.Printf("%s %s: %s", s.Step, s.Status, s.Message)
+//	    },
+//	})
+//
+// Nothing is printed, every line of output goes to OnStatus. The config file is not read,
+// so the folder has no role, unit, or other setting of its folder block.
+func DeployFolder(ctx context.Context, opts Options) (Result, error) {
+	var cfg aws.Config
+	if opts.AWSConfig != nil {
+		cfg = *opts.AWSConfig
+	} else {
+		var err error
+		cfg, err = config.LoadDefaultConfig(ctx, config.WithCredentialsCacheOptions(refreshEarly))
+		if err != nil {
+			return Result{}, configError{err}
+		}
+		retryAWSCalls(&cfg, defaultMaxAttempts, defaultMaxBackoff)
+	}
+	d, err := newFolderRun(ctx, opts, cfg.Region, s3.NewFromConfig(cfg), signer.NewFromConfig(cfg), lambda.NewFromConfig(cfg))
+	if err != nil {
+		return Result{}, err
+	}
+	err = d.run(opts.Folder)
+	return d.result(opts.Folder), err
+}
+
+// Returns a run that deploys the folder of the options with the clients.
+func newFolderRun(ctx context.Context, opts Options, region string, s3Client s3API, signerClient signerAPI, lambdaClient lambdaAPI) (*data, error) {
+	if opts.Folder == "" {
+		return nil, configErrorf("no folder to deploy")
+	}
+	// without the config file, a folder has no artifact or matrix to deploy instead
+	if !hasGoFiles(opts.Folder) {
+		return nil, configErrorf(`"%s" is not a Lambda folder: it has no Go files`, opts.Folder)
+	}
+	signedBucket := orDefault(opts.SignedBucket, opts.Bucket)
+	if signedBucket == "" || opts.SignedPrefix == "" {
+		return nil, configErrorf("a signed bucket and prefix are required")
+	}
+	unsignedBucket := orDefault(opts.UnsignedBucket, opts.Bucket)
+	stagingBucket := orDefault(opts.StagingBucket, opts.Bucket)
+	if opts.SigningProfile != "" && (unsignedBucket == "" || opts.UnsignedPrefix == "" || stagingBucket == "" || opts.StagingPrefix == "") {
+		return nil, configErrorf("signing requires unsigned and staging buckets and prefixes")
+	}
+	arch := orDefault(opts.Arch, defaultArch)
+	err := validateArch(arch)
+	if err != nil {
+		return nil, configError{err}
+	}
+	// every host environment variable, as without -env-allow and -env-deny
+	env, err := buildEnv(os.Environ(), nil, nil, nil)
+	if err != nil {
+		return nil, configError{err}
+	}
+	toolchain, err := installedGo(env)
+	if err != nil {
+		return nil, configError{err}
+	}
+	return &data{
+		ctx:       ctx,
+		runID:     newRunID(),
+		metrics:   newMetrics(),
+		report:    newRunReport(),
+		units:     newReleaseUnits(),
+		lock:      lockFile{},
+		lockMu:    &sync.Mutex{},
+		tags:      map[string]string{},
+		folders:   []string{opts.Folder},
+		configs:   map[string]folderConfig{},
+		region:    region,
+		force:     opts.Force,
+		readOnly:  opts.ReadOnly,
+		onStatus:  opts.OnStatus,
+		quiet:     true,
+		env:       env,
+		arch:      arch,
+		builds:    newSemaphore(1),
+		handler:   autoHandler,
+		zip:       zipCompression{level: flate.DefaultCompression},
+		artifacts: map[string]string{},
+		// s3 config
+		s3:                s3Client,
+		uploader:          newUploader(s3Client, defaultUploadPartSize, manager.DefaultUploadConcurrency),
+		uploadPartSize:    defaultUploadPartSize,
+		uploadConcurrency: manager.DefaultUploadConcurrency,
+		unsignedBucket:    regionalBucket(unsignedBucket, region),
+		stagingBucket:     regionalBucket(stagingBucket, region),
+		signedBucket:      regionalBucket(signedBucket, region),
+		unsignedPrefix:    opts.UnsignedPrefix,
+		stagingPrefix:     opts.StagingPrefix,
+		signedPrefix:      opts.SignedPrefix,
+		// signer config
+		signer:             signerClient,
+		signingProfile:     opts.SigningProfile,
+		signingJobWaiter:   newSigningJobWaiter(signerClient, defaultWaiterMinDelay, defaultWaiterMaxDelay),
+		signingTimeout:     defaultSigningTimeout,
+		verifySigned:       defaultVerifySigned,
+		codeSigningPolicy:  lambdaTypes.CodeSigningPolicyEnforce,
+		codeSigningConfigs: newCodeSigningConfigs(),
+		waiterMinDelay:     defaultWaiterMinDelay,
+		waiterMaxDelay:     defaultWaiterMaxDelay,
+		// lambda config
+		lambda:                        lambdaClient,
+		functionUpdatedWaiter:         newFunctionUpdatedWaiter(lambdaClient, defaultWaiterMinDelay, defaultWaiterMaxDelay),
+		updateTimeout:                 defaultUpdateTimeout,
+		publishedVersionWaiter:        newPublishedVersionWaiter(lambdaClient),
+		publishTimeout:                defaultPublishTimeout,
+		alias:                         orDefault(opts.Alias, defaultAlias),
+		createAlias:                   opts.CreateAlias,
+		user:                          deployingUser(),
+		goToolchain:                   toolchain,
+		verifyAliasTimeout:            defaultVerifyAliasTimeout,
+		provisionedConcurrencyTimeout: defaultProvisionedConcurrencyTimeout,
+		canaryBake:                    defaultCanaryBake,
+		previewURLs:                   map[string]string{},
+		previewURLsMu:                 &sync.Mutex{},
+		plan:                          &planTotals{},
+		s3PricePerGB:                  defaultS3PricePerGB,
+	}, nil
+}
+
+// Returns what the run did to the folder.
+func (d *data) result(folder string) Result {
+	result := Result{}
+	d.report.update(folder, d.targetRegion, func(r *folderReport) {
+		result = Result{
+			Status:          r.Status,
+			Version:         r.Version,
+			Alias:           r.Alias,
+			PreviousVersion: r.PreviousVersion,
+			UnsignedHash:    r.UnsignedHash,
+			SignedHash:      r.SignedHash,
+		}
+	})
+	return result
+}
+
+// Passes the line of output to the OnStatus callback of DeployFolder, if there is one.
+func (d *data) sendStatus(e event) {
+	if d.onStatus == nil {
+		return
+	}
+	d.onStatus(Status{
+		Time:     e.Time,
+		Folder:   e.Folder,
+		Step:     e.Step,
+		Status:   e.Status,
+		Message:  e.Message,
+		Error:    e.Error,
+		Duration: time.Duration(e.Duration * float64(time.Second)),
+	})
+}
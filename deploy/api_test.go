@@ -0,0 +1,70 @@
+package deploy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"testing"
+
+	"builder/testutil"
+)
+
+func TestNewFolderRunDefaultsAlias(t *testing.T) {
+	chdirLambdas(t)
+	fakeS3 := testutil.NewFakeS3("us-east-1")
+	fakeSigner := testutil.NewFakeSigner(fakeS3)
+	fakeLambda := testutil.NewFakeLambda(fakeS3, "us-east-1")
+	d, err := newFolderRun(context.Background(), Options{
+		Folder:       "testLambda01",
+		Bucket:       "bucket",
+		SignedPrefix: "test/signed",
+	}, "us-east-1", fakeS3, fakeSigner, fakeLambda)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.alias != defaultAlias {
+		t.Errorf("alias %q, want %q", d.alias, defaultAlias)
+	}
+}
+
+func TestRunNamesExecutableAfterRuntime(t *testing.T) {
+	chdirLambdas(t)
+	tests := []struct {
+		runtime string
+		want    string
+	}{
+		{runtime: "", want: "bootstrap"},
+		{runtime: "provided.al2", want: "bootstrap"},
+		{runtime: "go1.x", want: "main"},
+	}
+	for _, tt := range tests {
+		t.Run(orDefault(tt.runtime, "default"), func(t *testing.T) {
+			fakeS3 := testutil.NewFakeS3("us-east-1")
+			fakeSigner := testutil.NewFakeSigner(fakeS3)
+			fakeLambda := testutil.NewFakeLambda(fakeS3, "us-east-1")
+			d := newFakeRun(t, fakeS3, fakeSigner, fakeLambda)
+			c := d.configs["testLambda01"]
+			c.Runtime = tt.runtime
+			d.configs["testLambda01"] = c
+			err := d.run("testLambda01")
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkg, ok := fakeS3.Body("bucket", "test/signed/testLambda01.zip")
+			if !ok {
+				t.Fatal("no signed deployment package")
+			}
+			r, err := zip.NewReader(bytes.NewReader(pkg), int64(len(pkg)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, f := range r.File {
+				names = append(names, f.Name)
+			}
+			if len(names) != 1 || names[0] != tt.want {
+				t.Errorf("zip entries %v, want [%s]", names, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package deploy
+
+import (
+	"fmt"
+
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Maps GOARCH values to Lambda architectures.
+var architectures = map[string]lambdaTypes.Architecture{
+	"amd64": lambdaTypes.ArchitectureX8664,
+	"arm64": lambdaTypes.ArchitectureArm64,
+}
+
+// Returns an error if Lambda does not support the architecture.
+func validateArch(arch string) error {
+	if _, ok := architectures[arch]; !ok {
+		return fmt.Errorf(`architecture "%s" is not one of amd64, arm64`, arch)
+	}
+	return nil
+}
+
+// Parses comma-separated folder=arch pairs, e.g. testLambda01=arm64,testLambda02=amd64.
+func parseArchOverrides(s string) (map[string]string, error) {
+	overrides, err := parseOverrides(s)
+	if err != nil {
+		return nil, err
+	}
+	for _, arch := range overrides {
+		err := validateArch(arch)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return overrides, nil
+}
+
+// Returns the architecture to build and deploy the folder for.
+func (d *data) archFor(folder string) string {
+	if arch, ok := d.archOverrides[folder]; ok {
+		return arch
+	}
+	return d.arch
+}
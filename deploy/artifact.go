@@ -0,0 +1,84 @@
+package deploy
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Returns the deployment package built outside the builder for each folder, e.g. by Bazel or Nix,
+// from the artifact option of each folder block and -artifact.
+//
+//	builder -artifact=testLambda01=bazel-bin/testLambda01/package.zip
+//
+// A path without a folder is the artifact of the only folder deployed.
+func folderArtifacts(s string, folders []string) (map[string]string, error) {
+	artifacts := map[string]string{}
+	for folder, fc := range folderConfigs {
+		if fc.Artifact != "" {
+			artifacts[folder] = fc.Artifact
+		}
+	}
+	if s != "" && !strings.Contains(s, "=") {
+		if len(folders) != 1 {
+			return nil, fmt.Errorf(`artifact "%s" has no folder, but (%d) folders are deployed`, s, len(folders))
+		}
+		artifacts[folders[0]] = s
+		return artifacts, nil
+	}
+	overrides, err := parseOverrides(s)
+	if err != nil {
+		return nil, err
+	}
+	for folder, path := range overrides {
+		artifacts[folder] = path
+	}
+	return artifacts, nil
+}
+
+// Hashes the artifact instead of the source code, which the builder does not build.
+func (d *data) hashArtifact(folder, path string) (string, error) {
+	d.logf(folder, "hash", "Hashing artifact %s.", path)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		d.failf(folder, "hash", err, "Failed to read artifact: %s.", err.Error())
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	hash := base64.StdEncoding.EncodeToString(sum[:])
+	d.donef(folder, "hash", "Hashed artifact: %s", hash)
+	return hash, nil
+}
+
+// Returns the unsigned deployment package of the artifact and its size.
+// Zips are deployed as they are, anything else is zipped like an executable the builder built.
+func (d *data) readArtifact(folder, path string) (*bytes.Buffer, int, error) {
+	if !strings.HasSuffix(path, ".zip") {
+		err := d.checkUnzippedSize(folder, path)
+		if err != nil {
+			return nil, 0, err
+		}
+		r, err := d.zipExecutable(folder, path, d.handlerFor(folder))
+		if err != nil {
+			return nil, 0, err
+		}
+		return d.sizeExecutable(folder, r)
+	}
+	d.logf(folder, "zip", "Reading deployment package %s.", path)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		d.failf(folder, "zip", err, "Failed to read deployment package: %s.", err.Error())
+		return nil, 0, err
+	}
+	_, err = zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		d.failf(folder, "zip", err, "Deployment package %s is not a zip: %s.", path, err.Error())
+		return nil, 0, err
+	}
+	d.donef(folder, "zip", "Read deployment package: %.2f M.", float64(len(b))/1000000)
+	return bytes.NewBuffer(b), len(b), nil
+}
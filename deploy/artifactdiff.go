@@ -0,0 +1,209 @@
+package deploy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"debug/buildinfo"
+	"encoding/base64"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// A file in a deployment package.
+type artifactFile struct {
+	size uint64
+	hash string
+	// nil if the file is not a Go executable
+	buildInfo *buildinfo.BuildInfo
+}
+
+// Prints what changed between two versions of a function, file by file.
+// For Go executables, also prints what changed in the embedded build info.
+//
+//	builder artifact-diff -folder=orders -from=35 -to=36
+//
+// Compares to $LATEST if -to is not passed in.
+func artifactDiff() error {
+	folder := *folderFlag
+	if folder == "" {
+		return errors.New(`flag "folder" is required`)
+	}
+	if *fromFlag == "" {
+		return errors.New(`flag "from" is required`)
+	}
+	function := orDefault(*functionFlag, functionName(folder))
+	to := orDefault(*toFlag, "$LATEST")
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return err
+	}
+	d := &data{
+		configs:        folderConfigs,
+		functionPrefix: *functionPrefixFlag,
+		functionSuffix: *functionSuffixFlag,
+		ctx:            context.TODO(),
+		metrics:        runMetrics,
+		lambda:         lambda.NewFromConfig(cfg),
+	}
+	from, err := d.downloadArtifact(folder, function, *fromFlag)
+	if err != nil {
+		return err
+	}
+	files, err := d.downloadArtifact(folder, function, to)
+	if err != nil {
+		return err
+	}
+	printf("\nComparing version %s to version %s of %s.\n\n", *fromFlag, to, function)
+	printArtifactDiff(from, files)
+	return nil
+}
+
+// Downloads and unzips the deployment package of a version of the function.
+func (d *data) downloadArtifact(folder, function, version string) (map[string]artifactFile, error) {
+	d.logf(folder, "download", "Downloading version %s of Lambda function %s.", version, function)
+	output, err := d.lambda.GetFunction(d.ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(function),
+		Qualifier:    aws.String(version),
+	})
+	if err != nil {
+		d.failf(folder, "download", err, "Failed to get Lambda function: %s", err.Error())
+		return nil, err
+	}
+	code, err := downloadCode(output.Code)
+	if err != nil {
+		d.failf(folder, "download", err, "Failed to download code: %s", err.Error())
+		return nil, err
+	}
+	files, err := unzipArtifact(code)
+	if err != nil {
+		d.failf(folder, "download", err, "Failed to unzip code: %s", err.Error())
+		return nil, err
+	}
+	d.donef(folder, "download", "Downloaded version %s, (%d) files.", version, len(files))
+	return files, nil
+}
+
+func unzipArtifact(code []byte) (map[string]artifactFile, error) {
+	r, err := zip.NewReader(bytes.NewReader(code), int64(len(code)))
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]artifactFile{}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.Sum256(b)
+		file := artifactFile{
+			size: f.UncompressedSize64,
+			hash: base64.StdEncoding.EncodeToString(hash[:]),
+		}
+		// not every file is a Go executable
+		if info, err := buildinfo.Read(bytes.NewReader(b)); err == nil {
+			file.buildInfo = info
+		}
+		files[f.Name] = file
+	}
+	return files, nil
+}
+
+// Prints the files that were added, removed, or changed.
+//
+//	~ bootstrap: 8123456 -> 8125001 bytes (+1545)
+//	+ config.json (512 bytes)
+func printArtifactDiff(from, to map[string]artifactFile) {
+	names := []string{}
+	for name := range from {
+		names = append(names, name)
+	}
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	unchanged := 0
+	for _, name := range names {
+		a, inFrom := from[name]
+		b, inTo := to[name]
+		switch {
+		case !inTo:
+			printf("- %s (%d bytes)\n", name, a.size)
+		case !inFrom:
+			printf("+ %s (%d bytes)\n", name, b.size)
+		case a.hash == b.hash:
+			unchanged++
+		default:
+			printf("~ %s: %d -> %d bytes (%+d)\n", name, a.size, b.size, int64(b.size)-int64(a.size))
+			if a.buildInfo != nil && b.buildInfo != nil {
+				printBuildInfoDiff(a.buildInfo, b.buildInfo)
+			}
+		}
+	}
+	printf("\n(%d) files are unchanged.\n", unchanged)
+}
+
+// Prints what changed in the Go version, build settings, and dependencies of an executable.
+func printBuildInfoDiff(from, to *buildinfo.BuildInfo) {
+	if from.GoVersion != to.GoVersion {
+		printf("    go: %s -> %s\n", from.GoVersion, to.GoVersion)
+	}
+	fromSettings := map[string]string{}
+	for _, s := range from.Settings {
+		fromSettings[s.Key] = s.Value
+	}
+	toSettings := map[string]string{}
+	for _, s := range to.Settings {
+		toSettings[s.Key] = s.Value
+	}
+	printMapDiff("setting", fromSettings, toSettings)
+	fromDeps := map[string]string{}
+	for _, dep := range from.Deps {
+		fromDeps[dep.Path] = dep.Version
+	}
+	toDeps := map[string]string{}
+	for _, dep := range to.Deps {
+		toDeps[dep.Path] = dep.Version
+	}
+	printMapDiff("dep", fromDeps, toDeps)
+}
+
+func printMapDiff(kind string, from, to map[string]string) {
+	keys := []string{}
+	for key := range from {
+		keys = append(keys, key)
+	}
+	for key := range to {
+		if _, ok := from[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		a, inFrom := from[key]
+		b, inTo := to[key]
+		switch {
+		case !inTo:
+			printf("    - %s %s %s\n", kind, key, a)
+		case !inFrom:
+			printf("    + %s %s %s\n", kind, key, b)
+		case a != b:
+			printf("    ~ %s %s: %s -> %s\n", kind, key, a, b)
+		}
+	}
+}
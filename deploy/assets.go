@@ -0,0 +1,174 @@
+package deploy
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Files to add to the deployment package of the folder alongside the executable,
+// e.g. templates, certificates, shared libraries, or data the function reads at run time:
+//
+//	folder "api" {
+//	  asset {
+//	    source      = "templates/*.html"
+//	    destination = "templates/"
+//	  }
+//	  asset {
+//	    source      = "../shared/certs/ca.pem"
+//	    destination = "certs/root.pem"
+//	  }
+//	}
+//
+// The source is a glob relative to the folder. Directories it matches are added with everything in them.
+// The destination is a path in the zip, which Lambda extracts to /var/task.
+// A destination that ends with a slash, or of a glob that matches more than one file, is a directory the matches are added to.
+// Without a destination, matches are added at the root of the zip.
+type assetConfig struct {
+	Source      string `hcl:"source"`
+	Destination string `hcl:"destination,optional"`
+}
+
+// A file to add to the deployment package.
+type zipAsset struct {
+	// the file on disk
+	path string
+	// the entry in the zip
+	name string
+	mode fs.FileMode
+}
+
+// Returns an error if the destination of the asset is outside the zip.
+func (a assetConfig) validate() error {
+	if a.Source == "" {
+		return fmt.Errorf("asset has no source")
+	}
+	_, err := filepath.Match(a.Source, "")
+	if err != nil {
+		return fmt.Errorf(`invalid asset source "%s": %w`, a.Source, err)
+	}
+	if path.IsAbs(a.Destination) || strings.HasPrefix(path.Clean(a.Destination), "..") {
+		return fmt.Errorf(`invalid asset destination "%s": must be a relative path inside the zip`, a.Destination)
+	}
+	return nil
+}
+
+// Returns the files to add to the deployment package of the folder, in order of their names in the zip.
+// Returns an error if a source matches nothing, or two files would have the same name.
+func (d *data) folderAssets(folder string) ([]zipAsset, error) {
+	assets := []zipAsset{}
+	for _, a := range d.configs[folder].Assets {
+		matches, err := filepath.Glob(filepath.Join(sourceFolder(folder), a.Source))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf(`asset "%s" matches no files`, a.Source)
+		}
+		dir := a.Destination == "" || strings.HasSuffix(a.Destination, "/") || len(matches) > 1
+		for _, match := range matches {
+			name := path.Clean(a.Destination)
+			if dir {
+				name = path.Join(a.Destination, filepath.Base(match))
+			}
+			files, err := assetFiles(match, name)
+			if err != nil {
+				return nil, err
+			}
+			assets = append(assets, files...)
+		}
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].name < assets[j].name })
+	for i := 1; i < len(assets); i++ {
+		if assets[i].name == assets[i-1].name {
+			return nil, fmt.Errorf(`assets "%s" and "%s" are both zipped to %s`, assets[i-1].path, assets[i].path, assets[i].name)
+		}
+	}
+	return assets, nil
+}
+
+// Returns the file, or every file in the directory, named under name.
+func assetFiles(match, name string) ([]zipAsset, error) {
+	files := []zipAsset{}
+	err := filepath.WalkDir(match, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("asset %s is not a regular file", p)
+		}
+		rel, err := filepath.Rel(match, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, zipAsset{path: p, name: path.Join(name, filepath.ToSlash(rel)), mode: info.Mode().Perm()})
+		return nil
+	})
+	return files, err
+}
+
+// Adds the assets of the folder to the deployment package. Returns how many bytes were added.
+func (d *data) zipAssets(folder, handler string, w *zip.Writer) (int64, error) {
+	assets, err := d.folderAssets(folder)
+	if err != nil || len(assets) == 0 {
+		return 0, err
+	}
+	var written int64
+	for _, a := range assets {
+		if a.name == handler {
+			return 0, fmt.Errorf("asset %s would replace the executable %s", a.path, handler)
+		}
+		n, err := zipFile(w, d.zip, a)
+		if err != nil {
+			return 0, err
+		}
+		written += n
+	}
+	d.logf(folder, "zip", "Zipped (%d) assets.", len(assets))
+	return written, nil
+}
+
+func zipFile(w *zip.Writer, c zipCompression, a zipAsset) (int64, error) {
+	entryW, err := w.CreateHeader(c.header(a.name, a.mode))
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.Open(a.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return io.Copy(entryW, f)
+}
+
+// Writes the names and contents of the assets of the folder to the hash, so changing an asset redeploys the folder.
+// Writes nothing for folders without assets, so their hashes do not change.
+func (d *data) hashAssets(folder string, h io.Writer) error {
+	assets, err := d.folderAssets(folder)
+	if err != nil {
+		return err
+	}
+	for _, a := range assets {
+		fmt.Fprintf(h, "asset %s %o\n", a.name, a.mode)
+		f, err := os.Open(a.path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Returns the environment variables that make git authenticate to host with the token.
+// The credentials are passed through git's GIT_CONFIG_* variables so they are never written to disk.
+// Works with personal access tokens and GitHub App installation tokens.
+func gitCredentialEnv(host, username, token string) []string {
+	return []string{
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_CONFIG_COUNT=1",
+		fmt.Sprintf("GIT_CONFIG_KEY_0=url.https://%s:%s@%s/.insteadOf", username, token, host),
+		fmt.Sprintf("GIT_CONFIG_VALUE_0=https://%s/", host),
+	}
+}
+
+// Replaces every secret in s with asterisks.
+func scrub(s string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		s = strings.ReplaceAll(s, secret, "***")
+	}
+	return s
+}
@@ -0,0 +1,420 @@
+package deploy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// Formats builder bootstrap can print, see -bootstrap-format.
+const (
+	bootstrapCloudFormation = "cloudformation"
+	bootstrapTerraform      = "terraform"
+)
+
+// The platform of the signing profile bootstrap creates, the only one Lambda accepts.
+const lambdaSigningPlatform = "AWSLambda-SHA384-ECDSA"
+
+// How long noncurrent versions of signed deployment packages are kept, beyond the latest -keep-signed.
+const signedNoncurrentDays = 30
+
+// Prints a template of what a pipeline with the same flags needs in one region: the buckets with versioning
+// and lifecycle rules, the signing profile, the -history-table, and an IAM policy to deploy with.
+//
+//	builder bootstrap -region=us-east-1 -bucket=kesav-go-lambda-builder-{region} -signing-profile=builder > builder.json
+//	aws cloudformation deploy --template-file builder.json --stack-name go-lambda-builder --capabilities CAPABILITY_IAM
+//
+// With -bootstrap-format=terraform, prints Terraform JSON instead, e.g. to builder.tf.json.
+// Never calls AWS. With -regions, run it once for each region.
+func bootstrap() error {
+	region := orDefault(*regionFlag, os.Getenv("AWS_REGION"))
+	if region == "" {
+		return errors.New(`flag "region" is required`)
+	}
+	b, err := newBootstrapPlan(region)
+	if err != nil {
+		return err
+	}
+	var template interface{}
+	if *bootstrapFormatFlag == bootstrapTerraform {
+		template = b.terraform()
+	} else {
+		template = b.cloudFormation()
+	}
+	output, err := json.MarshalIndent(template, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(os.Stdout, "%s\n", output)
+	return err
+}
+
+// What a pipeline with the flags needs in the region.
+type bootstrapPlan struct {
+	region    string
+	partition string
+	buckets   []bootstrapBucket
+	// empty if nothing is signed
+	signingProfile string
+	// empty without -history-table
+	historyTable string
+	policy       policyDocument
+}
+
+// A bucket the pipeline uses, and the prefixes it deletes old objects from.
+type bootstrapBucket struct {
+	name string
+	// objects under these prefixes expire after expireDays
+	expiring   []string
+	expireDays int
+	// noncurrent versions under this prefix expire beyond the latest keepVersions, if signed is set
+	signed       bool
+	signedPrefix string
+	keepVersions int
+}
+
+type policyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []policyStatement `json:"Statement"`
+}
+
+type policyStatement struct {
+	Sid      string   `json:"Sid"`
+	Effect   string   `json:"Effect"`
+	Action   []string `json:"Action"`
+	Resource []string `json:"Resource"`
+}
+
+func newBootstrapPlan(region string) (*bootstrapPlan, error) {
+	b := &bootstrapPlan{
+		region:         region,
+		partition:      regionPartition(region),
+		signingProfile: *signingProfileFlag,
+		historyTable:   *historyTableFlag,
+	}
+	unsignedBucket := regionalBucket(orDefault(*unsignedBucketFlag, *bucketFlag), region)
+	stagingBucket := regionalBucket(orDefault(*stagingBucketFlag, *bucketFlag), region)
+	signedBucket := regionalBucket(orDefault(*signedBucketFlag, *bucketFlag), region)
+	if signedBucket == "" {
+		return nil, errors.New(`flag "signed-bucket" or "bucket" is required`)
+	}
+	// S3 rounds expirations up to whole days
+	expireDays := int(math.Ceil(unsignedTTLFlag.Hours() / 24))
+	if expireDays < 1 {
+		expireDays = 1
+	}
+	bucket := func(name string) *bootstrapBucket {
+		for i := range b.buckets {
+			if b.buckets[i].name == name {
+				return &b.buckets[i]
+			}
+		}
+		b.buckets = append(b.buckets, bootstrapBucket{name: name, expireDays: expireDays})
+		return &b.buckets[len(b.buckets)-1]
+	}
+	signed := bucket(signedBucket)
+	signed.signed = true
+	signed.signedPrefix = prefixDir(*signedPrefixFlag)
+	signed.keepVersions = *keepSignedFlag
+	// without a prefix, an expiration would delete the whole bucket
+	if unsignedBucket != "" && prefixDir(*unsignedPrefixFlag) != "" {
+		u := bucket(unsignedBucket)
+		u.expiring = append(u.expiring, prefixDir(*unsignedPrefixFlag))
+	}
+	if stagingBucket != "" && prefixDir(*stagingPrefixFlag) != "" {
+		s := bucket(stagingBucket)
+		s.expiring = append(s.expiring, prefixDir(*stagingPrefixFlag))
+	}
+	b.policy = b.deployPolicy()
+	return b, nil
+}
+
+// Returns the IAM policy a run with the flags needs, scoped to the buckets, signing profile, table, and functions.
+func (b *bootstrapPlan) deployPolicy() policyDocument {
+	arn := func(service, resource string) string {
+		return fmt.Sprintf("arn:%s:%s:%s:*:%s", b.partition, service, b.region, resource)
+	}
+	buckets := []string{}
+	objects := []string{}
+	for _, bucket := range b.buckets {
+		buckets = append(buckets, fmt.Sprintf("arn:%s:s3:::%s", b.partition, bucket.name))
+		objects = append(objects, fmt.Sprintf("arn:%s:s3:::%s/*", b.partition, bucket.name))
+	}
+	functions := arn("lambda", "function:"+*functionPrefixFlag+"*")
+	statements := []policyStatement{
+		{
+			Sid:      "Buckets",
+			Action:   []string{"s3:GetBucketLocation", "s3:GetBucketVersioning", "s3:ListBucket", "s3:ListBucketVersions"},
+			Resource: buckets,
+		},
+		{
+			Sid: "DeploymentPackages",
+			Action: []string{
+				"s3:DeleteObject", "s3:DeleteObjectVersion", "s3:GetObject", "s3:GetObjectVersion",
+				"s3:PutObject", "s3:PutObjectTagging",
+			},
+			Resource: objects,
+		},
+		{
+			Sid: "Functions",
+			Action: []string{
+				"lambda:CreateAlias", "lambda:DeleteFunctionVersion", "lambda:DeleteProvisionedConcurrencyConfig",
+				"lambda:GetAlias", "lambda:GetFunction", "lambda:GetFunctionConfiguration", "lambda:GetProvisionedConcurrencyConfig",
+				"lambda:ListAliases", "lambda:ListTags", "lambda:ListVersionsByFunction", "lambda:PublishVersion",
+				"lambda:PutProvisionedConcurrencyConfig", "lambda:TagResource", "lambda:UpdateAlias",
+				"lambda:UpdateFunctionCode", "lambda:UpdateFunctionConfiguration",
+			},
+			Resource: []string{functions, functions + ":*"},
+		},
+	}
+	if *createMissingFlag {
+		statements = append(statements, policyStatement{
+			Sid:      "CreateFunctions",
+			Action:   []string{"lambda:CreateFunction", "iam:PassRole"},
+			Resource: []string{"*"},
+		})
+	}
+	if b.signingProfile != "" {
+		statements = append(statements, policyStatement{
+			Sid:      "Signing",
+			Action:   []string{"signer:GetSigningProfile", "signer:StartSigningJob"},
+			Resource: []string{arn("signer", "/signing-profiles/"+b.signingProfile)},
+		}, policyStatement{
+			Sid:      "SigningJobs",
+			Action:   []string{"signer:DescribeSigningJob"},
+			Resource: []string{"*"},
+		})
+	}
+	if *codeSigningConfigFlag {
+		statements = append(statements, policyStatement{
+			Sid: "CodeSigningConfigs",
+			Action: []string{
+				"lambda:CreateCodeSigningConfig", "lambda:GetFunctionCodeSigningConfig",
+				"lambda:ListCodeSigningConfigs", "lambda:PutFunctionCodeSigningConfig",
+			},
+			Resource: []string{"*"},
+		})
+	}
+	if b.historyTable != "" {
+		statements = append(statements, policyStatement{
+			Sid:      "History",
+			Action:   []string{"dynamodb:PutItem", "dynamodb:Query"},
+			Resource: []string{arn("dynamodb", "table/"+b.historyTable)},
+		})
+	}
+	if *notifySNSTopicFlag != "" {
+		statements = append(statements, policyStatement{
+			Sid:      "Notify",
+			Action:   []string{"sns:Publish"},
+			Resource: []string{*notifySNSTopicFlag},
+		})
+	}
+	if *eventBusFlag != "" {
+		statements = append(statements, policyStatement{
+			Sid:      "Events",
+			Action:   []string{"events:PutEvents"},
+			Resource: []string{arn("events", "event-bus/"+*eventBusFlag)},
+		})
+	}
+	if *canaryAlarmsFlag != "" {
+		statements = append(statements, policyStatement{
+			Sid:      "CanaryAlarms",
+			Action:   []string{"cloudwatch:DescribeAlarms"},
+			Resource: []string{"*"},
+		})
+	}
+	for i := range statements {
+		statements[i].Effect = "Allow"
+	}
+	return policyDocument{Version: "2012-10-17", Statement: statements}
+}
+
+// Returns the lifecycle rules of the bucket, in the shape of CloudFormation or Terraform.
+func (bucket bootstrapBucket) lifecycleRules(terraform bool) []map[string]interface{} {
+	rules := []map[string]interface{}{}
+	rule := func(id, prefix string, action map[string]interface{}) {
+		r := map[string]interface{}{}
+		if terraform {
+			r["id"] = id
+			r["status"] = "Enabled"
+			r["filter"] = map[string]interface{}{"prefix": prefix}
+		} else {
+			r["Id"] = id
+			r["Status"] = "Enabled"
+			r["Prefix"] = prefix
+		}
+		for key, value := range action {
+			r[key] = value
+		}
+		rules = append(rules, r)
+	}
+	for _, prefix := range bucket.expiring {
+		id := "expire-" + strings.ReplaceAll(strings.TrimSuffix(prefix, "/"), "/", "-")
+		if terraform {
+			rule(id, prefix, map[string]interface{}{"expiration": map[string]interface{}{"days": bucket.expireDays}})
+		} else {
+			rule(id, prefix, map[string]interface{}{"ExpirationInDays": bucket.expireDays})
+		}
+	}
+	if bucket.signed {
+		if terraform {
+			rule("keep-signed", bucket.signedPrefix, map[string]interface{}{"noncurrent_version_expiration": map[string]interface{}{
+				"noncurrent_days":           signedNoncurrentDays,
+				"newer_noncurrent_versions": bucket.keepVersions,
+			}})
+		} else {
+			rule("keep-signed", bucket.signedPrefix, map[string]interface{}{"NoncurrentVersionExpiration": map[string]interface{}{
+				"NoncurrentDays":          signedNoncurrentDays,
+				"NewerNoncurrentVersions": bucket.keepVersions,
+			}})
+		}
+	}
+	if terraform {
+		rule("abort-multipart-uploads", "", map[string]interface{}{"abort_incomplete_multipart_upload": map[string]interface{}{"days_after_initiation": 1}})
+	} else {
+		rule("abort-multipart-uploads", "", map[string]interface{}{"AbortIncompleteMultipartUpload": map[string]interface{}{"DaysAfterInitiation": 1}})
+	}
+	return rules
+}
+
+// Returns the names of each bucket's resources, the first is named like the rest of the template.
+func bucketResourceName(name string, i int, separator string) string {
+	if i == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s%s%d", name, separator, i+1)
+}
+
+// Returns a CloudFormation template of the plan.
+func (b *bootstrapPlan) cloudFormation() map[string]interface{} {
+	resources := map[string]interface{}{}
+	for i, bucket := range b.buckets {
+		resources[bucketResourceName("Bucket", i, "")] = map[string]interface{}{
+			"Type":           "AWS::S3::Bucket",
+			"DeletionPolicy": "Retain",
+			"Properties": map[string]interface{}{
+				"BucketName":              bucket.name,
+				"VersioningConfiguration": map[string]interface{}{"Status": "Enabled"},
+				"LifecycleConfiguration":  map[string]interface{}{"Rules": bucket.lifecycleRules(false)},
+				"PublicAccessBlockConfiguration": map[string]interface{}{
+					"BlockPublicAcls":       true,
+					"BlockPublicPolicy":     true,
+					"IgnorePublicAcls":      true,
+					"RestrictPublicBuckets": true,
+				},
+			},
+		}
+	}
+	if b.signingProfile != "" {
+		resources["SigningProfile"] = map[string]interface{}{
+			"Type": "AWS::Signer::SigningProfile",
+			"Properties": map[string]interface{}{
+				"ProfileName": b.signingProfile,
+				"PlatformId":  lambdaSigningPlatform,
+			},
+		}
+	}
+	if b.historyTable != "" {
+		resources["HistoryTable"] = map[string]interface{}{
+			"Type":           "AWS::DynamoDB::Table",
+			"DeletionPolicy": "Retain",
+			"Properties": map[string]interface{}{
+				"TableName":   b.historyTable,
+				"BillingMode": "PAY_PER_REQUEST",
+				"AttributeDefinitions": []map[string]string{
+					{"AttributeName": historyFunction, "AttributeType": "S"},
+					{"AttributeName": historyDeployed, "AttributeType": "S"},
+				},
+				"KeySchema": []map[string]string{
+					{"AttributeName": historyFunction, "KeyType": "HASH"},
+					{"AttributeName": historyDeployed, "KeyType": "RANGE"},
+				},
+			},
+		}
+	}
+	resources["DeployPolicy"] = map[string]interface{}{
+		"Type": "AWS::IAM::ManagedPolicy",
+		"Properties": map[string]interface{}{
+			"Description":    "What go-lambda-builder needs to deploy.",
+			"PolicyDocument": b.policy,
+		},
+	}
+	return map[string]interface{}{
+		"AWSTemplateFormatVersion": "2010-09-09",
+		"Description":              "What go-lambda-builder needs to deploy in " + b.region + ".",
+		"Resources":                resources,
+		"Outputs": map[string]interface{}{
+			"DeployPolicyArn": map[string]interface{}{"Value": map[string]string{"Ref": "DeployPolicy"}},
+		},
+	}
+}
+
+// Returns a Terraform JSON configuration of the plan.
+func (b *bootstrapPlan) terraform() map[string]interface{} {
+	resources := map[string]map[string]interface{}{
+		"aws_s3_bucket":                         {},
+		"aws_s3_bucket_versioning":              {},
+		"aws_s3_bucket_lifecycle_configuration": {},
+		"aws_s3_bucket_public_access_block":     {},
+	}
+	for i, bucket := range b.buckets {
+		name := bucketResourceName("builder", i, "_")
+		ref := "${aws_s3_bucket." + name + ".id}"
+		resources["aws_s3_bucket"][name] = map[string]interface{}{"bucket": bucket.name}
+		resources["aws_s3_bucket_versioning"][name] = map[string]interface{}{
+			"bucket":                   ref,
+			"versioning_configuration": map[string]string{"status": "Enabled"},
+		}
+		resources["aws_s3_bucket_lifecycle_configuration"][name] = map[string]interface{}{
+			"bucket": ref,
+			"rule":   bucket.lifecycleRules(true),
+			// noncurrent version rules need versioning first
+			"depends_on": []string{"aws_s3_bucket_versioning." + name},
+		}
+		resources["aws_s3_bucket_public_access_block"][name] = map[string]interface{}{
+			"bucket":                  ref,
+			"block_public_acls":       true,
+			"block_public_policy":     true,
+			"ignore_public_acls":      true,
+			"restrict_public_buckets": true,
+		}
+	}
+	if b.signingProfile != "" {
+		resources["aws_signer_signing_profile"] = map[string]interface{}{
+			"builder": map[string]string{"name": b.signingProfile, "platform_id": lambdaSigningPlatform},
+		}
+	}
+	if b.historyTable != "" {
+		resources["aws_dynamodb_table"] = map[string]interface{}{
+			"history": map[string]interface{}{
+				"name":         b.historyTable,
+				"billing_mode": "PAY_PER_REQUEST",
+				"hash_key":     historyFunction,
+				"range_key":    historyDeployed,
+				"attribute": []map[string]string{
+					{"name": historyFunction, "type": "S"},
+					{"name": historyDeployed, "type": "S"},
+				},
+			},
+		}
+	}
+	// Terraform takes the policy as a JSON string
+	policy, _ := json.Marshal(b.policy)
+	resources["aws_iam_policy"] = map[string]interface{}{
+		"builder": map[string]string{
+			"name_prefix": "go-lambda-builder-",
+			"description": "What go-lambda-builder needs to deploy.",
+			"policy":      string(policy),
+		},
+	}
+	return map[string]interface{}{
+		"provider": map[string]interface{}{"aws": map[string]string{"region": b.region}},
+		"resource": resources,
+		"output": map[string]interface{}{
+			"deploy_policy_arn": map[string]string{"value": "${aws_iam_policy.builder.arn}"},
+		},
+	}
+}
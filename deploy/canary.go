@@ -0,0 +1,112 @@
+package deploy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cloudwatchTypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// How often to check the canary alarms while baking.
+const canaryAlarmDelay = 30 * time.Second
+
+// Shifts a percentage of the alias's traffic to version, bakes, then points the alias at version.
+// Points the alias back at previous with no canary if any of the canary alarms fire while baking.
+func (d *data) canaryFunctionAlias(folder, alias, previous, version, description string) error {
+	d.logf(folder, "canary", "Shifting %g%% of alias %s to version %s.", d.canaryPercent, alias, version)
+	_, err := d.lambda.UpdateAlias(d.ctx, &lambda.UpdateAliasInput{
+		FunctionName:    aws.String(d.functionName(folder)),
+		Name:            aws.String(alias),
+		FunctionVersion: aws.String(previous),
+		RoutingConfig: &lambdaTypes.AliasRoutingConfiguration{
+			AdditionalVersionWeights: map[string]float64{version: d.canaryPercent / 100},
+		},
+	})
+	if err != nil {
+		d.failf(folder, "canary", err, "Failed to shift traffic to version %s: %s", version, err.Error())
+		return err
+	}
+	d.logf(folder, "canary", "Baking for %s.", d.canaryBake)
+	deadline := time.Now().Add(d.canaryBake)
+	for {
+		alarms, err := d.firingAlarms()
+		if err != nil {
+			d.failf(folder, "canary", err, "Failed to check alarms: %s", err.Error())
+			return err
+		}
+		if len(alarms) != 0 {
+			err := fmt.Errorf("canary alarms fired: %v", alarms)
+			d.logf(folder, "canary", "Alarms %v fired, rolling back alias %s to version %s.", alarms, alias, previous)
+			rollbackErr := d.pointFunctionAlias(folder, alias, previous, nil)
+			if rollbackErr != nil {
+				d.failf(folder, "canary", rollbackErr, "Failed to roll back alias %s: %s", alias, rollbackErr.Error())
+				return rollbackErr
+			}
+			d.failf(folder, "canary", err, "Rolled back alias %s to version %s.", alias, previous)
+			return err
+		}
+		if !time.Now().Before(deadline) {
+			break
+		}
+		time.Sleep(minDuration(canaryAlarmDelay, time.Until(deadline)))
+	}
+	err = d.pointFunctionAlias(folder, alias, version, aws.String(description))
+	if err != nil {
+		d.failf(folder, "canary", err, "Failed to update alias of Lambda function: %s", err.Error())
+		return err
+	}
+	d.donef(folder, "canary", "Baked, updated alias %s of Lambda function: %s.", alias, description)
+	return nil
+}
+
+// Points all of the alias's traffic at version.
+func (d *data) pointFunctionAlias(folder, alias, version string, description *string) error {
+	_, err := d.lambda.UpdateAlias(d.ctx, &lambda.UpdateAliasInput{
+		FunctionName:    aws.String(d.functionName(folder)),
+		Name:            aws.String(alias),
+		FunctionVersion: aws.String(version),
+		Description:     description,
+		// an empty map removes the canary
+		RoutingConfig: &lambdaTypes.AliasRoutingConfiguration{
+			AdditionalVersionWeights: map[string]float64{},
+		},
+	})
+	return err
+}
+
+// Returns the canary alarms that are in the ALARM state.
+func (d *data) firingAlarms() ([]string, error) {
+	if len(d.canaryAlarms) == 0 {
+		return nil, nil
+	}
+	output, err := d.cloudwatch.DescribeAlarms(d.ctx, &cloudwatch.DescribeAlarmsInput{
+		AlarmNames: d.canaryAlarms,
+		StateValue: cloudwatchTypes.StateValueAlarm,
+		AlarmTypes: []cloudwatchTypes.AlarmType{
+			cloudwatchTypes.AlarmTypeMetricAlarm,
+			cloudwatchTypes.AlarmTypeCompositeAlarm,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	alarms := []string{}
+	for _, alarm := range output.MetricAlarms {
+		alarms = append(alarms, aws.ToString(alarm.AlarmName))
+	}
+	for _, alarm := range output.CompositeAlarms {
+		alarms = append(alarms, aws.ToString(alarm.AlarmName))
+	}
+	return alarms, nil
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
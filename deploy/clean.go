@@ -0,0 +1,254 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Deletes what deploys leave behind, since every deploy otherwise keeps every version forever
+// and accounts run into Lambda's code storage limit.
+//
+//	builder clean \
+//	    -bucket=kesav-go-lambda-builder-test \
+//	    -unsigned-prefix=test/unsigned \
+//	    -staging-prefix=test/staging \
+//	    -signed-prefix=test/signed \
+//	    -keep-signed=10 \
+//	    -keep-versions=10
+//
+// Deletes unsigned and staging objects older than -unsigned-ttl, the old versions of signed deployment packages
+// beyond -keep-signed, and the versions of each selected function beyond -keep-versions that no alias points at.
+// With -read-only, prints what it would delete.
+func clean() error {
+	if *keepSignedFlag < 1 || *keepVersionsFlag < 1 {
+		return errors.New(`flags "keep-signed" and "keep-versions" must be at least 1`)
+	}
+	folders, err := selectFolders()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return err
+	}
+	d := &data{
+		configs:        folderConfigs,
+		functionPrefix: *functionPrefixFlag,
+		functionSuffix: *functionSuffixFlag,
+		ctx:            context.TODO(),
+		metrics:        runMetrics,
+		readOnly:       *readOnlyFlag,
+		s3:             newS3Client(cfg),
+		unsignedBucket: orDefault(*unsignedBucketFlag, *bucketFlag),
+		unsignedPrefix: *unsignedPrefixFlag,
+		stagingBucket:  orDefault(*stagingBucketFlag, *bucketFlag),
+		stagingPrefix:  *stagingPrefixFlag,
+		signedBucket:   orDefault(*signedBucketFlag, *bucketFlag),
+		signedPrefix:   *signedPrefixFlag,
+		lambda:         lambda.NewFromConfig(cfg),
+	}
+	// runs with -regions leave packages and versions behind in each of them
+	targets, err := d.commandTargets(cfg)
+	if err != nil {
+		return err
+	}
+	failures := []string{}
+	for _, r := range targets {
+		failures = append(failures, r.clean(folders)...)
+	}
+	if len(failures) != 0 {
+		return fmt.Errorf("failed to clean: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// Cleans the buckets and the functions of the folders in the region of the run.
+// Returns what failed to be cleaned.
+func (d *data) clean(folders []string) []string {
+	failures := []string{}
+	cutoff := time.Now().Add(-*unsignedTTLFlag)
+	if d.unsignedBucket != "" && d.unsignedPrefix != "" {
+		err := d.cleanPrefix("unsigned deployment packages", d.unsignedBucket, d.unsignedPrefix, cutoff)
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if d.stagingBucket != "" && d.stagingPrefix != "" {
+		err := d.cleanPrefix("signing job output", d.stagingBucket, d.stagingPrefix, cutoff)
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if d.signedBucket != "" && d.signedPrefix != "" {
+		err := d.pruneSignedVersions(*keepSignedFlag)
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	printf("Deleting versions of (%d) functions in %s beyond the latest %d that no alias points at.\n\n", len(folders), d.region, *keepVersionsFlag)
+	for _, folder := range folders {
+		err := d.pruneFunctionVersions(folder, *keepVersionsFlag)
+		if err != nil {
+			failures = append(failures, d.folderInRegion(folder))
+		}
+	}
+	return failures
+}
+
+// Deletes the old versions of every signed deployment package beyond the latest keep, in a versioned signed bucket.
+func (d *data) pruneSignedVersions(keep int) error {
+	printf("Deleting versions of signed deployment packages in s3://%s/%s beyond the latest %d.\n\n", d.signedBucket, prefixDir(d.signedPrefix), keep)
+	versions := map[string][]s3Types.ObjectVersion{}
+	// the S3 client has no paginator for object versions
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(d.signedBucket),
+		Prefix: aws.String(prefixDir(d.signedPrefix)),
+	}
+	for {
+		output, err := d.s3.ListObjectVersions(d.ctx, input)
+		if err != nil {
+			return err
+		}
+		for _, version := range output.Versions {
+			key := aws.ToString(version.Key)
+			versions[key] = append(versions[key], version)
+		}
+		if !output.IsTruncated {
+			break
+		}
+		input.KeyMarker = output.NextKeyMarker
+		input.VersionIdMarker = output.NextVersionIdMarker
+	}
+	keys := []string{}
+	for key := range versions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	failures := []string{}
+	for _, key := range keys {
+		kept := versions[key]
+		sort.Slice(kept, func(i, j int) bool { return aws.ToTime(kept[i].LastModified).After(aws.ToTime(kept[j].LastModified)) })
+		if len(kept) <= keep {
+			continue
+		}
+		folder := strings.TrimSuffix(key[strings.LastIndex(key, "/")+1:], ".zip")
+		deleted := 0
+		for _, version := range kept[keep:] {
+			versionID := aws.ToString(version.VersionId)
+			if d.readOnly {
+				d.logf(folder, "clean", "Refusing to delete version %s of %s in read-only mode.", versionID, key)
+				continue
+			}
+			_, err := d.s3.DeleteObject(d.ctx, &s3.DeleteObjectInput{
+				Bucket:    aws.String(d.signedBucket),
+				Key:       aws.String(key),
+				VersionId: aws.String(versionID),
+			})
+			if err != nil {
+				d.failf(folder, "clean", err, "Failed to delete version %s of %s: %s", versionID, key, err.Error())
+				failures = append(failures, key)
+				continue
+			}
+			deleted++
+		}
+		d.donef(folder, "clean", "Deleted (%d) old versions of %s.", deleted, key)
+	}
+	if len(failures) != 0 {
+		return fmt.Errorf("failed to delete old versions of signed deployment packages: %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// Deletes the versions of the folder's function beyond the latest keep that no alias points at or routes traffic to.
+func (d *data) pruneFunctionVersions(folder string, keep int) error {
+	aliased, err := d.aliasedVersions(folder)
+	if err != nil {
+		d.failf(folder, "clean", err, "Failed to list aliases: %s", err.Error())
+		return err
+	}
+	numbers := []int{}
+	versions := lambda.NewListVersionsByFunctionPaginator(d.lambda, &lambda.ListVersionsByFunctionInput{
+		FunctionName: aws.String(d.functionName(folder)),
+	})
+	for versions.HasMorePages() {
+		output, err := versions.NextPage(d.ctx)
+		if err != nil {
+			d.failf(folder, "clean", err, "Failed to list versions: %s", err.Error())
+			return err
+		}
+		for _, version := range output.Versions {
+			// skips $LATEST
+			n, err := strconv.Atoi(aws.ToString(version.Version))
+			if err == nil {
+				numbers = append(numbers, n)
+			}
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(numbers)))
+	if len(numbers) <= keep {
+		d.donef(folder, "clean", "Lambda function has (%d) versions, not deleting any.", len(numbers))
+		return nil
+	}
+	deleted := 0
+	for _, n := range numbers[keep:] {
+		version := strconv.Itoa(n)
+		if aliased[version] {
+			continue
+		}
+		if d.readOnly {
+			d.logf(folder, "clean", "Refusing to delete version %s in read-only mode.", version)
+			continue
+		}
+		_, err := d.lambda.DeleteFunction(d.ctx, &lambda.DeleteFunctionInput{
+			FunctionName: aws.String(d.functionName(folder)),
+			Qualifier:    aws.String(version),
+		})
+		// e.g. versions used by event source mappings or with provisioned concurrency
+		var conflict *lambdaTypes.ResourceConflictException
+		if errors.As(err, &conflict) {
+			d.logf(folder, "clean", "Not deleting version %s, it is in use: %s.", version, err.Error())
+			continue
+		}
+		if err != nil {
+			d.failf(folder, "clean", err, "Failed to delete version %s: %s", version, err.Error())
+			return err
+		}
+		deleted++
+	}
+	d.donef(folder, "clean", "Deleted (%d) old versions of Lambda function.", deleted)
+	return nil
+}
+
+// Returns the versions of the folder's function that an alias points at or routes traffic to.
+func (d *data) aliasedVersions(folder string) (map[string]bool, error) {
+	aliased := map[string]bool{}
+	aliases := lambda.NewListAliasesPaginator(d.lambda, &lambda.ListAliasesInput{
+		FunctionName: aws.String(d.functionName(folder)),
+	})
+	for aliases.HasMorePages() {
+		output, err := aliases.NextPage(d.ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, alias := range output.Aliases {
+			aliased[aws.ToString(alias.FunctionVersion)] = true
+			if alias.RoutingConfig != nil {
+				for version := range alias.RoutingConfig.AdditionalVersionWeights {
+					aliased[version] = true
+				}
+			}
+		}
+	}
+	return aliased, nil
+}
@@ -0,0 +1,119 @@
+package deploy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Deletes unsigned deployment packages left behind by runs that were killed before they could clean up.
+//
+//	builder clean-unsigned \
+//	    -bucket=kesav-go-lambda-builder-test \
+//	    -unsigned-prefix=test/unsigned \
+//	    -unsigned-ttl=6h
+//
+// Keeps objects younger than -unsigned-ttl, and objects uploaded by a run that is still recording events.
+// With -read-only, prints the objects it would delete.
+func cleanUnsigned() error {
+	unsignedBucket := orDefault(*unsignedBucketFlag, *bucketFlag)
+	if unsignedBucket == "" {
+		return errors.New(`flag "unsigned-bucket" or "bucket" is required`)
+	}
+	if *unsignedPrefixFlag == "" {
+		return errors.New(`flag "unsigned-prefix" is required`)
+	}
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return err
+	}
+	d := &data{
+		configs:        folderConfigs,
+		functionPrefix: *functionPrefixFlag,
+		functionSuffix: *functionSuffixFlag,
+		ctx:            context.TODO(),
+		metrics:        runMetrics,
+		readOnly:       *readOnlyFlag,
+		s3:             newS3Client(cfg),
+		unsignedBucket: regionalBucket(unsignedBucket, cfg.Region),
+		unsignedPrefix: *unsignedPrefixFlag,
+	}
+	return d.cleanPrefix("unsigned deployment packages", d.unsignedBucket, d.unsignedPrefix, time.Now().Add(-*unsignedTTLFlag))
+}
+
+// Deletes the objects under the prefix last modified before cutoff that no active run uploaded,
+// e.g. unsigned deployment packages or signing job output left behind by killed runs.
+func (d *data) cleanPrefix(what, bucket, prefix string, cutoff time.Time) error {
+	printf("Cleaning %s in s3://%s/%s older than %s.\n\n",
+		what, bucket, prefixDir(prefix), cutoff.UTC().Format(time.RFC3339))
+	paginator := s3.NewListObjectsV2Paginator(d.s3, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefixDir(prefix)),
+	})
+	failures := []string{}
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(d.ctx)
+		if err != nil {
+			return err
+		}
+		for _, object := range output.Contents {
+			key := aws.ToString(object.Key)
+			folder := strings.TrimSuffix(filepath.Base(key), ".zip")
+			if object.LastModified == nil || object.LastModified.After(cutoff) {
+				continue
+			}
+			head, err := d.s3.HeadObject(d.ctx, &s3.HeadObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String(key),
+			})
+			if err != nil {
+				d.failf(folder, "clean", err, "Failed to get metadata of object (%s): %s", key, err.Error())
+				failures = append(failures, key)
+				continue
+			}
+			runID := head.Metadata["run-id"]
+			if runID != "" && runIsActive(runID) {
+				d.skipf(folder, "clean", "Not deleting object of active run %s: %s.", runID, key)
+				continue
+			}
+			d.deleteObject(folder, bucket, key)
+		}
+	}
+	if len(failures) != 0 {
+		return fmt.Errorf("failed to clean %s: %s", what, strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// Returns true if the run has an event log on this machine that does not mark it as complete.
+// Runs on other machines are never active, the age of their objects decides.
+func runIsActive(runID string) bool {
+	dir, err := eventsDir()
+	if err != nil {
+		return false
+	}
+	file, err := os.Open(filepath.Join(dir, runID+".jsonl"))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	// failed builds log their whole output in one event
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		e := event{}
+		if json.Unmarshal(scanner.Bytes(), &e) == nil && e.Step == stepDone && e.Folder == "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package deploy
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cloudwatchTypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// How many metrics PutMetricData takes at once.
+const maxMetricData = 20
+
+// Publishes metrics of the run to -cloudwatch-namespace, for dashboards and alarms on deployment health:
+//
+//	StepDuration    seconds each step took across all folders, by Step
+//	PackageSize     bytes of each unsigned deployment package, by Folder
+//	Folders         how many folders ended in each status, by Status, e.g. deployed or failed
+//	CacheHitRate    percent of folders that were already up to date
+//	RunDuration     seconds the run took, by Outcome
+//
+// Every metric also has an Environment dimension with -environment.
+// Never fails the run, and publishes nothing in read-only mode or when the run failed before it started.
+func (d *data) putCloudWatchMetrics(m *metrics, runErr error) {
+	if *cloudWatchNamespaceFlag == "" || d == nil || d.readOnly {
+		return
+	}
+	now := time.Now()
+	dimensions := func(name, value string) []cloudwatchTypes.Dimension {
+		dims := []cloudwatchTypes.Dimension{}
+		if name != "" {
+			dims = append(dims, cloudwatchTypes.Dimension{Name: aws.String(name), Value: aws.String(value)})
+		}
+		if d.environment != "" {
+			dims = append(dims, cloudwatchTypes.Dimension{Name: aws.String("Environment"), Value: aws.String(d.environment)})
+		}
+		return dims
+	}
+	datum := func(metric, name, value string, unit cloudwatchTypes.StandardUnit, v float64) cloudwatchTypes.MetricDatum {
+		return cloudwatchTypes.MetricDatum{
+			MetricName: aws.String(metric),
+			Dimensions: dimensions(name, value),
+			Timestamp:  aws.Time(now),
+			Unit:       unit,
+			Value:      aws.Float64(v),
+		}
+	}
+	data := []cloudwatchTypes.MetricDatum{}
+
+	m.mu.Lock()
+	for step, h := range m.histograms {
+		data = append(data, cloudwatchTypes.MetricDatum{
+			MetricName: aws.String("StepDuration"),
+			Dimensions: dimensions("Step", step),
+			Timestamp:  aws.Time(now),
+			Unit:       cloudwatchTypes.StandardUnitSeconds,
+			StatisticValues: &cloudwatchTypes.StatisticSet{
+				SampleCount: aws.Float64(float64(h.count)),
+				Sum:         aws.Float64(h.sum),
+				Minimum:     aws.Float64(h.min),
+				Maximum:     aws.Float64(h.max),
+			},
+		})
+	}
+	m.mu.Unlock()
+
+	d.report.mu.Lock()
+	statuses := map[string]int{}
+	sizes := map[string]int{}
+	for _, key := range d.report.keys() {
+		fr := d.report.folders[key]
+		statuses[fr.Status]++
+		if fr.Size != 0 {
+			sizes[fr.Folder] = fr.Size
+		}
+	}
+	d.report.mu.Unlock()
+	total := 0
+	for status, count := range statuses {
+		data = append(data, datum("Folders", "Status", status, cloudwatchTypes.StandardUnitCount, float64(count)))
+		total += count
+	}
+	if total != 0 {
+		data = append(data, datum("CacheHitRate", "", "", cloudwatchTypes.StandardUnitPercent, 100*float64(statuses[reportUpToDate])/float64(total)))
+	}
+	for folder, size := range sizes {
+		data = append(data, datum("PackageSize", "Folder", folder, cloudwatchTypes.StandardUnitBytes, float64(size)))
+	}
+	outcome := "succeeded"
+	if errors.Is(runErr, errTooManyFailures) {
+		outcome = "too many failures"
+	} else if runErr != nil {
+		outcome = "failed"
+	}
+	data = append(data, datum("RunDuration", "Outcome", outcome, cloudwatchTypes.StandardUnitSeconds, m.elapsed().Seconds()))
+
+	for start := 0; start < len(data); start += maxMetricData {
+		end := start + maxMetricData
+		if end > len(data) {
+			end = len(data)
+		}
+		_, err := d.cloudwatch.PutMetricData(d.ctx, &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(*cloudWatchNamespaceFlag),
+			MetricData: data[start:end],
+		})
+		if err != nil {
+			printf("Failed to publish metrics to CloudWatch namespace %s: %s.\n\n", *cloudWatchNamespaceFlag, err.Error())
+			return
+		}
+	}
+	printf("Published (%d) metrics to CloudWatch namespace %s.\n\n", len(data), *cloudWatchNamespaceFlag)
+}
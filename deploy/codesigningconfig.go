@@ -0,0 +1,132 @@
+package deploy
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/signer"
+)
+
+// Describes the code signing configs the builder creates, so later runs find them instead of creating more.
+const codeSigningConfigDescription = "go-lambda-builder signing profile %s"
+
+// The ARN of the code signing config of each signing profile in a region, found or created once per run.
+// Safe to use from multiple goroutines.
+type codeSigningConfigs struct {
+	mu   sync.Mutex
+	arns map[string]string
+}
+
+func newCodeSigningConfigs() *codeSigningConfigs {
+	return &codeSigningConfigs{arns: map[string]string{}}
+}
+
+// Makes Lambda itself check the signature of the folder's code, not just the pipeline, before the code is updated.
+// With -code-signing-config, attaches a code signing config that allows the folder's signing profile,
+// creating it if there is none. Without it, only warns if the function has no code signing config.
+func (d *data) checkCodeSigningConfig(folder string) error {
+	profile := d.signingProfileFor(folder)
+	if profile == "" {
+		return nil
+	}
+	output, err := d.lambda.GetFunctionCodeSigningConfig(d.ctx, &lambda.GetFunctionCodeSigningConfigInput{
+		FunctionName: aws.String(d.functionName(folder)),
+	})
+	if isFunctionNotFound(err) {
+		// created with the code signing config, see createFunction
+		return nil
+	}
+	if err != nil {
+		d.failf(folder, "code-signing", err, "Failed to get code signing config of Lambda function: %s", err.Error())
+		return err
+	}
+	current := aws.ToString(output.CodeSigningConfigArn)
+	if !d.codeSigningConfig {
+		if current == "" {
+			d.skipf(folder, "code-signing", "Warning: Lambda function has no code signing config, so Lambda does not check signatures. Pass -code-signing-config to attach one.")
+		}
+		return nil
+	}
+	arn, err := d.codeSigningConfigFor(folder, profile)
+	if err != nil {
+		return err
+	}
+	if current == arn {
+		return nil
+	}
+	if err := d.refuseInReadOnly(folder, "attaching code signing config"); err != nil {
+		return err
+	}
+	d.logf(folder, "code-signing", "Attaching code signing config %s.", arn)
+	_, err = d.lambda.PutFunctionCodeSigningConfig(d.ctx, &lambda.PutFunctionCodeSigningConfigInput{
+		FunctionName:         aws.String(d.functionName(folder)),
+		CodeSigningConfigArn: aws.String(arn),
+	})
+	if err != nil {
+		d.failf(folder, "code-signing", err, "Failed to attach code signing config: %s", err.Error())
+		return err
+	}
+	d.donef(folder, "code-signing", "Attached code signing config %s.", arn)
+	return nil
+}
+
+// Returns the ARN of the code signing config that allows the signing profile, creating it if there is none.
+func (d *data) codeSigningConfigFor(folder, profile string) (string, error) {
+	d.codeSigningConfigs.mu.Lock()
+	defer d.codeSigningConfigs.mu.Unlock()
+	if arn, ok := d.codeSigningConfigs.arns[profile]; ok {
+		return arn, nil
+	}
+	// packages of regions without AWS Signer are signed with the profile of the -signer-fallback region
+	output, err := d.signingRun().signer.GetSigningProfile(d.ctx, &signer.GetSigningProfileInput{
+		ProfileName: aws.String(profile),
+	})
+	if err != nil {
+		d.failf(folder, "code-signing", err, "Failed to get signing profile %s: %s", profile, err.Error())
+		return "", err
+	}
+	profileVersionARN := aws.ToString(output.ProfileVersionArn)
+	description := fmt.Sprintf(codeSigningConfigDescription, profile)
+	paginator := lambda.NewListCodeSigningConfigsPaginator(d.lambda, &lambda.ListCodeSigningConfigsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(d.ctx)
+		if err != nil {
+			d.failf(folder, "code-signing", err, "Failed to list code signing configs: %s", err.Error())
+			return "", err
+		}
+		for _, csc := range page.CodeSigningConfigs {
+			if aws.ToString(csc.Description) == description && allowsPublisher(csc, profileVersionARN) {
+				d.codeSigningConfigs.arns[profile] = aws.ToString(csc.CodeSigningConfigArn)
+				return aws.ToString(csc.CodeSigningConfigArn), nil
+			}
+		}
+	}
+	if err := d.refuseInReadOnly(folder, "creating code signing config"); err != nil {
+		return "", err
+	}
+	d.logf(folder, "code-signing", "Creating code signing config for signing profile %s.", profile)
+	created, err := d.lambda.CreateCodeSigningConfig(d.ctx, &lambda.CreateCodeSigningConfigInput{
+		Description: aws.String(description),
+		AllowedPublishers: &lambdaTypes.AllowedPublishers{
+			SigningProfileVersionArns: []string{profileVersionARN},
+		},
+		CodeSigningPolicies: &lambdaTypes.CodeSigningPolicies{
+			UntrustedArtifactOnDeployment: d.codeSigningPolicy,
+		},
+	})
+	if err != nil {
+		d.failf(folder, "code-signing", err, "Failed to create code signing config: %s", err.Error())
+		return "", err
+	}
+	arn := aws.ToString(created.CodeSigningConfig.CodeSigningConfigArn)
+	d.codeSigningConfigs.arns[profile] = arn
+	return arn, nil
+}
+
+// Returns true if the code signing config allows packages signed with the signing profile version.
+func allowsPublisher(csc lambdaTypes.CodeSigningConfig, profileVersionARN string) bool {
+	return csc.AllowedPublishers != nil && contains(csc.AllowedPublishers.SigningProfileVersionArns, profileVersionARN)
+}
@@ -0,0 +1,89 @@
+package deploy
+
+import (
+	"os"
+)
+
+// When to color output, see -color.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// ANSI escape codes of the colors output uses.
+const (
+	ansiReset   = "\033[0m"
+	ansiDim     = "\033[2m"
+	ansiRed     = "\033[31m"
+	ansiGreen   = "\033[32m"
+	ansiYellow  = "\033[33m"
+	ansiBlue    = "\033[34m"
+	ansiMagenta = "\033[35m"
+	ansiCyan    = "\033[36m"
+)
+
+// Whether to color output, set from -color.
+var useColor = false
+
+// Switches color on or off. In auto mode, colors output on a terminal unless NO_COLOR is set.
+func useColorMode(mode string) {
+	switch mode {
+	case colorAlways:
+		useColor = true
+	case colorNever:
+		useColor = false
+	default:
+		useColor = isTerminal(logOutput) && os.Getenv("NO_COLOR") == ""
+	}
+}
+
+// The color of each stage of the pipeline, so the overall progress of a run is easy to tell at a glance:
+// cyan while checking what to deploy, blue while building, magenta while uploading, yellow while signing,
+// and green while deploying to Lambda. Other steps are not colored.
+var stepColors = map[string]string{
+	"check":            ansiCyan,
+	"hash":             ansiCyan,
+	"policy":           ansiCyan,
+	"go":               ansiBlue,
+	"prebuild":         ansiBlue,
+	"build":            ansiBlue,
+	"debug":            ansiBlue,
+	"size":             ansiBlue,
+	"zip":              ansiBlue,
+	"upload":           ansiMagenta,
+	"download":         ansiMagenta,
+	"sign":             ansiYellow,
+	"verify-signature": ansiYellow,
+	"copy":             ansiYellow,
+	"code-signing":     ansiGreen,
+	"configure":        ansiGreen,
+	"create":           ansiGreen,
+	"update":           ansiGreen,
+	"publish":          ansiGreen,
+	"alias":            ansiGreen,
+	"canary":           ansiGreen,
+	"verify":           ansiGreen,
+	"provision":        ansiGreen,
+	"postdeploy":       ansiGreen,
+}
+
+// Returns s in the color, or as is with -color=never or when color is off.
+func colorize(color, s string) string {
+	if !useColor || color == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// Returns the color of a line of output of the step with the status:
+// red when the step failed, dim when it was skipped, and the color of the step otherwise.
+func lineColor(step, status string) string {
+	switch status {
+	case statusFailed:
+		return ansiRed
+	case statusSkipped:
+		return ansiDim
+	}
+	return stepColors[step]
+}
@@ -0,0 +1,76 @@
+package deploy
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// Subcommands, e.g. builder teardown-preview -preview=1234.
+// Each subcommand shares the flags of the main command.
+var commands = map[string]func() error{
+	"teardown-preview": teardownPreview,
+	"gc":               gc,
+	"tail-run":         tailRun,
+	"adopt":            adopt,
+	"artifact-diff":    artifactDiff,
+	"rollback":         rollback,
+	"history":          history,
+	"clean-unsigned":   cleanUnsigned,
+	"clean":            clean,
+	"run-one":          runOne,
+	"promote":          promote,
+	"verify-lock":      verifyLock,
+	"release":          release,
+	"inspect":          inspect,
+	"watch":            watch,
+	"invoke":           invoke,
+	"graph":            graph,
+	"version":          version,
+	"bootstrap":        bootstrap,
+	"verify":           verify,
+}
+
+// Subcommands whose output is meant to be piped, e.g. builder graph | dot -Tsvg.
+// Everything else they print goes to stderr.
+var pipedCommands = map[string]bool{
+	"bootstrap": true,
+	"graph":     true,
+	"invoke":    true,
+	"run-one":   true,
+	"version":   true,
+}
+
+// Subcommands that take an argument before their flags, e.g. builder run-one testLambda01 -alias=TEST.
+var argumentCommands = map[string]bool{
+	"run-one": true,
+}
+
+// The argument of the subcommand, see argumentCommands.
+var commandArg string
+
+func runCommand(name string, args []string) {
+	if pipedCommands[name] {
+		logOutput = os.Stderr
+	}
+	command, ok := commands[name]
+	if !ok {
+		names := []string{}
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		printf("Commands: %s.\n", strings.Join(names, ", "))
+		exit(configErrorf(`command "%s" does not exist`, name))
+	}
+	if argumentCommands[name] && len(args) != 0 && !strings.HasPrefix(args[0], "-") {
+		commandArg, args = args[0], args[1:]
+	}
+	err := parseFlags(args)
+	if err != nil {
+		exit(configError{err})
+	}
+	err = command()
+	printf("\nTook %s.\n\n", formatDuration(runMetrics.elapsed()))
+	exit(err)
+}
@@ -0,0 +1,187 @@
+package deploy
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"runtime"
+)
+
+// How much of an executable each goroutine compresses with -zip-parallel.
+// Smaller chunks compress worse, since each chunk starts without the history of the ones before it.
+const zipChunkSize = 1 << 20
+
+// The -zip-level of each -zip-compression.
+// Storing is fastest for large executables that are signed anyway, best compression makes the smallest packages,
+// which Lambda downloads faster on cold starts.
+var zipCompressionLevels = map[string]int{
+	"store":   flate.NoCompression,
+	"fastest": flate.BestSpeed,
+	"default": flate.DefaultCompression,
+	"best":    flate.BestCompression,
+}
+
+// Sets -zip-level from -zip-compression, if it is set.
+// Returns an error if -zip-level is set too, on the command line or in the config file, even to the same level.
+func useZipCompression() error {
+	if *zipCompressionFlag == "" {
+		return nil
+	}
+	level, ok := zipCompressionLevels[*zipCompressionFlag]
+	if !ok {
+		return fmt.Errorf(`invalid zip compression "%s": expected store, fastest, default, or best`, *zipCompressionFlag)
+	}
+	levelSet := false
+	commandLine.Visit(func(f *flag.Flag) {
+		levelSet = levelSet || f.Name == "zip-level"
+	})
+	if levelSet {
+		return errors.New(`flags "zip-compression" and "zip-level" both set the zip level: pass only one of them`)
+	}
+	*zipLevelFlag = level
+	return nil
+}
+
+// How deployment packages are compressed, from -zip-level and -zip-parallel.
+type zipCompression struct {
+	level    int
+	parallel bool
+}
+
+// Returns the compression of the flags.
+func zipCompressionFlags() zipCompression {
+	return zipCompression{level: *zipLevelFlag, parallel: *zipParallelFlag}
+}
+
+// Returns the method of zip entries at the level, level 0 stores entries without compressing them.
+func (c zipCompression) method() uint16 {
+	if c.level == flate.NoCompression {
+		return zip.Store
+	}
+	return zip.Deflate
+}
+
+// Returns a description of the level for output, e.g. deflate level 9.
+func (c zipCompression) name() string {
+	switch c.level {
+	case flate.NoCompression:
+		return "store"
+	case flate.DefaultCompression:
+		return "deflate default level"
+	}
+	return fmt.Sprintf("deflate level %d", c.level)
+}
+
+// Makes w compress deflated entries at the level, in parallel if parallel is set.
+func (c zipCompression) register(w *zip.Writer) {
+	w.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		if c.parallel {
+			return newParallelDeflater(out, c.level), nil
+		}
+		return flate.NewWriter(out, c.level)
+	})
+}
+
+// Returns the header of a zip entry that only depends on its name, mode, and the compression.
+func (c zipCompression) header(name string, mode fs.FileMode) *zip.FileHeader {
+	fh := &zip.FileHeader{Name: name, Method: c.method(), Modified: zipTime}
+	fh.SetMode(mode)
+	return fh
+}
+
+// Deflates chunks of its input on every CPU and writes them out in order.
+// Every chunk but the last ends with a sync flush, which ends on a byte boundary without ending the stream,
+// so the chunks join into one deflate stream that any unzip reads, the same way pigz does.
+// The output only depends on the input and the level, not on the number of CPUs.
+type parallelDeflater struct {
+	out   io.Writer
+	level int
+	buf   []byte
+	// the compressed chunks, in the order they are written out
+	chunks chan chan deflatedChunk
+	done   chan error
+	// limits how many chunks are compressed at once
+	sem chan struct{}
+}
+
+type deflatedChunk struct {
+	b   []byte
+	err error
+}
+
+func newParallelDeflater(out io.Writer, level int) *parallelDeflater {
+	p := &parallelDeflater{
+		out:    out,
+		level:  level,
+		chunks: make(chan chan deflatedChunk, runtime.NumCPU()),
+		done:   make(chan error, 1),
+		sem:    make(chan struct{}, runtime.NumCPU()),
+	}
+	go p.writeChunks()
+	return p
+}
+
+func (p *parallelDeflater) Write(b []byte) (int, error) {
+	n := len(b)
+	for len(b) > 0 {
+		take := zipChunkSize - len(p.buf)
+		if take > len(b) {
+			take = len(b)
+		}
+		p.buf = append(p.buf, b[:take]...)
+		b = b[take:]
+		if len(p.buf) == zipChunkSize {
+			p.compress(p.buf, false)
+			p.buf = nil
+		}
+	}
+	return n, nil
+}
+
+// Compresses the rest of the input as the final chunk and waits for every chunk to be written out.
+func (p *parallelDeflater) Close() error {
+	p.compress(p.buf, true)
+	p.buf = nil
+	close(p.chunks)
+	return <-p.done
+}
+
+func (p *parallelDeflater) compress(chunk []byte, last bool) {
+	result := make(chan deflatedChunk, 1)
+	p.chunks <- result
+	p.sem <- struct{}{}
+	go func() {
+		defer func() { <-p.sem }()
+		buf := &bytes.Buffer{}
+		w, err := flate.NewWriter(buf, p.level)
+		if err == nil {
+			_, err = w.Write(chunk)
+		}
+		if err == nil && last {
+			err = w.Close()
+		} else if err == nil {
+			err = w.Flush()
+		}
+		result <- deflatedChunk{buf.Bytes(), err}
+	}()
+}
+
+func (p *parallelDeflater) writeChunks() {
+	var err error
+	for result := range p.chunks {
+		chunk := <-result
+		if err != nil {
+			continue
+		}
+		err = chunk.err
+		if err == nil {
+			_, err = p.out.Write(chunk.b)
+		}
+	}
+	p.done <- err
+}
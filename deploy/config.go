@@ -0,0 +1,334 @@
+package deploy
+
+import (
+	"compress/flate"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Options that can be set for a single folder in the config file.
+//
+//	folder "testLambda01" {
+//	  function        = "prod-testLambda01"
+//	  signing-profile = "other"
+//	  alias           = "LIVE"
+//	  arch            = "arm64"
+//	}
+//
+// Folders with targets are tools, see toolTargets.
+// Folders with layers use layers from the layers directory, see functionLayers.
+// Folders that depend on other folders deploy after them, see deployLevels.
+// Folders in the same unit update their aliases together, see releaseUnits.
+// Folders with a matrix are built once for each of its functions, see matrixConfig.
+// Folders with an artifact deploy it instead of building, see folderArtifacts.
+// Folders with a role are created if their function does not exist, see createFunction.
+// Folders with settings update them after their code, see updateConfiguration.
+// Folders with provisioned concurrency provision it for each new version, see provisionConcurrency.
+type folderConfig struct {
+	Name           string            `hcl:"name,label"`
+	SigningProfile string            `hcl:"signing-profile,optional"`
+	Alias          string            `hcl:"alias,optional"`
+	Arch           string            `hcl:"arch,optional"`
+	Targets        []string          `hcl:"targets,optional"`
+	Layers         []string          `hcl:"layers,optional"`
+	DependsOn      []string          `hcl:"depends-on,optional"`
+	Unit           string            `hcl:"unit,optional"`
+	Function       string            `hcl:"function,optional"`
+	Artifact       string            `hcl:"artifact,optional"`
+	Role           string            `hcl:"role,optional"`
+	Runtime        string            `hcl:"runtime,optional"`
+	Memory         int               `hcl:"memory,optional"`
+	Timeout        int               `hcl:"timeout,optional"`
+	Environment    map[string]string `hcl:"environment,optional"`
+	Handler        string            `hcl:"handler,optional"`
+	Tracing        string            `hcl:"tracing,optional"`
+	// commands to run in the folder before it is built and after it is deployed, see runPrebuildHook
+	Prebuild   string `hcl:"prebuild,optional"`
+	Postdeploy string `hcl:"postdeploy,optional"`
+	// files to zip alongside the executable
+	Assets []assetConfig `hcl:"asset,block"`
+	// execution environments to provision for each new version, see provisionConcurrency
+	ProvisionedConcurrency int            `hcl:"provisioned-concurrency,optional"`
+	Matrix                 []matrixConfig `hcl:"matrix,block"`
+}
+
+// Per-folder options read from the config file, keyed by folder.
+var folderConfigs = map[string]folderConfig{}
+
+// Only describes the blocks, the attributes are checked against the flags.
+var configSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "folder", LabelNames: []string{"name"}},
+		{Type: "environment", LabelNames: []string{"name"}},
+		{Type: "tags"},
+	},
+}
+
+// Parses the flags, then reads the config file.
+// Flags passed on the command line take precedence over the config file.
+func parseFlags(args []string) error {
+	err := commandLine.Parse(args)
+	if err != nil {
+		return err
+	}
+	usePorcelain()
+	err = loadConfigFile()
+	if err != nil {
+		return err
+	}
+	usePorcelain()
+	if *logModeFlag != logModeStream && *logModeFlag != logModeGrouped && *logModeFlag != logModeLive {
+		return fmt.Errorf(`invalid log mode "%s": expected %s, %s, or %s`, *logModeFlag, logModeStream, logModeGrouped, logModeLive)
+	}
+	useLogMode(*logModeFlag)
+	if *colorFlag != colorAuto && *colorFlag != colorAlways && *colorFlag != colorNever {
+		return fmt.Errorf(`invalid color "%s": expected %s, %s, or %s`, *colorFlag, colorAuto, colorAlways, colorNever)
+	}
+	useColorMode(*colorFlag)
+	err = checkRequiredVersion()
+	if err != nil {
+		return err
+	}
+	if *logFormatFlag != "text" && *logFormatFlag != "json" {
+		return fmt.Errorf(`invalid log format "%s": expected text or json`, *logFormatFlag)
+	}
+	logJSON = *logFormatFlag == "json"
+	if *signingTimeoutFlag <= 0 || *updateTimeoutFlag <= 0 || *publishTimeoutFlag <= 0 {
+		return errors.New("invalid waiter timeout: signing, update, and publish timeouts must be positive")
+	}
+	if *waiterMinDelayFlag <= 0 || *waiterMaxDelayFlag < *waiterMinDelayFlag {
+		return fmt.Errorf("invalid waiter delays %s and %s: the min delay must be positive and at most the max delay", *waiterMinDelayFlag, *waiterMaxDelayFlag)
+	}
+	if *codeSigningPolicyFlag != string(lambdaTypes.CodeSigningPolicyEnforce) && *codeSigningPolicyFlag != string(lambdaTypes.CodeSigningPolicyWarn) {
+		return fmt.Errorf(`invalid code signing policy "%s": expected Enforce or Warn`, *codeSigningPolicyFlag)
+	}
+	err = useZipCompression()
+	if err != nil {
+		return err
+	}
+	if *zipLevelFlag < flate.DefaultCompression || *zipLevelFlag > flate.BestCompression {
+		return fmt.Errorf("invalid zip level %d: expected -1 for the default, 0 to store, or 1 to 9", *zipLevelFlag)
+	}
+	if *orderFlag != orderSlowestFirst && *orderFlag != orderName {
+		return fmt.Errorf(`invalid order "%s": expected %s or %s`, *orderFlag, orderSlowestFirst, orderName)
+	}
+	if *bootstrapFormatFlag != bootstrapCloudFormation && *bootstrapFormatFlag != bootstrapTerraform {
+		return fmt.Errorf(`invalid bootstrap format "%s": expected %s or %s`, *bootstrapFormatFlag, bootstrapCloudFormation, bootstrapTerraform)
+	}
+	if *graphFormatFlag != "dot" && *graphFormatFlag != "mermaid" {
+		return fmt.Errorf(`invalid graph format "%s": expected dot or mermaid`, *graphFormatFlag)
+	}
+	if *uploadPartSizeFlag*1024*1024 < manager.MinUploadPartSize {
+		return fmt.Errorf("invalid upload part size %d: S3 parts must be at least 5 MiB", *uploadPartSizeFlag)
+	}
+	if *maxAttemptsFlag < 1 {
+		return fmt.Errorf("invalid max attempts %d: must be at least 1", *maxAttemptsFlag)
+	}
+	if *uploadConcurrencyFlag < 1 {
+		return fmt.Errorf("invalid upload concurrency %d: must be at least 1", *uploadConcurrencyFlag)
+	}
+	if *endpointURLFlag != "" {
+		err = checkEndpoint(*endpointURLFlag)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = parseEndpoints(*endpointURLsFlag)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Reads the config file, if there is one.
+func loadConfigFile() error {
+	path := *configFlag
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		path = filepath.Join(home, ".config", "go-lambda-builder", "config.hcl")
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+	}
+	printf("Reading options from %s.\n\n", path)
+	return loadConfig(path)
+}
+
+// Reads the config file at path.
+// Every top-level attribute sets the flag of the same name, unless that flag was passed on the command line.
+//
+//	bucket          = "kesav-go-lambda-builder-test"
+//	unsigned-prefix = "test/unsigned"
+//	include         = ["testLambda*"]
+//	env             = ["GOPRIVATE=github.com/kesav21/*"]
+//	force           = true
+func loadConfig(path string) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	file, diags := hclsyntax.ParseConfig(src, path, hcl.InitialPos)
+	if diags.HasErrors() {
+		return diags
+	}
+	content, _, diags := file.Body.PartialContent(configSchema)
+	if diags.HasErrors() {
+		return diags
+	}
+	passed := map[string]bool{}
+	commandLine.Visit(func(f *flag.Flag) {
+		passed[f.Name] = true
+	})
+	for name, attr := range file.Body.(*hclsyntax.Body).Attributes {
+		f := commandLine.Lookup(name)
+		if f == nil || name == "config" {
+			return fmt.Errorf(`%s: "%s" is not a flag`, attr.NameRange, name)
+		}
+		if passed[name] {
+			continue
+		}
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return diags
+		}
+		err := setFlag(f, value)
+		if err != nil {
+			return fmt.Errorf(`%s: invalid value for "%s": %w`, attr.NameRange, name, err)
+		}
+	}
+	for _, block := range content.Blocks {
+		switch block.Type {
+		case "folder":
+			fc := folderConfig{}
+			diags := gohcl.DecodeBody(block.Body, nil, &fc)
+			if diags.HasErrors() {
+				return diags
+			}
+			fc.Name = block.Labels[0]
+			folderConfigs[fc.Name] = fc
+			err := addMatrixBuilds(fc)
+			if err != nil {
+				return err
+			}
+		case "environment":
+			ec := environmentConfig{}
+			diags := gohcl.DecodeBody(block.Body, nil, &ec)
+			if diags.HasErrors() {
+				return diags
+			}
+			ec.Name = block.Labels[0]
+			environmentConfigs[ec.Name] = ec
+		case "tags":
+			attrs, diags := block.Body.JustAttributes()
+			if diags.HasErrors() {
+				return diags
+			}
+			for key, attr := range attrs {
+				value, diags := attr.Expr.Value(nil)
+				if diags.HasErrors() {
+					return diags
+				}
+				s, err := ctyString(value)
+				if err != nil {
+					return fmt.Errorf(`%s: invalid value for tag "%s": %w`, attr.NameRange, key, err)
+				}
+				defaultTags[key] = s
+			}
+		}
+	}
+	return nil
+}
+
+// Adds the options of each folder block to the overrides.
+// Overrides passed as flags take precedence over folder blocks.
+func mergeFolderConfigs(archOverrides, aliasOverrides, signingProfileOverrides map[string]string) error {
+	for folder, fc := range folderConfigs {
+		if _, ok := archOverrides[folder]; !ok && fc.Arch != "" {
+			err := validateArch(fc.Arch)
+			if err != nil {
+				return fmt.Errorf(`folder "%s": %w`, folder, err)
+			}
+			archOverrides[folder] = fc.Arch
+		}
+		if fc.Tracing != "" && fc.Tracing != string(lambdaTypes.TracingModeActive) && fc.Tracing != string(lambdaTypes.TracingModePassThrough) {
+			return fmt.Errorf(`folder "%s": invalid tracing "%s": expected Active or PassThrough`, folder, fc.Tracing)
+		}
+		if fc.ProvisionedConcurrency < 0 {
+			return fmt.Errorf(`folder "%s": invalid provisioned concurrency %d: must not be negative`, folder, fc.ProvisionedConcurrency)
+		}
+		if _, ok := aliasOverrides[folder]; !ok && fc.Alias != "" {
+			aliasOverrides[folder] = fc.Alias
+		}
+		if fc.SigningProfile != "" {
+			signingProfileOverrides[folder] = fc.SigningProfile
+		}
+		for _, a := range fc.Assets {
+			err := a.validate()
+			if err != nil {
+				return fmt.Errorf(`folder "%s": %w`, folder, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Sets the flag from a config file value.
+// Lists set repeatable flags once per element, and other flags to the comma-separated elements.
+func setFlag(f *flag.Flag, value cty.Value) error {
+	if !value.Type().IsListType() && !value.Type().IsTupleType() {
+		s, err := ctyString(value)
+		if err != nil {
+			return err
+		}
+		return commandLine.Set(f.Name, s)
+	}
+	strs := []string{}
+	for it := value.ElementIterator(); it.Next(); {
+		_, element := it.Element()
+		s, err := ctyString(element)
+		if err != nil {
+			return err
+		}
+		strs = append(strs, s)
+	}
+	if _, ok := f.Value.(*listFlag); ok {
+		for _, s := range strs {
+			err := commandLine.Set(f.Name, s)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return commandLine.Set(f.Name, strings.Join(strs, ","))
+}
+
+func ctyString(value cty.Value) (string, error) {
+	if value.IsNull() || !value.IsKnown() {
+		return "", errors.New("value must be known and not null")
+	}
+	switch value.Type() {
+	case cty.String:
+		return value.AsString(), nil
+	case cty.Number:
+		return value.AsBigFloat().Text('f', -1), nil
+	case cty.Bool:
+		return strconv.FormatBool(value.True()), nil
+	}
+	return "", fmt.Errorf("%s is not a string, number, or bool", value.Type().FriendlyName())
+}
@@ -0,0 +1,87 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// The runtime of functions created with -create-missing, unless the folder block sets one.
+const defaultRuntime = "provided.al2023"
+
+// Creates the function of the folder with -create-missing, from its folder block.
+//
+//	folder "testLambda01" {
+//	  role        = "arn:aws:iam::123456789012:role/testLambda01"
+//	  runtime     = "provided.al2023"
+//	  memory      = 256
+//	  timeout     = 30
+//	  environment = { "TABLE" = "orders" }
+//	}
+//
+// Only the role is required. Every other setting defaults to Lambda's defaults.
+func (d *data) createFunction(folder, signedKey, arch string, tags map[string]string) error {
+	fc := d.configs[folder]
+	if fc.Role == "" {
+		err := fmt.Errorf(`function %s does not exist, and folder "%s" has no role to create it with`, d.functionName(folder), folder)
+		d.failf(folder, "create", err, "Failed to create Lambda function: %s.", err.Error())
+		return err
+	}
+	d.logf(folder, "create", "Creating Lambda function.")
+	input := &lambda.CreateFunctionInput{
+		FunctionName: aws.String(d.functionName(folder)),
+		Role:         aws.String(fc.Role),
+		Runtime:      lambdaTypes.Runtime(orDefault(fc.Runtime, defaultRuntime)),
+		Handler:      aws.String(d.handlerFor(folder)),
+		Code: &lambdaTypes.FunctionCode{
+			S3Bucket: aws.String(d.signedBucket),
+			S3Key:    aws.String(signedKey),
+		},
+		Architectures: []lambdaTypes.Architecture{architectures[arch]},
+		Tags:          tags,
+	}
+	if fc.Memory != 0 {
+		input.MemorySize = aws.Int32(int32(fc.Memory))
+	}
+	if fc.Timeout != 0 {
+		input.Timeout = aws.Int32(int32(fc.Timeout))
+	}
+	if len(fc.Environment) != 0 {
+		input.Environment = &lambdaTypes.Environment{Variables: fc.Environment}
+	}
+	if profile := d.signingProfileFor(folder); profile != "" && d.codeSigningConfig {
+		arn, err := d.codeSigningConfigFor(folder, profile)
+		if err != nil {
+			return err
+		}
+		input.CodeSigningConfigArn = aws.String(arn)
+	}
+	_, err := d.lambda.CreateFunction(d.ctx, input)
+	if err != nil {
+		d.failf(folder, "create", err, "Failed to create Lambda function: %s", err.Error())
+		return err
+	}
+	// new functions are pending until Lambda has set them up, and cannot be published until then
+	err = lambda.NewFunctionActiveV2Waiter(d.lambda, func(o *lambda.FunctionActiveV2WaiterOptions) {
+		o.MinDelay = d.waiterMinDelay
+		o.MaxDelay = d.waiterMaxDelay
+	}).Wait(d.ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(d.functionName(folder)),
+	}, 5*time.Minute)
+	if err != nil {
+		d.failf(folder, "create", err, "Failed to wait for Lambda function to be active: %s", err.Error())
+		return err
+	}
+	d.donef(folder, "create", "Created Lambda function.")
+	return nil
+}
+
+// Returns true if the error is from a function that does not exist.
+func isFunctionNotFound(err error) bool {
+	var notFound *lambdaTypes.ResourceNotFoundException
+	return errors.As(err, &notFound)
+}
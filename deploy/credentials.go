@@ -0,0 +1,67 @@
+package deploy
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// How long before they expire credentials are refreshed, so no call signs with credentials about to expire.
+const credentialsExpiryWindow = 5 * time.Minute
+
+// How long a folder is expected to take, to estimate how long a run takes without -expected-run-time.
+const expectedFolderTime = 2 * time.Minute
+
+// The credentials the config was loaded with, before assuming a role.
+// Assumed roles are refreshed with these, so these are the credentials that must outlive the run.
+var sourceCredentials aws.CredentialsProvider
+
+// Refreshes cached credentials credentialsExpiryWindow before they expire.
+func refreshEarly(o *aws.CredentialsCacheOptions) {
+	o.ExpiryWindow = credentialsExpiryWindow
+}
+
+// Returns how long the run is expected to take, from -expected-run-time
+// or from deploying the folders concurrency at a time.
+func expectedRunTime(folders, concurrency int) time.Duration {
+	if *expectedRunTimeFlag != 0 {
+		return *expectedRunTimeFlag
+	}
+	if concurrency <= 0 || concurrency > folders {
+		concurrency = folders
+	}
+	if concurrency == 0 {
+		return 0
+	}
+	batches := (folders + concurrency - 1) / concurrency
+	return time.Duration(batches) * expectedFolderTime
+}
+
+// Warns if the source credentials expire before the run is expected to finish,
+// since the last folders would then fail with ExpiredToken.
+// Never fails the run, the estimate is only a guess.
+func checkCredentialLifetime(ctx context.Context, expected time.Duration) {
+	if sourceCredentials == nil || expected == 0 {
+		return
+	}
+	creds, err := sourceCredentials.Retrieve(ctx)
+	if err != nil {
+		printf("Failed to check when credentials expire: %s.\n\n", err.Error())
+		return
+	}
+	if !creds.CanExpire {
+		return
+	}
+	left := time.Until(creds.Expires)
+	if left >= expected {
+		return
+	}
+	printf(
+		"Warning: credentials from %s expire in %s, but the run is expected to take %s. "+
+			"Refresh them or pass -expected-run-time if the estimate is wrong.\n\n",
+		creds.Source,
+		left.Round(time.Second),
+		expected,
+	)
+}
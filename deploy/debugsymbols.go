@@ -0,0 +1,58 @@
+package deploy
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Tags each function with where the debug symbols of its code are.
+const debugSymbolsTag = "debug-symbols"
+
+// Returns where the debug symbols of the signed deployment package go, e.g. debug/testLambda01/1a2b3c....
+// Signed hashes are base64, which can contain slashes, so the key uses hex.
+func debugSymbolsKey(prefix, folder, signedHash string) (string, error) {
+	hash, err := base64.StdEncoding.DecodeString(signedHash)
+	if err != nil {
+		return "", err
+	}
+	return objectKey(prefix, folder, hex.EncodeToString(hash)), nil
+}
+
+// Uploads the executable with debug symbols next to the signed deployment package it was built with,
+// and returns its location to tag the function with.
+func (d *data) uploadDebugSymbols(folder, debugPath, signedHash string) (string, error) {
+	if err := d.refuseInReadOnly(folder, "uploading debug symbols"); err != nil {
+		return "", err
+	}
+	key, err := debugSymbolsKey(d.debugSymbolsPrefix, folder, signedHash)
+	if err != nil {
+		d.failf(folder, "debug", err, "Failed to get key of debug symbols: %s.", err.Error())
+		return "", err
+	}
+	f, err := os.Open(debugPath)
+	if err != nil {
+		d.failf(folder, "debug", err, "Failed to open debug symbols: %s.", err.Error())
+		return "", err
+	}
+	defer f.Close()
+	d.logf(folder, "debug", "Uploading debug symbols to s3://%s/%s.", d.signedBucket, key)
+	_, err = d.uploader.Upload(d.ctx, &s3.PutObjectInput{
+		Bucket:   aws.String(d.signedBucket),
+		Key:      aws.String(key),
+		Body:     f,
+		Metadata: map[string]string{"signedHash": signedHash},
+		Tagging:  objectTagging(d.tags),
+	})
+	if err != nil {
+		d.failf(folder, "debug", err, "Failed to upload debug symbols: %s", err.Error())
+		return "", err
+	}
+	location := fmt.Sprintf("s3://%s/%s", d.signedBucket, key)
+	d.donef(folder, "debug", "Uploaded debug symbols to %s.", location)
+	return location, nil
+}
@@ -0,0 +1,97 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+)
+
+// A package as printed by go list -json, with only the fields that affect the executable.
+type listedPackage struct {
+	Dir        string
+	ImportPath string
+	Standard   bool
+	Module     *listedModule
+	GoFiles    []string
+	CgoFiles   []string
+	CFiles     []string
+	HFiles     []string
+	SFiles     []string
+	EmbedFiles []string
+}
+
+type listedModule struct {
+	Path    string
+	Version string
+	Replace *listedModule
+}
+
+// Returns the files of every package the folder's executable is built from that is not in the module cache,
+// e.g. the folder itself, shared internal packages, and modules replaced with local directories,
+// and the versions of the modules that are in the module cache, which never change.
+//
+// The standard library is left out, since the Go version is already part of the executable's build info.
+func (d *data) dependencies(folder string) ([]string, []string, error) {
+	args := []string{"list", "-deps", "-json"}
+	// build tags decide which files are part of the executable
+	if tags := buildTags(folder); tags != "" {
+		args = append(args, "-tags="+tags)
+	}
+	cmd := exec.Command("go", append(args, ".")...)
+	cmd.Dir = sourceFolder(folder)
+	cmd.Env = append([]string{}, d.env...)
+	cmd.Env = append(cmd.Env, "GOOS=linux")
+	cmd.Env = append(cmd.Env, "GOARCH="+d.archFor(folder))
+	cmd.Env = append(cmd.Env, "CGO_ENABLED=0")
+	// go list downloads missing modules like go build does
+	cmd.Env = append(cmd.Env, d.gitEnv...)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	if err != nil {
+		return nil, nil, fmt.Errorf("go list: %w: %s", err, scrub(stderr.String(), d.secrets))
+	}
+	files := []string{}
+	modules := map[string]bool{}
+	decoder := json.NewDecoder(stdout)
+	for {
+		p := listedPackage{}
+		err := decoder.Decode(&p)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if p.Standard {
+			continue
+		}
+		if m := p.Module; m != nil {
+			if m.Replace != nil {
+				m = m.Replace
+			}
+			if m.Version != "" {
+				modules[m.Path+"@"+m.Version] = true
+				continue
+			}
+		}
+		for _, names := range [][]string{p.GoFiles, p.CgoFiles, p.CFiles, p.HFiles, p.SFiles, p.EmbedFiles} {
+			for _, name := range names {
+				files = append(files, filepath.Join(p.Dir, name))
+			}
+		}
+	}
+	versions := []string{}
+	for module := range modules {
+		versions = append(versions, module)
+	}
+	sort.Strings(files)
+	sort.Strings(versions)
+	return files, versions, nil
+}
@@ -0,0 +1,77 @@
+package deploy
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Sends AWS API calls to -endpoint-url, or the -endpoint-urls of their service, instead of AWS,
+// e.g. to run the whole pipeline against LocalStack or minio. Services without a custom endpoint go to AWS.
+func useCustomEndpoints(cfg *aws.Config) error {
+	endpoints, err := parseEndpoints(*endpointURLsFlag)
+	if err != nil {
+		return err
+	}
+	if *endpointURLFlag == "" && len(endpoints) == 0 {
+		return nil
+	}
+	cfg.EndpointResolverWithOptions = aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+		endpoint, ok := endpoints[serviceKey(service)]
+		if !ok {
+			endpoint = *endpointURLFlag
+		}
+		if endpoint == "" {
+			// falls back to the endpoint of the service in AWS
+			return aws.Endpoint{}, &aws.EndpointNotFoundError{}
+		}
+		return aws.Endpoint{
+			URL:           endpoint,
+			SigningRegion: region,
+			Source:        aws.EndpointSourceCustom,
+		}, nil
+	})
+	return nil
+}
+
+// Parses comma-separated service=url pairs, e.g. s3=http://localhost:9000,lambda=http://localhost:4566.
+// Services are named as in the SDK, in any case, e.g. s3, signer, lambda, dynamodb, sts.
+func parseEndpoints(s string) (map[string]string, error) {
+	endpoints := map[string]string{}
+	for _, pair := range splitList(s) {
+		service, endpoint, ok := strings.Cut(pair, "=")
+		if !ok || service == "" || endpoint == "" {
+			return nil, fmt.Errorf(`endpoint "%s" is not of the form service=url`, pair)
+		}
+		err := checkEndpoint(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		endpoints[serviceKey(service)] = endpoint
+	}
+	return endpoints, nil
+}
+
+func checkEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf(`invalid endpoint URL "%s": expected e.g. http://localhost:4566`, endpoint)
+	}
+	return nil
+}
+
+// Returns the service ID of the SDK, e.g. "CloudWatch" or "Lambda", as it is written in -endpoint-urls.
+func serviceKey(service string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(service), " ", ""))
+}
+
+// Returns an S3 client that addresses buckets in the path with -s3-path-style, e.g. http://localhost:9000/bucket/key,
+// as minio and LocalStack without DNS for bucket subdomains need.
+func newS3Client(cfg aws.Config) *s3.Client {
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.UsePathStyle = *s3PathStyleFlag
+	})
+}
@@ -0,0 +1,83 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Variables that go build needs to find the toolchain and its caches.
+// These are always passed through when an allowlist is given, unless they are denied.
+var baseEnv = []string{
+	"PATH",
+	"HOME",
+	"TMPDIR",
+	"GOROOT",
+	"GOPATH",
+	"GOCACHE",
+	"GOMODCACHE",
+}
+
+// Returns the environment to pass to go build.
+//
+// If allow is empty, every variable in environ is passed through.
+// If allow is not empty, only the variables in baseEnv and the variables matching allow are passed through.
+// Variables matching deny are never passed through.
+// Variables in extra are appended last so they take precedence over environ.
+// Patterns are matched with path.Match, e.g. AWS_*.
+func buildEnv(environ, allow, deny, extra []string) ([]string, error) {
+	for _, pattern := range append(append([]string{}, allow...), deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid environment variable pattern %q: %w", pattern, err)
+		}
+	}
+	env := []string{}
+	for _, kv := range environ {
+		key, _, _ := strings.Cut(kv, "=")
+		if matchesAny(deny, key) {
+			continue
+		}
+		if len(allow) != 0 && !contains(baseEnv, key) && !matchesAny(allow, key) {
+			continue
+		}
+		env = append(env, kv)
+	}
+	for _, kv := range extra {
+		if !strings.Contains(kv, "=") {
+			return nil, fmt.Errorf("invalid environment variable %q: expected KEY=VALUE", kv)
+		}
+		env = append(env, kv)
+	}
+	return env, nil
+}
+
+// Returns the environment to run go build with, from the host environment and the env flags.
+func goBuildEnv() ([]string, error) {
+	extra := append([]string{}, envFlag...)
+	if *goprivateFlag != "" {
+		extra = append(extra, "GOPRIVATE="+*goprivateFlag)
+	}
+	return buildEnv(os.Environ(), splitList(*envAllowFlag), splitList(*envDenyFlag), extra)
+}
+
+// Returns the names of the variables in env, without their values.
+func envKeys(env []string) []string {
+	keys := make([]string, 0, len(env))
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Returns true if any of the patterns match the string.
+// Expects the patterns to be valid.
+func matchesAny(patterns []string, str string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, str); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package deploy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildEnv(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin",
+		"HOME=/home/kesav",
+		"GOCACHE=/cache",
+		"AWS_REGION=us-east-1",
+		"AWS_SECRET_ACCESS_KEY=secret",
+		"GITHUB_TOKEN=token",
+		"CGO_ENABLED=1",
+	}
+	tests := []struct {
+		name  string
+		allow []string
+		deny  []string
+		extra []string
+		want  []string
+	}{
+		{
+			name: "everything",
+			want: environ,
+		},
+		{
+			name:  "allow keeps base variables",
+			allow: []string{"CGO_ENABLED"},
+			want:  []string{"PATH=/usr/bin", "HOME=/home/kesav", "GOCACHE=/cache", "CGO_ENABLED=1"},
+		},
+		{
+			name:  "allow globs",
+			allow: []string{"AWS_*"},
+			want:  []string{"PATH=/usr/bin", "HOME=/home/kesav", "GOCACHE=/cache", "AWS_REGION=us-east-1", "AWS_SECRET_ACCESS_KEY=secret"},
+		},
+		{
+			name: "deny",
+			deny: []string{"AWS_SECRET_*", "GITHUB_TOKEN"},
+			want: []string{"PATH=/usr/bin", "HOME=/home/kesav", "GOCACHE=/cache", "AWS_REGION=us-east-1", "CGO_ENABLED=1"},
+		},
+		{
+			name:  "deny wins over allow",
+			allow: []string{"AWS_*"},
+			deny:  []string{"AWS_SECRET_*"},
+			want:  []string{"PATH=/usr/bin", "HOME=/home/kesav", "GOCACHE=/cache", "AWS_REGION=us-east-1"},
+		},
+		{
+			name:  "deny removes base variables",
+			allow: []string{"CGO_ENABLED"},
+			deny:  []string{"HOME"},
+			want:  []string{"PATH=/usr/bin", "GOCACHE=/cache", "CGO_ENABLED=1"},
+		},
+		{
+			name:  "extra comes last, even if denied",
+			allow: []string{"CGO_ENABLED"},
+			deny:  []string{"GOPRIVATE", "CGO_ENABLED"},
+			extra: []string{"GOPRIVATE=github.com/kesav21/*", "CGO_ENABLED=0"},
+			want:  []string{"PATH=/usr/bin", "HOME=/home/kesav", "GOCACHE=/cache", "GOPRIVATE=github.com/kesav21/*", "CGO_ENABLED=0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildEnv(environ, tt.allow, tt.deny, tt.extra)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildEnvInvalid(t *testing.T) {
+	_, err := buildEnv(nil, []string{"["}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), `invalid environment variable pattern "["`) {
+		t.Errorf("invalid allow pattern: got error %v", err)
+	}
+	_, err = buildEnv(nil, nil, []string{"AWS_["}, nil)
+	if err == nil || !strings.Contains(err.Error(), `invalid environment variable pattern "AWS_["`) {
+		t.Errorf("invalid deny pattern: got error %v", err)
+	}
+	_, err = buildEnv(nil, nil, nil, []string{"GOFLAGS"})
+	if err == nil || !strings.Contains(err.Error(), "expected KEY=VALUE") {
+		t.Errorf("extra variable without a value: got error %v", err)
+	}
+}
@@ -0,0 +1,299 @@
+package deploy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// One line of output, as recorded in the event log of a run.
+type event struct {
+	Time   time.Time `json:"time"`
+	RunID  string    `json:"runId"`
+	Folder string    `json:"folder"`
+	Step   string    `json:"step"`
+	// only set when deploying to several regions
+	Region string `json:"region,omitempty"`
+	Status string `json:"status"`
+	// Seconds since the first event of the step.
+	Duration float64 `json:"duration"`
+	Error    string  `json:"error,omitempty"`
+	Message  string  `json:"message"`
+}
+
+// The step of the event that marks the end of a run.
+const stepDone = "done"
+
+// The status of a step, as of an event.
+// Every step is running until it is done, failed, or skipped.
+const (
+	statusRunning = "running"
+	statusDone    = "done"
+	statusFailed  = "failed"
+	statusSkipped = "skipped"
+)
+
+// Appends events to a file, one JSON object per line.
+// Safe to use from multiple goroutines.
+type eventLog struct {
+	mu    sync.Mutex
+	runID string
+	file  *os.File
+}
+
+// Returns the directory event logs are written to.
+func eventsDir() (string, error) {
+	if *eventsDirFlag != "" {
+		return *eventsDirFlag, nil
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cache, "go-lambda-builder", "runs"), nil
+}
+
+// Creates the event log of the run.
+func newEventLog(runID string) (*eventLog, error) {
+	dir, err := eventsDir()
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, runID+".jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &eventLog{runID: runID, file: file}, nil
+}
+
+func (l *eventLog) write(e event) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.RunID = l.runID
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	l.file.Write(append(b, '\n'))
+}
+
+// Marks the end of the run and closes the file.
+func (l *eventLog) close() {
+	if l == nil {
+		return
+	}
+	l.write(event{Time: time.Now().UTC(), Step: stepDone, Status: statusDone, Message: "Run is complete."})
+	l.file.Close()
+}
+
+// Prints a line of output for the folder and records it in the event log.
+// The step is running until one of donef, failf, or skipf is called for it.
+//
+//	d.logf(folder, "build", "Building executable.")
+func (d *data) logf(folder, step, format string, args ...interface{}) {
+	d.log(folder, step, statusRunning, nil, format, args...)
+}
+
+// Like logf, but marks the step as done.
+//
+//	d.donef(folder, "build", "Built executable.")
+func (d *data) donef(folder, step, format string, args ...interface{}) {
+	d.log(folder, step, statusDone, nil, format, args...)
+}
+
+// Like logf, but marks the step as failed with err.
+//
+//	d.failf(folder, "build", err, "Failed to build executable: %s.", err.Error())
+func (d *data) failf(folder, step string, err error, format string, args ...interface{}) {
+	d.log(folder, step, statusFailed, err, format, args...)
+}
+
+// Like logf, but marks the step as skipped.
+//
+//	d.skipf(folder, "upload", "Not uploading unsigned deployment package to S3.")
+func (d *data) skipf(folder, step, format string, args ...interface{}) {
+	d.log(folder, step, statusSkipped, nil, format, args...)
+}
+
+func (d *data) log(folder, step, status string, err error, format string, args ...interface{}) {
+	now := time.Now().UTC()
+	took := d.metrics.observe(folder, d.targetRegion, step, status, now)
+	e := event{
+		Time:     now,
+		RunID:    d.runID,
+		Folder:   folder,
+		Step:     step,
+		Status:   status,
+		Region:   d.targetRegion,
+		Duration: took.Seconds(),
+		Message:  fmt.Sprintf(format, args...),
+	}
+	if err != nil {
+		e.Error = scrub(err.Error(), d.secrets)
+	}
+	switch {
+	case d.quiet:
+	case logJSON:
+		b, err := json.Marshal(e)
+		if err == nil {
+			logOutput.Write(append(b, '\n'))
+		}
+	default:
+		message := e.Message
+		if status == statusDone || status == statusFailed {
+			message += " (" + formatDuration(took) + ")"
+		}
+		// failed and skipped lines are colored whole, other lines only by the color of their step
+		color := lineColor(step, status)
+		prefix := d.folderInRegion(folder)
+		line := colorize(color, prefix) + " | " + message
+		if status == statusFailed || status == statusSkipped {
+			line = colorize(color, prefix+" | "+message)
+		}
+		if !logFolderPrefix {
+			line = message
+			if status == statusFailed || status == statusSkipped {
+				line = colorize(color, message)
+			}
+		}
+		output.line(folder, line+"\n")
+	}
+	d.events.write(e)
+	d.sendStatus(e)
+	d.trace.observe(e)
+}
+
+// Whether lines of output start with their folder, builder run-one turns this off since it only deploys one.
+var logFolderPrefix = true
+
+// Whether to print events as JSON, one per line, set by -log-format.
+var logJSON = false
+
+// Where events and other output go.
+// Commands whose output is meant to be piped, e.g. builder graph, send them to stderr instead.
+var logOutput io.Writer = os.Stdout
+
+// Prints output that is not an event of a folder.
+// With -log-format=json, this goes to stderr so that stdout only has events.
+func printf(format string, args ...interface{}) {
+	if logJSON {
+		fmt.Fprintf(os.Stderr, format, args...)
+		return
+	}
+	output.print(fmt.Sprintf(format, args...))
+}
+
+// Prints the events of a run, optionally only for one folder or step.
+//
+//	builder tail-run -run-id=123 -folder=testLambda01 -step=sign -follow
+//
+// Prints the most recent run if no run ID is passed in.
+// With -follow, waits for new events until the run is complete.
+func tailRun() error {
+	dir, err := eventsDir()
+	if err != nil {
+		return err
+	}
+	runID := *runIDFlag
+	if runID == "" {
+		runID, err = latestRunID(dir)
+		if err != nil {
+			return err
+		}
+	}
+	file, err := os.Open(filepath.Join(dir, runID+".jsonl"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	printf("Showing run %s.\n\n", runID)
+	r := bufio.NewReader(file)
+	partial := ""
+	for {
+		line, err := r.ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			// the line is still being written
+			partial += line
+			if !*followFlag {
+				return nil
+			}
+			time.Sleep(500 * time.Millisecond)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		line = partial + line
+		partial = ""
+		e := event{}
+		err = json.Unmarshal([]byte(line), &e)
+		if err != nil {
+			return fmt.Errorf("invalid event in run %s: %w", runID, err)
+		}
+		if e.Step == stepDone && e.Folder == "" {
+			return nil
+		}
+		if *folderFlag != "" && e.Folder != *folderFlag {
+			continue
+		}
+		if *tailStepFlag != "" && e.Step != *tailStepFlag {
+			continue
+		}
+		if logJSON {
+			fmt.Print(line)
+			continue
+		}
+		printf("%s %s | %-8s | %s\n", e.Time.Format("15:04:05.000"), e.Folder, e.Step, e.Message)
+	}
+}
+
+// Returns the ID of the run whose event log was modified most recently.
+func latestRunID(dir string) (string, error) {
+	runIDs, err := recentRunIDs(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(runIDs) == 0 {
+		return "", fmt.Errorf("no runs found in %s", dir)
+	}
+	return runIDs[0], nil
+}
+
+// Returns the IDs of the runs in dir, the one whose event log was modified most recently first.
+func recentRunIDs(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	modTimes := map[string]time.Time{}
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[match] = info.ModTime()
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return modTimes[matches[i]].After(modTimes[matches[j]])
+	})
+	runIDs := []string{}
+	for _, match := range matches {
+		runIDs = append(runIDs, strings.TrimSuffix(filepath.Base(match), ".jsonl"))
+	}
+	return runIDs, nil
+}
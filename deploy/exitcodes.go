@@ -0,0 +1,90 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Exit codes of the builder, so CI scripts can branch on the result of a run:
+//
+//	0  every folder deployed or was up to date
+//	1  the builder failed for any other reason, e.g. it could not write the manifest
+//	2  the flags, config file, folders, or AWS setup are invalid, and nothing was deployed
+//	3  more folders failed than -max-failures allows, and the rest were not deployed
+//	4  folders failed to build, and none deployed
+//	5  folders failed to deploy, and none deployed
+//	6  some folders deployed and some failed
+const (
+	exitError           = 1
+	exitConfigError     = 2
+	exitTooManyFailures = 3
+	exitBuildFailure    = 4
+	exitDeployFailure   = 5
+	exitPartialFailure  = 6
+)
+
+// An error that stops the run before any folder is deployed, e.g. a missing flag or a dependency cycle.
+type configError struct {
+	error
+}
+
+func (e configError) Unwrap() error {
+	return e.error
+}
+
+func configErrorf(format string, args ...interface{}) error {
+	return configError{fmt.Errorf(format, args...)}
+}
+
+// An error building a folder, before anything of it is uploaded.
+type buildError struct {
+	error
+}
+
+func (e buildError) Unwrap() error {
+	return e.error
+}
+
+// Returned by deploy when folders failed, listing them.
+type folderFailures struct {
+	folders []string
+	// whether other folders deployed or were up to date
+	partial bool
+	// whether every folder failed to build, or only failed because a folder it depends on did
+	build bool
+}
+
+func (e *folderFailures) Error() string {
+	return fmt.Sprintf("(%d) folders failed: %s", len(e.folders), strings.Join(e.folders, ", "))
+}
+
+// Returns the exit code of the error of a run.
+func exitCode(err error) int {
+	var failures *folderFailures
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errTooManyFailures):
+		return exitTooManyFailures
+	case errors.As(err, &configError{}):
+		return exitConfigError
+	case errors.As(err, &failures) && failures.partial:
+		return exitPartialFailure
+	case errors.As(err, &failures) && failures.build:
+		return exitBuildFailure
+	case errors.As(err, &failures):
+		return exitDeployFailure
+	}
+	return exitError
+}
+
+// Prints the error of the run, if there is one, and exits with its exit code.
+func exit(err error) {
+	code := exitCode(err)
+	if err != nil {
+		printf("Error: %s.\n", err.Error())
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,39 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, 0},
+		{"other error", errors.New("failed to write manifest"), exitError},
+		{"config error", configErrorf(`flag "signed-prefix" is required`), exitConfigError},
+		{"wrapped config error", fmt.Errorf("loading config: %w", configErrorf("bad")), exitConfigError},
+		{"too many failures", fmt.Errorf("%w: a, b", errTooManyFailures), exitTooManyFailures},
+		{"build failures", &folderFailures{folders: []string{"a"}, build: true}, exitBuildFailure},
+		{"deploy failures", &folderFailures{folders: []string{"a"}}, exitDeployFailure},
+		{"partial build failures", &folderFailures{folders: []string{"a"}, build: true, partial: true}, exitPartialFailure},
+		{"partial deploy failures", &folderFailures{folders: []string{"a"}, partial: true}, exitPartialFailure},
+		{"wrapped failures", fmt.Errorf("run: %w", &folderFailures{folders: []string{"a"}, build: true}), exitBuildFailure},
+		{"build error outside deploy", buildError{errors.New("go build failed")}, exitError},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestFolderFailuresError(t *testing.T) {
+	err := &folderFailures{folders: []string{"testLambda01", "testLambda02"}}
+	if got, want := err.Error(), "(2) folders failed: testLambda01, testLambda02"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
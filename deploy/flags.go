@@ -0,0 +1,96 @@
+package deploy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A flag that can be passed more than once, e.g. -env=GOPRIVATE=a -env=GOPROXY=b,direct.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// How many folders can fail before the run stops deploying the rest,
+// either a count, e.g. -max-failures=5, or a percentage of the folders, e.g. -max-failures=10%.
+// Unlimited unless set.
+type failureBudget struct {
+	set     bool
+	count   int
+	percent float64
+}
+
+func (b *failureBudget) String() string {
+	if !b.set {
+		return ""
+	}
+	if b.percent != 0 {
+		return strconv.FormatFloat(b.percent, 'f', -1, 64) + "%"
+	}
+	return strconv.Itoa(b.count)
+}
+
+func (b *failureBudget) Set(s string) error {
+	if strings.HasSuffix(s, "%") {
+		p, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil || p <= 0 || p > 100 {
+			return fmt.Errorf(`"%s" is not a percentage between 0%% and 100%%`, s)
+		}
+		*b = failureBudget{set: true, percent: p}
+		return nil
+	}
+	count, err := strconv.Atoi(s)
+	if err != nil || count < 0 {
+		return fmt.Errorf(`"%s" is not a count or a percentage, e.g. 5 or 10%%`, s)
+	}
+	*b = failureBudget{set: true, count: count}
+	return nil
+}
+
+// Returns whether more folders failed than the budget allows, out of total folders.
+func (b *failureBudget) exceeded(failures, total int) bool {
+	if !b.set {
+		return false
+	}
+	if b.percent != 0 {
+		return float64(failures) > b.percent/100*float64(total)
+	}
+	return failures > b.count
+}
+
+// Splits a comma-separated flag value.
+// Returns an empty slice if the value is empty.
+func splitList(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+// Parses comma-separated folder=value pairs, e.g. testLambda01=arm64,testLambda02=amd64.
+func parseOverrides(s string) (map[string]string, error) {
+	overrides := map[string]string{}
+	for _, pair := range splitList(s) {
+		folder, value, ok := strings.Cut(pair, "=")
+		if !ok || folder == "" || value == "" {
+			return nil, fmt.Errorf(`override "%s" is not of the form folder=value`, pair)
+		}
+		overrides[folder] = value
+	}
+	return overrides, nil
+}
+
+// Returns the value, or the fallback if the value is empty.
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,51 @@
+package deploy
+
+import "testing"
+
+func TestFailureBudget(t *testing.T) {
+	tests := []struct {
+		value    string
+		failures int
+		total    int
+		want     bool
+	}{
+		{"", 100, 100, false},
+		{"0", 0, 10, false},
+		{"0", 1, 10, true},
+		{"5", 5, 10, false},
+		{"5", 6, 10, true},
+		{"10%", 1, 10, false},
+		{"10%", 2, 10, true},
+		{"10%", 0, 5, false},
+		{"10%", 1, 5, true},
+		{"50%", 2, 5, false},
+		{"50%", 3, 5, true},
+		{"100%", 10, 10, false},
+		{"2.5%", 5, 200, false},
+		{"2.5%", 6, 200, true},
+	}
+	for _, tt := range tests {
+		b := failureBudget{}
+		if tt.value != "" {
+			err := b.Set(tt.value)
+			if err != nil {
+				t.Fatalf("Set(%q): %s", tt.value, err)
+			}
+		}
+		if got := b.exceeded(tt.failures, tt.total); got != tt.want {
+			t.Errorf("-max-failures=%s with (%d/%d) failed: exceeded = %v, want %v", tt.value, tt.failures, tt.total, got, tt.want)
+		}
+		if got := b.String(); got != tt.value {
+			t.Errorf("-max-failures=%s: String() = %q", tt.value, got)
+		}
+	}
+}
+
+func TestFailureBudgetInvalid(t *testing.T) {
+	for _, value := range []string{"-1", "0%", "101%", "-5%", "x", "5x", "%", ""} {
+		b := failureBudget{}
+		if err := b.Set(value); err == nil {
+			t.Errorf("Set(%q) = nil, want an error", value)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package deploy
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Returns the folders that contain Go files, filtered by the patterns.
+// If include is empty, every folder is included.
+// Returns an error if an include pattern does not match any folder, since that is usually a typo.
+func lambdaFolders(include, exclude []string) ([]string, error) {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid folder pattern %q: %w", pattern, err)
+		}
+	}
+	matches, err := filepath.Glob("*/*.go")
+	if err != nil {
+		return nil, err
+	}
+	allFolders := []string{}
+	for _, match := range matches {
+		dir, _ := filepath.Split(match)
+		dir = dir[:len(dir)-1]
+		if !contains(allFolders, dir) {
+			allFolders = append(allFolders, dir)
+		}
+	}
+	// folders built elsewhere may have no Go files
+	for folder, fc := range folderConfigs {
+		if fc.Artifact != "" && sourceFolder(folder) == folder && !contains(allFolders, folder) {
+			allFolders = append(allFolders, folder)
+		}
+	}
+	sort.Strings(allFolders)
+	for _, pattern := range include {
+		if !matchesSome(pattern, allFolders) {
+			printf("Lambda folders: %s.\n", strings.Join(allFolders, ", "))
+			return nil, fmt.Errorf(`pattern "%s" does not match any Lambda folder`, pattern)
+		}
+	}
+	folders := []string{}
+	skipped := []string{}
+	for _, folder := range allFolders {
+		if len(include) != 0 && !matchesAny(include, folder) || matchesAny(exclude, folder) {
+			skipped = append(skipped, folder)
+			continue
+		}
+		folders = append(folders, folder)
+	}
+	if len(include) != 0 || len(exclude) != 0 {
+		printf("Matched (%d) folders: %s.\n", len(folders), strings.Join(folders, ", "))
+		printf("Skipped (%d) folders: %s.\n\n", len(skipped), strings.Join(skipped, ", "))
+	}
+	return folders, nil
+}
+
+// Returns true if the pattern matches any of the strings.
+// Expects the pattern to be valid.
+func matchesSome(pattern string, strs []string) bool {
+	for _, str := range strs {
+		if ok, _ := path.Match(pattern, str); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the name of the folder's Lambda function: the function of its folder block,
+// or the folder between -function-prefix and -function-suffix.
+//
+//	folder "orders" {
+//	  function = "prod-orders-v2"
+//	}
+func functionName(folder string) string {
+	if function := folderConfigs[folder].Function; function != "" {
+		return function
+	}
+	return *functionPrefixFlag + folder + *functionSuffixFlag
+}
+
+// Returns the name of the folder's Lambda function in the run, see functionName.
+func (d *data) functionName(folder string) string {
+	if function := d.configs[folder].Function; function != "" {
+		return function
+	}
+	return d.functionPrefix + folder + d.functionSuffix
+}
+
+// Returns the folder whose function is the function, for commands that take -function.
+// Returns the function itself if no folder deploys it.
+func folderOfFunction(function string) string {
+	for folder, fc := range folderConfigs {
+		if fc.Function == function {
+			return folder
+		}
+	}
+	if strings.HasPrefix(function, *functionPrefixFlag) && strings.HasSuffix(function, *functionSuffixFlag) &&
+		len(function) > len(*functionPrefixFlag)+len(*functionSuffixFlag) {
+		return strings.TrimSuffix(strings.TrimPrefix(function, *functionPrefixFlag), *functionSuffixFlag)
+	}
+	return function
+}
@@ -0,0 +1,90 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Runs the test in a directory with a Go file in each of the folders, and a folder without one.
+func chdirFolders(t *testing.T, folders ...string) {
+	dir := t.TempDir()
+	for _, folder := range folders {
+		err := os.MkdirAll(filepath.Join(dir, folder), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(dir, folder, "main.go"), []byte("package main\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.MkdirAll(filepath.Join(dir, "docs"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestLambdaFolders(t *testing.T) {
+	chdirFolders(t, "orders-api", "orders-worker", "users-api", "internal")
+	useFolderConfigs(t, map[string]folderConfig{})
+	tests := []struct {
+		include []string
+		exclude []string
+		want    []string
+		wantErr string
+	}{
+		{want: []string{"internal", "orders-api", "orders-worker", "users-api"}},
+		{include: []string{"orders-*"}, want: []string{"orders-api", "orders-worker"}},
+		{include: []string{"*-api"}, want: []string{"orders-api", "users-api"}},
+		{include: []string{"orders-api", "users-api"}, want: []string{"orders-api", "users-api"}},
+		{exclude: []string{"internal"}, want: []string{"orders-api", "orders-worker", "users-api"}},
+		{include: []string{"orders-*"}, exclude: []string{"*-worker"}, want: []string{"orders-api"}},
+		{include: []string{"*-api"}, exclude: []string{"*"}, want: []string{}},
+		{exclude: []string{"nothing-*"}, want: []string{"internal", "orders-api", "orders-worker", "users-api"}},
+		{include: []string{"docs"}, wantErr: `pattern "docs" does not match any Lambda folder`},
+		{include: []string{"payments-*"}, wantErr: `pattern "payments-*" does not match any Lambda folder`},
+		{exclude: []string{"["}, wantErr: `invalid folder pattern "["`},
+	}
+	for _, tt := range tests {
+		got, err := lambdaFolders(tt.include, tt.exclude)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("include %q exclude %q: got error %v, want %q", tt.include, tt.exclude, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("include %q exclude %q: %s", tt.include, tt.exclude, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("include %q exclude %q: got %q, want %q", tt.include, tt.exclude, got, tt.want)
+		}
+	}
+}
+
+func TestLambdaFoldersArtifacts(t *testing.T) {
+	chdirFolders(t, "orders-api")
+	useFolderConfigs(t, map[string]folderConfig{
+		"legacy": {Name: "legacy", Artifact: "dist/legacy.zip"},
+	})
+	got, err := lambdaFolders(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"legacy", "orders-api"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
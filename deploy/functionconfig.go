@@ -0,0 +1,114 @@
+package deploy
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Updates the settings of the folder's function that its folder block sets, after its code is updated.
+// Only settings that differ from the function's are pushed, so functions whose settings did not change are not updated.
+//
+//	folder "testLambda01" {
+//	  memory      = 256
+//	  timeout     = 30
+//	  environment = { "TABLE" = "orders" }
+//	  handler     = "bootstrap"
+//	  layers      = ["otel"]
+//	  tracing     = "Active"
+//	}
+//
+// Settings the folder block leaves out are left as they are. The environment replaces every variable of the function.
+func (d *data) updateConfiguration(folder string) error {
+	fc := d.configs[folder]
+	layers := d.functionLayers[folder]
+	if fc.Memory == 0 && fc.Timeout == 0 && fc.Environment == nil && fc.Handler == "" && fc.Tracing == "" && len(layers) == 0 {
+		return nil
+	}
+	if err := d.refuseInReadOnly(folder, "updating Lambda function configuration"); err != nil {
+		return err
+	}
+	d.logf(folder, "configure", "Checking Lambda function configuration.")
+	current, err := d.lambda.GetFunctionConfiguration(d.ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(d.functionName(folder)),
+	})
+	if err != nil {
+		d.failf(folder, "configure", err, "Failed to get Lambda function configuration: %s", err.Error())
+		return err
+	}
+	input := &lambda.UpdateFunctionConfigurationInput{FunctionName: aws.String(d.functionName(folder))}
+	changed := []string{}
+	if fc.Memory != 0 && int32(fc.Memory) != aws.ToInt32(current.MemorySize) {
+		input.MemorySize = aws.Int32(int32(fc.Memory))
+		changed = append(changed, "memory")
+	}
+	if fc.Timeout != 0 && int32(fc.Timeout) != aws.ToInt32(current.Timeout) {
+		input.Timeout = aws.Int32(int32(fc.Timeout))
+		changed = append(changed, "timeout")
+	}
+	if fc.Environment != nil && !sameVariables(fc.Environment, current.Environment) {
+		input.Environment = &lambdaTypes.Environment{Variables: fc.Environment}
+		changed = append(changed, "environment")
+	}
+	if fc.Handler != "" && fc.Handler != aws.ToString(current.Handler) {
+		input.Handler = aws.String(fc.Handler)
+		changed = append(changed, "handler")
+	}
+	if fc.Tracing != "" && (current.TracingConfig == nil || string(current.TracingConfig.Mode) != fc.Tracing) {
+		input.TracingConfig = &lambdaTypes.TracingConfig{Mode: lambdaTypes.TracingMode(fc.Tracing)}
+		changed = append(changed, "tracing")
+	}
+	// the latest version of each layer, published before the functions that use them
+	if len(layers) != 0 {
+		arns := []string{}
+		for _, layer := range layers {
+			arns = append(arns, d.layerARNs[layer])
+		}
+		if !sameLayers(arns, current.Layers) {
+			input.Layers = arns
+			changed = append(changed, "layers")
+		}
+	}
+	if len(changed) == 0 {
+		d.donef(folder, "configure", "Lambda function configuration is up to date.")
+		return nil
+	}
+	d.logf(folder, "configure", "Updating Lambda function configuration: %s.", strings.Join(changed, ", "))
+	_, err = d.lambda.UpdateFunctionConfiguration(d.ctx, input)
+	if err != nil {
+		d.failf(folder, "configure", err, "Failed to update Lambda function configuration: %s", err.Error())
+		return err
+	}
+	d.donef(folder, "configure", "Updated Lambda function configuration: %s.", strings.Join(changed, ", "))
+	return d.waitForFunctionUpdate(folder)
+}
+
+func sameVariables(want map[string]string, current *lambdaTypes.EnvironmentResponse) bool {
+	have := map[string]string{}
+	if current != nil {
+		have = current.Variables
+	}
+	if len(want) != len(have) {
+		return false
+	}
+	for key, value := range want {
+		if v, ok := have[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+func sameLayers(want []string, current []lambdaTypes.Layer) bool {
+	if len(want) != len(current) {
+		return false
+	}
+	for i, layer := range current {
+		if aws.ToString(layer.Arn) != want[i] {
+			return false
+		}
+	}
+	return true
+}
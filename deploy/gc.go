@@ -0,0 +1,175 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// Lambda does not support tagging aliases, so previews are tracked with tags on the function.
+// The tag key names the alias and the tag value is when the preview expires.
+//
+//	go-lambda-builder:preview:PR-1234 = 2022-12-06T00:00:00Z
+const previewTagPrefix = "go-lambda-builder:preview:"
+
+// Returns the function's ARN and tags.
+func (d *data) getFunctionTags(folder string) (string, map[string]string, error) {
+	output, err := d.lambda.GetFunction(d.ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(d.functionName(folder)),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return *output.Configuration.FunctionArn, output.Tags, nil
+}
+
+// Tags the function with the time the preview alias expires.
+func (d *data) tagPreview(folder, alias string) error {
+	if err := d.refuseInReadOnly(folder, "tagging preview"); err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(d.previewTTL).UTC().Format(time.RFC3339)
+	d.logf(folder, "preview", "Tagging preview %s to expire at %s.", alias, expiresAt)
+	arn, _, err := d.getFunctionTags(folder)
+	if err != nil {
+		d.failf(folder, "preview", err, "Failed to get Lambda function: %s", err.Error())
+		return err
+	}
+	_, err = d.lambda.TagResource(d.ctx, &lambda.TagResourceInput{
+		Resource: aws.String(arn),
+		Tags:     map[string]string{previewTagPrefix + alias: expiresAt},
+	})
+	if err != nil {
+		d.failf(folder, "preview", err, "Failed to tag preview: %s", err.Error())
+		return err
+	}
+	d.donef(folder, "preview", "Tagged preview %s.", alias)
+	return nil
+}
+
+func (d *data) untagPreview(folder, alias string) error {
+	d.logf(folder, "teardown", "Untagging preview %s.", alias)
+	arn, _, err := d.getFunctionTags(folder)
+	if err != nil {
+		d.failf(folder, "teardown", err, "Failed to get Lambda function: %s", err.Error())
+		return err
+	}
+	_, err = d.lambda.UntagResource(d.ctx, &lambda.UntagResourceInput{
+		Resource: aws.String(arn),
+		TagKeys:  []string{previewTagPrefix + alias},
+	})
+	if err != nil {
+		d.failf(folder, "teardown", err, "Failed to untag preview: %s", err.Error())
+		return err
+	}
+	d.donef(folder, "teardown", "Untagged preview %s.", alias)
+	return nil
+}
+
+// Returns the pull request numbers of the folder's expired previews.
+func (d *data) expiredPreviews(folder string, now time.Time) ([]string, error) {
+	_, tags, err := d.getFunctionTags(folder)
+	if err != nil {
+		d.failf(folder, "gc", err, "Failed to get Lambda function: %s", err.Error())
+		return nil, err
+	}
+	prs := []string{}
+	for key, value := range tags {
+		if !strings.HasPrefix(key, previewTagPrefix) {
+			continue
+		}
+		alias := strings.TrimPrefix(key, previewTagPrefix)
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			d.logf(folder, "gc", "Preview %s has an invalid expiry, skipping: %s.", alias, value)
+			continue
+		}
+		if now.Before(expiresAt) {
+			continue
+		}
+		prs = append(prs, strings.TrimPrefix(alias, previewAlias("")))
+	}
+	sort.Strings(prs)
+	return prs, nil
+}
+
+// Tears down every preview whose TTL has passed.
+//
+//	builder gc \
+//	    -bucket=kesav-go-lambda-builder-test \
+//	    -signed-prefix=test/signed \
+//	    -gc-interval=1h
+func gc() error {
+	signedBucket := orDefault(*signedBucketFlag, *bucketFlag)
+	if signedBucket == "" {
+		return errors.New(`flag "signed-bucket" or "bucket" is required`)
+	}
+	if *signedPrefixFlag == "" {
+		return errors.New(`flag "signed-prefix" is required`)
+	}
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return err
+	}
+	d := &data{
+		configs:        folderConfigs,
+		functionPrefix: *functionPrefixFlag,
+		functionSuffix: *functionSuffixFlag,
+		ctx:            context.TODO(),
+		metrics:        runMetrics,
+		s3:             newS3Client(cfg),
+		signedBucket:   signedBucket,
+		signedPrefix:   *signedPrefixFlag,
+		lambda:         lambda.NewFromConfig(cfg),
+	}
+	targets, err := d.commandTargets(cfg)
+	if err != nil {
+		return err
+	}
+	for {
+		err := sweepPreviews(targets)
+		if *gcIntervalFlag <= 0 {
+			return err
+		}
+		if err != nil {
+			printf("Sweep failed, retrying in %s: %s.\n\n", *gcIntervalFlag, err.Error())
+		}
+		time.Sleep(*gcIntervalFlag)
+	}
+}
+
+// Tears down the expired previews of every selected folder in each region.
+func sweepPreviews(targets []*data) error {
+	folders, err := selectFolders()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	failures := []string{}
+	for _, d := range targets {
+		printf("Sweeping expired previews of (%d) folders in %s.\n\n", len(folders), d.region)
+		for _, folder := range folders {
+			prs, err := d.expiredPreviews(folder, now)
+			if err != nil {
+				failures = append(failures, d.folderInRegion(folder))
+				continue
+			}
+			for _, pr := range prs {
+				err := d.teardownPreview(folder, pr)
+				if err != nil {
+					failures = append(failures, d.folderInRegion(folder))
+				}
+			}
+		}
+	}
+	if len(failures) != 0 {
+		return fmt.Errorf("failed to sweep previews: %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package deploy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The go that builds every folder, from go env.
+type goToolchain struct {
+	// e.g. 1.22.3, empty for development versions, which are never checked
+	version string
+	// GOTOOLCHAIN, e.g. auto or local
+	switching string
+}
+
+// Returns the go that go build runs with env.
+func installedGo(env []string) (goToolchain, error) {
+	cmd := exec.Command("go", "env", "GOVERSION", "GOTOOLCHAIN")
+	cmd.Env = env
+	output, err := cmd.Output()
+	if err != nil {
+		return goToolchain{}, fmt.Errorf("go env: %w", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	t := goToolchain{}
+	if version := strings.TrimPrefix(lines[0], "go"); version != lines[0] {
+		t.version = version
+	}
+	// go before 1.21 prints an empty line for GOTOOLCHAIN, which it does not know
+	if len(lines) > 1 {
+		t.switching = lines[1]
+	}
+	return t, nil
+}
+
+// Returns the go directive of the go.mod in dir, e.g. 1.21, empty if there is none.
+// Folders without a go.mod are part of a bigger module, which go build checks itself.
+func goDirective(dir string) (string, error) {
+	f, err := os.Open(filepath.Join(dir, "go.mod"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "go" {
+			return fields[1], nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// Returns true if Go version a is older than b, e.g. 1.20.5 is older than 1.21.
+// Prereleases, e.g. 1.21rc2, compare as their release.
+func olderGo(a, b string) bool {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		x, y := 0, 0
+		if i < len(as) {
+			x = leadingNumber(as[i])
+		}
+		if i < len(bs) {
+			y = leadingNumber(bs[i])
+		}
+		if x != y {
+			return x < y
+		}
+	}
+	return false
+}
+
+func leadingNumber(s string) int {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(s[:end])
+	return n
+}
+
+// Fails if the installed go is older than the go.mod of the folder asks for,
+// so the folder fails before it builds instead of with a compile error.
+// Go 1.21 and later download the newer toolchain themselves, unless GOTOOLCHAIN=local.
+func (d *data) checkGoVersion(folder string) error {
+	if d.goToolchain.version == "" {
+		return nil
+	}
+	required, err := goDirective(sourceFolder(folder))
+	if err != nil {
+		d.failf(folder, "go", err, "Failed to read go.mod: %s.", err.Error())
+		return err
+	}
+	if required == "" || !olderGo(d.goToolchain.version, required) {
+		return nil
+	}
+	if !olderGo(d.goToolchain.version, "1.21") && d.goToolchain.switching != "local" {
+		d.logf(folder, "go", "Requires Go %s, Go %s will switch to it.", required, d.goToolchain.version)
+		return nil
+	}
+	err = fmt.Errorf("go.mod requires Go %s, but Go %s is installed", required, d.goToolchain.version)
+	d.failf(folder, "go", err, "Go is too old: %s. Install Go %s or later.", err.Error(), required)
+	return err
+}
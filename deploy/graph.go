@@ -0,0 +1,190 @@
+package deploy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Prints a diagram of what a run with the same flags and config would do, without building or calling AWS:
+// the steps each folder goes through, the folders of each level of dependencies, and which region and instance they deploy to.
+//
+//	builder graph -graph-format=mermaid -include=api-* > pipeline.md
+//
+// DOT diagrams render with graphviz, e.g. builder graph | dot -Tsvg > pipeline.svg.
+func graph() error {
+	folders, err := selectFolders()
+	if err != nil {
+		return err
+	}
+	title := "builder"
+	if *instanceFlag != -1 && *numInstancesFlag != -1 {
+		folders = spread(folders, 10)[*instanceFlag]
+		title = fmt.Sprintf("builder instance %d of %d", *instanceFlag, *numInstancesFlag-1)
+	}
+	levels, err := deployLevels(folders)
+	if err != nil {
+		return err
+	}
+	if regions := splitList(*regionsFlag); len(regions) != 0 {
+		title += fmt.Sprintf(" in (%d) regions: %s", len(regions), strings.Join(regions, ", "))
+	}
+	g := pipelineGraph{title: title, stages: pipelineStages(), levels: levels}
+	if *graphFormatFlag == "mermaid" {
+		return g.writeMermaid(os.Stdout)
+	}
+	return g.writeDOT(os.Stdout)
+}
+
+type pipelineGraph struct {
+	title  string
+	stages []string
+	levels [][]string
+}
+
+// Returns the steps each folder goes through with the flags, in order, like run and deploy.
+func pipelineStages() []string {
+	stages := []string{}
+	if *policyFlag != "" {
+		stages = append(stages, "evaluate policy")
+	}
+	switch {
+	case *artifactFlag != "":
+		stages = append(stages, "hash artifact")
+	case *hashCommandFlag != "":
+		stages = append(stages, "hash with command")
+	default:
+		stages = append(stages, "hash source code")
+	}
+	if !*forceFlag {
+		stages = append(stages, "skip if up to date")
+	}
+	stages = append(stages, "build", "zip")
+	if *readOnlyFlag {
+		return append(stages, "print plan")
+	}
+	if *noUploadFlag {
+		return stages
+	}
+	if signingEnabled() {
+		stages = append(stages, "upload unsigned")
+		if *noSignFlag {
+			return stages
+		}
+		stages = append(stages, "sign")
+	}
+	if *noCopySignedFlag {
+		return stages
+	}
+	stages = append(stages, "copy signed")
+	if *debugSymbolsPrefixFlag != "" {
+		stages = append(stages, "upload debug symbols")
+	}
+	if *noUpdateFunctionsFlag {
+		return stages
+	}
+	stages = append(stages, "update code", "update configuration")
+	if *latestOnlyFlag {
+		return stages
+	}
+	stages = append(stages, "publish version")
+	if *canaryPercentFlag > 0 {
+		stages = append(stages, fmt.Sprintf("shift %g%% to new version", *canaryPercentFlag))
+	}
+	alias := "move alias"
+	if *previewFlag != "" {
+		alias = "move alias PR-" + *previewFlag
+	}
+	return append(stages, alias)
+}
+
+// Returns the label of the folder, with the folder matrix builds are built from.
+func folderLabel(folder string) string {
+	label := folder
+	if source := sourceFolder(folder); source != folder {
+		label += "\\n(matrix of " + source + ")"
+	}
+	if function := functionName(folder); function != folder {
+		label += "\\n→ " + function
+	}
+	return label
+}
+
+// Writes the graph in the DOT language of graphviz.
+func (g pipelineGraph) writeDOT(w io.Writer) error {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "digraph builder {\n\tlabel=%q;\n\trankdir=LR;\n\tnode [shape=box];\n", g.title)
+	fmt.Fprintf(b, "\tsubgraph cluster_steps {\n\t\tlabel=\"each folder\";\n")
+	for i, stage := range g.stages {
+		fmt.Fprintf(b, "\t\tstep%d [label=%q];\n", i, stage)
+		if i > 0 {
+			fmt.Fprintf(b, "\t\tstep%d -> step%d;\n", i-1, i)
+		}
+	}
+	fmt.Fprintf(b, "\t}\n")
+	for i, level := range g.levels {
+		fmt.Fprintf(b, "\tsubgraph cluster_level%d {\n\t\tlabel=\"level %d\";\n", i, i)
+		for _, folder := range level {
+			// labels already contain escaped newlines, so they are quoted by hand
+			fmt.Fprintf(b, "\t\t%q [label=\"%s\"];\n", folder, strings.ReplaceAll(folderLabel(folder), `"`, `\"`))
+		}
+		fmt.Fprintf(b, "\t}\n")
+	}
+	for _, edge := range g.dependencies() {
+		fmt.Fprintf(b, "\t%q -> %q;\n", edge[0], edge[1])
+	}
+	fmt.Fprintf(b, "}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Writes the graph as a Mermaid flowchart, which GitHub renders in markdown.
+func (g pipelineGraph) writeMermaid(w io.Writer) error {
+	ids := map[string]string{}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "---\ntitle: %s\n---\nflowchart LR\n", g.title)
+	fmt.Fprintf(b, "\tsubgraph steps [each folder]\n")
+	for i, stage := range g.stages {
+		fmt.Fprintf(b, "\t\tstep%d[\"%s\"]\n", i, mermaidText(stage))
+		if i > 0 {
+			fmt.Fprintf(b, "\t\tstep%d --> step%d\n", i-1, i)
+		}
+	}
+	fmt.Fprintf(b, "\tend\n")
+	for i, level := range g.levels {
+		fmt.Fprintf(b, "\tsubgraph level%d [level %d]\n", i, i)
+		for _, folder := range level {
+			ids[folder] = fmt.Sprintf("folder%d", len(ids))
+			fmt.Fprintf(b, "\t\t%s[\"%s\"]\n", ids[folder], mermaidText(strings.ReplaceAll(folderLabel(folder), "\\n", "<br>")))
+		}
+		fmt.Fprintf(b, "\tend\n")
+	}
+	for _, edge := range g.dependencies() {
+		fmt.Fprintf(b, "\t%s --> %s\n", ids[edge[0]], ids[edge[1]])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Mermaid labels cannot contain quotes.
+func mermaidText(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
+
+// Returns the dependencies between the folders of the graph, as pairs of dependency and dependent.
+func (g pipelineGraph) dependencies() [][2]string {
+	deployed := []string{}
+	for _, level := range g.levels {
+		deployed = append(deployed, level...)
+	}
+	edges := [][2]string{}
+	for _, folder := range deployed {
+		for _, dependency := range folderConfigs[folder].DependsOn {
+			if contains(deployed, dependency) {
+				edges = append(edges, [2]string{dependency, folder})
+			}
+		}
+	}
+	return edges
+}
@@ -0,0 +1,81 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// The accounts and regions an environment is allowed to deploy to, and the role to deploy with.
+// Environments in other partitions than aws set their partition, see checkPartition.
+//
+//	environment "prod" {
+//	  account-ids = ["123456789012"]
+//	  regions     = ["us-east-1"]
+//	  role-arn    = "arn:aws:iam::123456789012:role/deployer"
+//	  external-id = "go-lambda-builder"
+//	}
+type environmentConfig struct {
+	Name       string   `hcl:"name,label"`
+	AccountIDs []string `hcl:"account-ids,optional"`
+	Regions    []string `hcl:"regions,optional"`
+	RoleARN    string   `hcl:"role-arn,optional"`
+	ExternalID string   `hcl:"external-id,optional"`
+	Partition  string   `hcl:"partition,optional"`
+}
+
+// Environments read from the config file, keyed by name.
+var environmentConfigs = map[string]environmentConfig{}
+
+// Returns an error if the credentials or region do not belong to the selected environment.
+// Environments are optional, but once one is configured, every run must select one.
+func checkGuardrails(ctx context.Context, cfg aws.Config, environment string) error {
+	if len(environmentConfigs) == 0 && environment == "" {
+		return nil
+	}
+	names := []string{}
+	for name := range environmentConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if environment == "" {
+		return fmt.Errorf(`flag "environment" is required, one of: %s`, strings.Join(names, ", "))
+	}
+	ec, ok := environmentConfigs[environment]
+	if !ok {
+		return fmt.Errorf(`environment "%s" is not one of: %s`, environment, strings.Join(names, ", "))
+	}
+	if len(ec.Regions) != 0 && !contains(ec.Regions, cfg.Region) {
+		return fmt.Errorf(
+			`region "%s" is not allowed in environment "%s", expected one of: %s`,
+			cfg.Region,
+			environment,
+			strings.Join(ec.Regions, ", "),
+		)
+	}
+	if len(ec.AccountIDs) != 0 {
+		output, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+		if err != nil {
+			return err
+		}
+		account := aws.ToString(output.Account)
+		if account == "" {
+			return errors.New("failed to determine the account of the credentials")
+		}
+		if !contains(ec.AccountIDs, account) {
+			return fmt.Errorf(
+				`account "%s" is not allowed in environment "%s", expected one of: %s`,
+				account,
+				environment,
+				strings.Join(ec.AccountIDs, ", "),
+			)
+		}
+	}
+	printf("Running in environment %s, region %s.\n\n", environment, cfg.Region)
+	return nil
+}
@@ -0,0 +1,59 @@
+package deploy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Hashes the output of -hash-command instead of the source code, for repos whose build graph
+// is tracked by another tool, e.g. Bazel or Nix. The command runs with sh in the working directory,
+// with the folder in FOLDER and the source folder of matrix builds in SOURCE_FOLDER.
+//
+//	builder -hash-command='nix path-info --derivation .#$FOLDER'
+//	builder -hash-command='bazel aquery //$SOURCE_FOLDER:all --output=text | sha256sum'
+//
+// The output only has to change whenever the executable would.
+func (d *data) hashWithCommand(folder string) (string, error) {
+	d.logf(folder, "hash", "Hashing with %s.", d.hashCommand)
+	cmd := exec.Command("sh", "-c", d.hashCommand)
+	cmd.Env = append([]string{}, d.env...)
+	cmd.Env = append(cmd.Env, "FOLDER="+folder, "SOURCE_FOLDER="+sourceFolder(folder))
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.Output()
+	if err != nil {
+		err = fmt.Errorf("%w: %s", err, scrub(strings.TrimSpace(stderr.String()), d.secrets))
+		d.failf(folder, "hash", err, "Failed to run hash command: %s.", err.Error())
+		return "", err
+	}
+	output := strings.TrimSpace(string(stdout))
+	if output == "" {
+		err := errors.New("hash command printed nothing")
+		d.failf(folder, "hash", err, "Failed to run hash command: %s.", err.Error())
+		return "", err
+	}
+	h := sha256.New()
+	// matrix builds of the same folder may share their output
+	if tags := buildTags(folder); tags != "" {
+		io.WriteString(h, "-tags="+tags+"\n")
+	}
+	for _, flag := range buildVars(folder) {
+		io.WriteString(h, flag+"\n")
+	}
+	io.WriteString(h, output)
+	// the hash command only covers what go build reads
+	err = d.hashAssets(folder, h)
+	if err != nil {
+		d.failf(folder, "hash", err, "Failed to hash assets: %s.", err.Error())
+		return "", err
+	}
+	hash := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	d.donef(folder, "hash", "Hashed with hash command: %s", hash)
+	return hash, nil
+}
@@ -0,0 +1,223 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Attributes of the -history-table.
+// The table is keyed by function name and the time of each deployment, so it can be queried newest first.
+const (
+	historyFunction = "function"
+	historyDeployed = "deployed"
+)
+
+// One deployment of a function, an item of the -history-table.
+type historyEntry struct {
+	Function string
+	Deployed time.Time
+	Folder   string
+	Region   string
+	Version  string
+	Alias    string
+	// the version the alias pointed at before, to roll back to
+	PreviousVersion string
+	UnsignedHash    string
+	SignedHash      string
+	SigningJobID    string
+	GitSHA          string
+	RunID           string
+	User            string
+	Environment     string
+}
+
+// Returns the item to put in the -history-table, leaving out empty attributes since DynamoDB rejects empty keys.
+func (e historyEntry) item() map[string]dynamodbTypes.AttributeValue {
+	item := map[string]dynamodbTypes.AttributeValue{}
+	for name, value := range map[string]string{
+		historyFunction:   e.Function,
+		historyDeployed:   e.Deployed.UTC().Format(time.RFC3339Nano),
+		"folder":          e.Folder,
+		"region":          e.Region,
+		"version":         e.Version,
+		"alias":           e.Alias,
+		"previousVersion": e.PreviousVersion,
+		"unsignedHash":    e.UnsignedHash,
+		"signedHash":      e.SignedHash,
+		"signingJobId":    e.SigningJobID,
+		"gitSha":          e.GitSHA,
+		"runId":           e.RunID,
+		"user":            e.User,
+		"environment":     e.Environment,
+	} {
+		if value != "" {
+			item[name] = &dynamodbTypes.AttributeValueMemberS{Value: value}
+		}
+	}
+	return item
+}
+
+// Returns the deployment recorded in the item.
+func historyEntryOf(item map[string]dynamodbTypes.AttributeValue) historyEntry {
+	s := func(name string) string {
+		if v, ok := item[name].(*dynamodbTypes.AttributeValueMemberS); ok {
+			return v.Value
+		}
+		return ""
+	}
+	deployed, _ := time.Parse(time.RFC3339Nano, s(historyDeployed))
+	return historyEntry{
+		Function:        s(historyFunction),
+		Deployed:        deployed,
+		Folder:          s("folder"),
+		Region:          s("region"),
+		Version:         s("version"),
+		Alias:           s("alias"),
+		PreviousVersion: s("previousVersion"),
+		UnsignedHash:    s("unsignedHash"),
+		SignedHash:      s("signedHash"),
+		SigningJobID:    s("signingJobId"),
+		GitSHA:          s("gitSha"),
+		RunID:           s("runId"),
+		User:            s("user"),
+		Environment:     s("environment"),
+	}
+}
+
+// Records the deployment of the folder in the -history-table.
+// Only warns if it fails, since the function is already deployed.
+func (d *data) recordHistory(folder string, r folderReport) {
+	if d.history == nil || d.historyTable == "" {
+		return
+	}
+	entry := historyEntry{
+		Function:        d.functionName(folder),
+		Deployed:        time.Now(),
+		Folder:          folder,
+		Region:          d.targetRegion,
+		Version:         r.Version,
+		Alias:           r.Alias,
+		PreviousVersion: r.PreviousVersion,
+		UnsignedHash:    r.UnsignedHash,
+		SignedHash:      r.SignedHash,
+		SigningJobID:    r.SigningJobID,
+		GitSHA:          d.gitSHA,
+		RunID:           d.runID,
+		User:            d.user,
+		Environment:     d.environment,
+	}
+	if entry.Region == "" {
+		entry.Region = d.region
+	}
+	_, err := d.history.PutItem(d.ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.historyTable),
+		Item:      entry.item(),
+	})
+	if err != nil {
+		d.logf(folder, "history", "Failed to record deployment in history table %s: %s.", d.historyTable, err.Error())
+		return
+	}
+	d.logf(folder, "history", "Recorded deployment of %s in history table %s.", aliasChange(entry.Alias, entry.PreviousVersion, entry.Version), d.historyTable)
+}
+
+// Returns the deployments of the function in the -history-table, newest first, at most limit if it is not 0.
+func queryHistory(ctx context.Context, client *dynamodb.Client, table, function string, limit int) ([]historyEntry, error) {
+	entries := []historyEntry{}
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(table),
+		KeyConditionExpression: aws.String("#f = :f"),
+		ExpressionAttributeNames: map[string]string{
+			"#f": historyFunction,
+		},
+		ExpressionAttributeValues: map[string]dynamodbTypes.AttributeValue{
+			":f": &dynamodbTypes.AttributeValueMemberS{Value: function},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+	paginator := dynamodb.NewQueryPaginator(client, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range output.Items {
+			entries = append(entries, historyEntryOf(item))
+			if limit != 0 && len(entries) == limit {
+				return entries, nil
+			}
+		}
+	}
+	return entries, nil
+}
+
+// Lists past deployments of a function, newest first.
+//
+//	builder history -function=testLambda01 -history-table=deployments
+//
+// With -history-limit, lists at most that many.
+func history() error {
+	if *historyTableFlag == "" {
+		return errors.New(`flag "history-table" is required`)
+	}
+	folders, err := selectFunctions()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return err
+	}
+	client := dynamodb.NewFromConfig(cfg)
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FUNCTION\tDEPLOYED\tREGION\tVERSION\tPREVIOUS\tALIAS\tGIT SHA\tUSER\tRUN\tSIGNING JOB")
+	for _, folder := range folders {
+		function := functionName(folder)
+		entries, err := queryHistory(context.TODO(), client, *historyTableFlag, function, *historyLimitFlag)
+		if err != nil {
+			return fmt.Errorf("failed to query history of %s: %w", function, err)
+		}
+		for _, e := range entries {
+			sha := e.GitSHA
+			if len(sha) > 7 {
+				sha = sha[:7]
+			}
+			fmt.Fprintln(w, strings.Join([]string{
+				e.Function, e.Deployed.Format(time.RFC3339), e.Region, e.Version, e.PreviousVersion, e.Alias, sha, e.User, e.RunID, e.SigningJobID,
+			}, "\t"))
+		}
+	}
+	w.Flush()
+	printf("%s", buf.String())
+	return nil
+}
+
+// Returns the version of the folder's function deployed from the commit, the newest if there are several.
+// The commit can be abbreviated.
+func (d *data) historicalVersion(folder, commit string) (string, error) {
+	if d.history == nil || d.historyTable == "" {
+		return "", fmt.Errorf(`flag "history-table" is required to roll back to commit %s`, commit)
+	}
+	entries, err := queryHistory(d.ctx, d.history, d.historyTable, d.functionName(folder), 0)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if e.Region != "" && d.region != "" && e.Region != d.region {
+			continue
+		}
+		if e.Version != "" && strings.HasPrefix(e.GitSHA, commit) {
+			return e.Version, nil
+		}
+	}
+	return "", fmt.Errorf("no deployment of commit %s in history table %s", commit, d.historyTable)
+}
@@ -0,0 +1,79 @@
+package deploy
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+)
+
+// Runs the prebuild hook of the folder block, if it has one, before the folder is hashed and built,
+// e.g. to generate code or compile assets the executable embeds:
+//
+//	folder "api" {
+//	  prebuild = "go generate ./..."
+//	}
+//
+// The hook runs with sh in the folder, with the environment of go build.
+// Runs before hashing, so the hash covers what it generates.
+func (d *data) runPrebuildHook(folder string) error {
+	hook := d.configs[folder].Prebuild
+	if hook == "" {
+		return nil
+	}
+	return d.runHook(folder, "prebuild", hook, nil)
+}
+
+// Runs the postdeploy hook of the folder block, if it has one, once the folder is deployed to the region,
+// e.g. to warm the function or run smoke tests:
+//
+//	folder "api" {
+//	  postdeploy = "./scripts/warm.sh"
+//	}
+//
+// The hook runs with sh in the folder, with the environment of go build,
+// and the function, version, alias, and region deployed in FUNCTION, VERSION, ALIAS, and REGION.
+// Only runs once the alias moved to the new version, so never in read-only mode, for folders that were up to date,
+// or for members of release units that were only published.
+func (d *data) runPostdeployHook(folder string) error {
+	hook := d.configs[folder].Postdeploy
+	if hook == "" {
+		return nil
+	}
+	status, version := "", ""
+	d.report.update(folder, d.targetRegion, func(r *folderReport) { status, version = r.Status, r.Version })
+	if status != reportDeployed {
+		d.skipf(folder, "postdeploy", "Not running postdeploy hook, the folder was not deployed (%s).", status)
+		return nil
+	}
+	return d.runHook(folder, "postdeploy", hook, []string{
+		"FUNCTION=" + d.functionName(folder),
+		"VERSION=" + version,
+		"ALIAS=" + d.aliasFor(folder),
+		"REGION=" + d.region,
+	})
+}
+
+func (d *data) runHook(folder, step, hook string, env []string) error {
+	d.logf(folder, step, "Running %s hook: %s.", step, hook)
+	cmd := exec.Command("sh", "-c", hook)
+	cmd.Dir = sourceFolder(folder)
+	cmd.Env = append([]string{}, d.env...)
+	cmd.Env = append(cmd.Env, "FOLDER="+folder, "SOURCE_FOLDER="+sourceFolder(folder))
+	cmd.Env = append(cmd.Env, env...)
+	// hooks such as go generate may download modules, like go build
+	cmd.Env = append(cmd.Env, d.gitEnv...)
+	output := &bytes.Buffer{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := cmd.Run()
+	printed := scrub(strings.TrimSpace(output.String()), d.secrets)
+	if err != nil {
+		d.failf(folder, step, err, "Failed to run %s hook: %s.\n%s", step, err.Error(), printed)
+		return err
+	}
+	if printed != "" {
+		d.logf(folder, step, "%s", printed)
+	}
+	d.donef(folder, step, "Ran %s hook.", step)
+	return nil
+}
@@ -0,0 +1,194 @@
+package deploy
+
+import (
+	"archive/zip"
+	"bytes"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Builds and zips the folder like a deploy would, unzips the package into a directory,
+// and prints its files and what takes up the space in its executable. Never calls AWS.
+//
+//	builder inspect -folder=testLambda01 -inspect-dir=/tmp/testLambda01
+//
+// Unzips into a new temporary directory if -inspect-dir is not passed in.
+func inspect() error {
+	folder := *folderFlag
+	if folder == "" {
+		return errors.New(`flag "folder" is required`)
+	}
+	dir := *inspectDirFlag
+	if dir == "" {
+		var err error
+		dir, err = os.MkdirTemp("", "builder-inspect-")
+		if err != nil {
+			return err
+		}
+	}
+	// inspect never calls AWS, so it cannot look up the runtime
+	handler := *handlerFlag
+	if handler == autoHandler {
+		handler = "main"
+	}
+	pkg, _, err := buildLocally(folder, dir, handler)
+	if err != nil {
+		return err
+	}
+	printf("\nUnzipped deployment package of %s into %s, %.2f M zipped.\n\n", folder, dir, float64(len(pkg))/1000000)
+	return printPackage(pkg, dir)
+}
+
+// Builds and zips the folder like a deploy would, and unzips the package into dir.
+// Returns the package and the architecture it was built for.
+func buildLocally(folder, dir, handler string) ([]byte, string, error) {
+	arch := orDefault(*archFlag, *goarchFlag)
+	err := validateArch(arch)
+	if err != nil {
+		return nil, "", err
+	}
+	archOverrides, err := parseArchOverrides(*archOverridesFlag)
+	if err != nil {
+		return nil, "", err
+	}
+	err = mergeFolderConfigs(archOverrides, map[string]string{}, map[string]string{})
+	if err != nil {
+		return nil, "", err
+	}
+	env, err := goBuildEnv()
+	if err != nil {
+		return nil, "", err
+	}
+	d := &data{
+		configs:        folderConfigs,
+		functionPrefix: *functionPrefixFlag,
+		functionSuffix: *functionSuffixFlag,
+		metrics:        runMetrics,
+		env:            env,
+		arch:           arch,
+		archOverrides:  archOverrides,
+		handler:        handler,
+		zip:            zipCompressionFlags(),
+	}
+	executablePath := filepath.Join(dir, handler)
+	err = d.buildExecutable(folder, executablePath, "linux", d.archFor(folder))
+	if err != nil {
+		return nil, "", err
+	}
+	unsignedR, err := d.zipExecutable(folder, executablePath, handler)
+	if err != nil {
+		return nil, "", err
+	}
+	pkg, err := io.ReadAll(unsignedR)
+	if err != nil {
+		return nil, "", err
+	}
+	// the package is what gets deployed, so unzip it instead of trusting the executable
+	err = os.Remove(executablePath)
+	if err != nil {
+		return nil, "", err
+	}
+	return pkg, d.archFor(folder), unzipInto(pkg, dir)
+}
+
+// Writes the files of the zip into dir.
+func unzipInto(pkg []byte, dir string) error {
+	r, err := zip.NewReader(bytes.NewReader(pkg), int64(len(pkg)))
+	if err != nil {
+		return err
+	}
+	for _, f := range r.File {
+		path := filepath.Join(dir, f.Name)
+		if !strings.HasPrefix(path, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("zip entry %s is outside of %s", f.Name, dir)
+		}
+		if f.FileInfo().IsDir() {
+			err := os.MkdirAll(path, 0755)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		// assets may be in directories without entries of their own
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(path, b, f.Mode())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prints the mode and sizes of each file in the package, and the sections of each executable.
+//
+//	MODE        SIZE     ZIPPED   NAME
+//	-rwxrwxrwx  6.21 M   3.02 M   main
+func printPackage(pkg []byte, dir string) error {
+	r, err := zip.NewReader(bytes.NewReader(pkg), int64(len(pkg)))
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODE\tSIZE\tZIPPED\tNAME")
+	for _, f := range r.File {
+		fmt.Fprintf(w, "%s\t%.2f M\t%.2f M\t%s\n",
+			f.Mode(), float64(f.UncompressedSize64)/1000000, float64(f.CompressedSize64)/1000000, f.Name)
+	}
+	w.Flush()
+	printf("%s\n", buf.String())
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		// not every file is an executable
+		exe, err := elf.Open(filepath.Join(dir, f.Name))
+		if err != nil {
+			continue
+		}
+		printSections(f.Name, exe)
+		exe.Close()
+	}
+	return nil
+}
+
+// Prints the biggest sections of the executable, e.g. .text for code and .rodata for constants and embedded files.
+func printSections(name string, exe *elf.File) {
+	sections := []*elf.Section{}
+	total := uint64(0)
+	for _, s := range exe.Sections {
+		if s.Size == 0 || s.Type == elf.SHT_NOBITS {
+			continue
+		}
+		sections = append(sections, s)
+		total += s.Size
+	}
+	sort.Slice(sections, func(i, j int) bool { return sections[i].Size > sections[j].Size })
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "SECTION OF %s\tSIZE\tSHARE\n", name)
+	for _, s := range sections {
+		fmt.Fprintf(w, "%s\t%.2f M\t%.1f%%\n", s.Name, float64(s.Size)/1000000, float64(s.Size)/float64(total)*100)
+	}
+	w.Flush()
+	printf("%s\n", buf.String())
+}
@@ -0,0 +1,107 @@
+package deploy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// How long to wait for the emulator to accept invocations.
+const emulatorStartTimeout = 10 * time.Second
+
+// Builds the folder like a deploy would and invokes it once in the Lambda Runtime Interface Emulator with docker,
+// then prints the response and the logs of the function. Never calls AWS.
+//
+//	builder invoke -folder=testLambda01 -payload=event.json
+//
+// The payload is read from stdin if it is -, and is {} if it is empty.
+func invoke() error {
+	folder := *folderFlag
+	if folder == "" {
+		return errors.New(`flag "folder" is required`)
+	}
+	payload := []byte("{}")
+	var err error
+	switch *payloadFlag {
+	case "":
+	case "-":
+		payload, err = io.ReadAll(os.Stdin)
+	default:
+		payload, err = os.ReadFile(*payloadFlag)
+	}
+	if err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp("", "builder-invoke-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	// the OS-only runtime runs bootstrap, whatever the function's runtime is
+	_, arch, err := buildLocally(folder, dir, "bootstrap")
+	if err != nil {
+		return err
+	}
+	printf("\nStarting %s in %s.\n\n", folder, *emulatorImageFlag)
+	output, err := exec.Command(
+		"docker", "run", "--rm", "--detach",
+		"--platform=linux/"+arch,
+		fmt.Sprintf("--publish=127.0.0.1:%d:8080", *invokePortFlag),
+		"--volume="+dir+":/var/task:ro",
+		*emulatorImageFlag,
+		"bootstrap",
+	).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("docker run: %s", strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	if err != nil {
+		return err
+	}
+	container := strings.TrimSpace(string(output))
+	defer exec.Command("docker", "stop", container).Run()
+	response, err := invokeEmulator(*invokePortFlag, payload)
+	if err != nil {
+		return err
+	}
+	logs, err := exec.Command("docker", "logs", container).CombinedOutput()
+	if err != nil {
+		return err
+	}
+	printf("%s\n", logs)
+	// only the response goes to stdout, so it can be piped into jq
+	fmt.Println(string(response))
+	return nil
+}
+
+// Posts the payload to the emulator listening on port, retrying until it has started.
+// Returns the response of the function.
+func invokeEmulator(port int, payload []byte) ([]byte, error) {
+	url := fmt.Sprintf("http://127.0.0.1:%d/2015-03-31/functions/function/invocations", port)
+	deadline := time.Now().Add(emulatorStartTimeout)
+	for {
+		res, err := http.Post(url, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			if time.Now().After(deadline) {
+				return nil, fmt.Errorf("emulator did not start in %s: %w", emulatorStartTimeout, err)
+			}
+			time.Sleep(200 * time.Millisecond)
+			continue
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("emulator responded with %s: %s", res.Status, body)
+		}
+		return body, nil
+	}
+}
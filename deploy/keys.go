@@ -0,0 +1,32 @@
+package deploy
+
+import "strings"
+
+// Joins the parts of an S3 key with one slash between each part.
+// Prefixes may be passed with or without leading and trailing slashes, and empty parts and doubled slashes are dropped.
+//
+//	objectKey("test/signed/", "testLambda01.zip") == "test/signed/testLambda01.zip"
+func objectKey(parts ...string) string {
+	segments := []string{}
+	for _, part := range parts {
+		for _, segment := range strings.Split(part, "/") {
+			if segment != "" {
+				segments = append(segments, segment)
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Returns the prefix with exactly one trailing slash, or an empty string for the root of the bucket.
+// Signer appends the job ID to its destination prefix as is, so it must be passed the prefix this way,
+// and so must ListObjectsV2 so that test/unsigned does not also match test/unsigned-old.
+//
+//	prefixDir("/test/staging") == "test/staging/"
+func prefixDir(prefix string) string {
+	prefix = objectKey(prefix)
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
@@ -0,0 +1,48 @@
+package deploy
+
+import "testing"
+
+func TestObjectKey(t *testing.T) {
+	tests := []struct {
+		parts []string
+		want  string
+	}{
+		{[]string{"test/signed", "testLambda01.zip"}, "test/signed/testLambda01.zip"},
+		{[]string{"test/signed/", "testLambda01.zip"}, "test/signed/testLambda01.zip"},
+		{[]string{"/test/signed", "/testLambda01.zip"}, "test/signed/testLambda01.zip"},
+		{[]string{"/test/signed/", "testLambda01.zip/"}, "test/signed/testLambda01.zip"},
+		{[]string{"test//signed", "testLambda01.zip"}, "test/signed/testLambda01.zip"},
+		{[]string{"test/signed//", "//testLambda01.zip"}, "test/signed/testLambda01.zip"},
+		{[]string{"", "test/signed", "", "testLambda01.zip"}, "test/signed/testLambda01.zip"},
+		{[]string{"", "testLambda01.zip"}, "testLambda01.zip"},
+		{[]string{"/", "testLambda01.zip"}, "testLambda01.zip"},
+		{[]string{""}, ""},
+		{[]string{}, ""},
+	}
+	for _, tt := range tests {
+		if got := objectKey(tt.parts...); got != tt.want {
+			t.Errorf("objectKey(%q) = %q, want %q", tt.parts, got, tt.want)
+		}
+	}
+}
+
+func TestPrefixDir(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"test/staging", "test/staging/"},
+		{"test/staging/", "test/staging/"},
+		{"/test/staging", "test/staging/"},
+		{"/test/staging//", "test/staging/"},
+		{"test//staging", "test/staging/"},
+		{"", ""},
+		{"/", ""},
+		{"//", ""},
+	}
+	for _, tt := range tests {
+		if got := prefixDir(tt.prefix); got != tt.want {
+			t.Errorf("prefixDir(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package deploy
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Returns true if $LATEST is running the signed deployment package.
+// Compares the CodeSha256 of $LATEST to the "signedhash" of the signed deployment package.
+func (d *data) isLatestDeployed(folder, signedKey string) (bool, error) {
+	d.logf(folder, "check", "Checking if $LATEST is running the deployment package.")
+	object, err := d.s3.HeadObject(d.ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(d.signedBucket),
+		Key:    aws.String(signedKey),
+	})
+	if err != nil {
+		d.failf(folder, "check", err, "Failed to get deployment package: %s", err.Error())
+		return false, err
+	}
+	function, err := d.lambda.GetFunctionConfiguration(d.ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(d.functionName(folder)),
+	})
+	if err != nil {
+		d.failf(folder, "check", err, "Failed to get Lambda function configuration: %s", err.Error())
+		return false, err
+	}
+	if aws.ToString(function.CodeSha256) != object.Metadata["signedhash"] {
+		d.donef(
+			folder,
+			"check",
+			"$LATEST is running a different deployment package, proceeding: %s.",
+			aws.ToString(function.CodeSha256),
+		)
+		return false, nil
+	}
+	d.donef(folder, "check", "$LATEST is running the deployment package.")
+	return true, nil
+}
+
+// Points $LATEST at the existing signed deployment package without rebuilding it.
+func (d *data) updateLatest(folder, signedKey, arch string) error {
+	if d.noUpdateFunctions {
+		d.skipf(folder, "update", "Not updating Lambda function code.")
+		return nil
+	}
+	err := d.updateFunctionCode(folder, signedKey, arch, d.tags)
+	if err != nil {
+		return err
+	}
+	return d.waitForFunctionUpdate(folder)
+}
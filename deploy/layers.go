@@ -0,0 +1,224 @@
+package deploy
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Layer versions published by the builder are described with this and the hash of their files,
+// so an unchanged layer is not published again.
+const layerDescriptionPrefix = "go-lambda-builder "
+
+// Returns the layers in the layers directory, one per subfolder.
+// Returns no layers if there is no layers directory.
+func layerNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// Returns the layers each folder's function uses, keyed by folder.
+//
+//	folder "testLambda01" {
+//	  layers = ["common"]
+//	}
+func functionLayers(layers []string) (map[string][]string, error) {
+	functionLayers := map[string][]string{}
+	for folder, fc := range folderConfigs {
+		for _, layer := range fc.Layers {
+			if !contains(layers, layer) {
+				return nil, fmt.Errorf(`folder "%s": layer "%s" is not in the layers directory`, folder, layer)
+			}
+		}
+		if len(fc.Layers) != 0 {
+			functionLayers[folder] = fc.Layers
+		}
+	}
+	return functionLayers, nil
+}
+
+// Publishes a new version of each layer whose files changed, and records the ARN of the latest version of every layer.
+func (d *data) publishLayers(dir string, layers []string) error {
+	d.layerARNs = map[string]string{}
+	failures := []string{}
+	for _, layer := range layers {
+		arn, err := d.publishLayer(filepath.Join(dir, layer), layer)
+		if err != nil {
+			failures = append(failures, layer)
+			continue
+		}
+		d.layerARNs[layer] = arn
+	}
+	if len(failures) != 0 {
+		return fmt.Errorf("failed to publish layers: %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+func (d *data) publishLayer(path, layer string) (string, error) {
+	// layers are logged and signed like the folder they are in
+	label := filepath.ToSlash(path)
+	d.logf(label, "layer", "Zipping layer %s.", layer)
+	pkg, hash, err := zipLayer(path, d.zip)
+	if err != nil {
+		d.failf(label, "layer", err, "Failed to zip layer: %s.", err.Error())
+		return "", err
+	}
+	description := layerDescriptionPrefix + hash
+	output, err := d.lambda.ListLayerVersions(d.ctx, &lambda.ListLayerVersionsInput{
+		LayerName: aws.String(layer),
+		MaxItems:  aws.Int32(1),
+	})
+	if err != nil {
+		d.failf(label, "layer", err, "Failed to list versions of layer: %s", err.Error())
+		return "", err
+	}
+	if len(output.LayerVersions) != 0 && aws.ToString(output.LayerVersions[0].Description) == description {
+		arn := aws.ToString(output.LayerVersions[0].LayerVersionArn)
+		d.donef(label, "layer", "Layer is up to date: %s.", arn)
+		return arn, nil
+	}
+	if d.readOnly {
+		d.donef(label, "plan", "Plan: publish new version of layer %s (%.2f M).", layer, float64(len(pkg))/1000000)
+		return "", nil
+	}
+	if d.noUpload {
+		d.skipf(label, "layer", "Not publishing layer %s.", layer)
+		return "", nil
+	}
+	content := &lambdaTypes.LayerVersionContentInput{ZipFile: pkg}
+	if d.signingProfileFor(label) != "" {
+		// regions without AWS Signer sign in the -signer-fallback region
+		s := d.signingRun()
+		unsignedKey := objectKey(d.unsignedPrefix, label+".zip")
+		objectVersion, err := s.putObject(label, unsignedKey, bytes.NewReader(pkg))
+		if err != nil {
+			return "", err
+		}
+		defer s.deleteObject(label, s.unsignedBucket, unsignedKey)
+		jobId, err := s.startSigningJob(label, unsignedKey, objectVersion)
+		if err != nil {
+			return "", err
+		}
+		err = s.waitForSigningJob(label, jobId)
+		if err != nil {
+			return "", err
+		}
+		stagingKey := objectKey(d.stagingPrefix, jobId+".zip")
+		defer s.deleteObject(label, s.stagingBucket, stagingKey)
+		content = &lambdaTypes.LayerVersionContentInput{
+			S3Bucket: aws.String(d.stagingBucket),
+			S3Key:    aws.String(stagingKey),
+		}
+		// Lambda only reads layers from buckets in its own region
+		if s != d {
+			signedR, err := s.getObject(label, stagingKey)
+			if err != nil {
+				return "", err
+			}
+			defer signedR.Close()
+			signed, err := io.ReadAll(signedR)
+			if err != nil {
+				d.failf(label, "layer", err, "Failed to download signed layer: %s", err.Error())
+				return "", err
+			}
+			content = &lambdaTypes.LayerVersionContentInput{ZipFile: signed}
+		}
+	}
+	d.logf(label, "layer", "Publishing new version of layer %s.", layer)
+	published, err := d.lambda.PublishLayerVersion(d.ctx, &lambda.PublishLayerVersionInput{
+		LayerName:   aws.String(layer),
+		Content:     content,
+		Description: aws.String(description),
+	})
+	if err != nil {
+		d.failf(label, "layer", err, "Failed to publish layer: %s", err.Error())
+		return "", err
+	}
+	arn := aws.ToString(published.LayerVersionArn)
+	d.donef(label, "layer", "Published layer: %s.", arn)
+	return arn, nil
+}
+
+// Zips the files of the layer, and returns the zip and a hash of the files.
+func zipLayer(path string, c zipCompression) ([]byte, string, error) {
+	files := []string{}
+	err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	// the hash must not depend on the order the files are walked in
+	sort.Strings(files)
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	c.register(w)
+	h := sha256.New()
+	for _, file := range files {
+		name, err := filepath.Rel(path, file)
+		if err != nil {
+			return nil, "", err
+		}
+		name = filepath.ToSlash(name)
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, "", err
+		}
+		// only whether the file is executable survives, not the umask of the machine
+		mode := fs.FileMode(0644)
+		if info.Mode()&0111 != 0 {
+			mode = 0755
+		}
+		entryW, err := w.CreateHeader(c.header(name, mode))
+		if err != nil {
+			return nil, "", err
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, "", err
+		}
+		io.WriteString(h, name)
+		_, err = io.Copy(io.MultiWriter(entryW, h), f)
+		f.Close()
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,56 @@
+package deploy
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsHttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+)
+
+// A counting semaphore, nil means unlimited.
+type semaphore chan struct{}
+
+// Returns a semaphore that allows n holders, or nil if n is not positive.
+func newSemaphore(n int) semaphore {
+	if n <= 0 {
+		return nil
+	}
+	return make(semaphore, n)
+}
+
+func (s semaphore) acquire() {
+	if s != nil {
+		s <- struct{}{}
+	}
+}
+
+func (s semaphore) release() {
+	if s != nil {
+		<-s
+	}
+}
+
+// An HTTP client that caps the number of AWS API calls in flight across every client that shares it.
+// Waiters sleep between calls, so they do not hold a slot while waiting.
+type limitedHTTPClient struct {
+	client aws.HTTPClient
+	sem    semaphore
+}
+
+func (c *limitedHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	c.sem.acquire()
+	defer c.sem.release()
+	return c.client.Do(req)
+}
+
+// Caps the number of concurrent AWS API calls made with the config.
+func limitAWSConcurrency(cfg *aws.Config, n int) {
+	if n <= 0 {
+		return
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = awsHttp.NewBuildableClient()
+	}
+	cfg.HTTPClient = &limitedHTTPClient{client: client, sem: newSemaphore(n)}
+}
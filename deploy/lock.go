@@ -0,0 +1,191 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// What a folder's function is running in an environment.
+type lockEntry struct {
+	SignedHash string `json:"signedHash"`
+	// $LATEST in latest-only mode
+	Version string `json:"version"`
+	Alias   string `json:"alias,omitempty"`
+}
+
+// The contents of the lock file, keyed by environment, then by folder.
+// Checked into the repo so drift between the repo and each environment is reviewable in pull requests.
+//
+//	{
+//	  "prod": {
+//	    "testLambda01": {"signedHash": "GgbC24x...", "version": "42", "alias": "LIVE"}
+//	  }
+//	}
+type lockFile map[string]map[string]lockEntry
+
+// Reads the lock file at path, or returns an empty lock file if it does not exist yet.
+func readLockFile(path string) (lockFile, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return lockFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	lock := lockFile{}
+	err = json.Unmarshal(b, &lock)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lock file %s: %w", path, err)
+	}
+	return lock, nil
+}
+
+// Writes the lock file with sorted keys so it diffs cleanly.
+func (l lockFile) write(path string) error {
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// Adds the entries of other, replacing the entries of the same folders.
+func (l lockFile) merge(other lockFile) {
+	for environment, entries := range other {
+		if l[environment] == nil {
+			l[environment] = map[string]lockEntry{}
+		}
+		for folder, entry := range entries {
+			l[environment][folder] = entry
+		}
+	}
+}
+
+// Returns the key of the environment in the lock file.
+// Every region of a multi-region run is its own environment.
+func lockEnvironment(environment, region string) string {
+	environment = orDefault(environment, "default")
+	if region == "" {
+		return environment
+	}
+	return environment + "/" + region
+}
+
+// Records what the folder's function is running, to be written to the lock file at the end of the run.
+func (d *data) recordDeploy(folder string, entry lockEntry) {
+	if d.lock == nil {
+		return
+	}
+	d.lockMu.Lock()
+	defer d.lockMu.Unlock()
+	environment := lockEnvironment(d.environment, d.targetRegion)
+	if d.lock[environment] == nil {
+		d.lock[environment] = map[string]lockEntry{}
+	}
+	d.lock[environment][folder] = entry
+}
+
+// Checks that every function in the lock file runs the signed deployment package it records.
+//
+//	builder verify-lock -lock-file=deployed.lock -environment=prod
+//
+// Only checks the selected environment, and with -regions, each of its regions.
+func verifyLock() error {
+	if *lockFileFlag == "" {
+		return errors.New(`flag "lock-file" is required`)
+	}
+	lock, err := readLockFile(*lockFileFlag)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return err
+	}
+	d := &data{
+		configs:        folderConfigs,
+		functionPrefix: *functionPrefixFlag,
+		functionSuffix: *functionSuffixFlag,
+		ctx:            context.TODO(),
+		metrics:        runMetrics,
+		lambda:         lambda.NewFromConfig(cfg),
+	}
+	targets := []*data{d}
+	if regions := splitList(*regionsFlag); len(regions) != 0 {
+		cfgs, err := loadRegionalAWSConfigs(cfg, regions)
+		if err != nil {
+			return err
+		}
+		targets = []*data{}
+		for _, regionalCfg := range cfgs {
+			targets = append(targets, d.inRegion(regionalCfg))
+		}
+	}
+	drifted := []string{}
+	for _, t := range targets {
+		environment := lockEnvironment(*environmentFlag, t.targetRegion)
+		entries, ok := lock[environment]
+		if !ok {
+			return fmt.Errorf(`lock file %s does not have environment "%s"`, *lockFileFlag, environment)
+		}
+		folders := []string{}
+		for folder := range entries {
+			folders = append(folders, folder)
+		}
+		sort.Strings(folders)
+		printf("Verifying (%d) folders of %s against %s.\n\n", len(folders), environment, *lockFileFlag)
+		for _, folder := range folders {
+			ok, err := t.verifyLockEntry(folder, entries[folder])
+			if err != nil || !ok {
+				drifted = append(drifted, fmt.Sprintf("%s (%s)", folder, environment))
+			}
+		}
+	}
+	if len(drifted) != 0 {
+		return fmt.Errorf("functions do not match the lock file: %s", strings.Join(drifted, ", "))
+	}
+	return nil
+}
+
+// Returns true if the folder's function runs the signed deployment package of the entry.
+func (d *data) verifyLockEntry(folder string, entry lockEntry) (bool, error) {
+	d.logf(folder, "verify-lock", "Checking version %s.", entry.Version)
+	version := entry.Version
+	if entry.Alias != "" {
+		output, err := d.lambda.GetAlias(d.ctx, &lambda.GetAliasInput{
+			FunctionName: aws.String(d.functionName(folder)),
+			Name:         aws.String(entry.Alias),
+		})
+		if err != nil {
+			d.failf(folder, "verify-lock", err, "Failed to get alias %s: %s", entry.Alias, err.Error())
+			return false, err
+		}
+		if aws.ToString(output.FunctionVersion) != version {
+			d.failf(folder, "verify-lock", nil, "Alias %s points at version %s, the lock file has %s.",
+				entry.Alias, aws.ToString(output.FunctionVersion), version)
+			return false, nil
+		}
+	}
+	output, err := d.lambda.GetFunctionConfiguration(d.ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(d.functionName(folder)),
+		Qualifier:    aws.String(version),
+	})
+	if err != nil {
+		d.failf(folder, "verify-lock", err, "Failed to get version %s: %s", version, err.Error())
+		return false, err
+	}
+	if hash := aws.ToString(output.CodeSha256); hash != entry.SignedHash {
+		d.failf(folder, "verify-lock", nil, "Version %s runs %s, the lock file has %s.", version, hash, entry.SignedHash)
+		return false, nil
+	}
+	d.donef(folder, "verify-lock", "Version %s matches the lock file.", version)
+	return true, nil
+}
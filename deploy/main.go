@@ -0,0 +1,1124 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/signer"
+)
+
+// The flags of the builder, registered on the flag set of Main by registerFlags.
+var (
+	// required
+	bucketFlag         *string
+	unsignedBucketFlag *string
+	stagingBucketFlag  *string
+	signedBucketFlag   *string
+	unsignedPrefixFlag *string
+	stagingPrefixFlag  *string
+	signedPrefixFlag   *string
+
+	// optional
+	signingProfileFlag                *string
+	configFlag                        *string
+	archFlag                          *string
+	archOverridesFlag                 *string
+	goarchFlag                        *string
+	toolsPrefixFlag                   *string
+	stampFlag                         *bool
+	stampPackageFlag                  *string
+	debugSymbolsPrefixFlag            *string
+	layersDirFlag                     *string
+	watchDebounceFlag                 *time.Duration
+	hashCommandFlag                   *string
+	artifactFlag                      *string
+	handlerFlag                       *string
+	aliasFlag                         *string
+	aliasOverridesFlag                *string
+	verifyAliasTimeoutFlag            *time.Duration
+	canaryPercentFlag                 *float64
+	canaryBakeFlag                    *time.Duration
+	canaryAlarmsFlag                  *string
+	latestOnlyFlag                    *bool
+	signingTimeoutFlag                *time.Duration
+	updateTimeoutFlag                 *time.Duration
+	waiterMinDelayFlag                *time.Duration
+	waiterMaxDelayFlag                *time.Duration
+	provisionedConcurrencyTimeoutFlag *time.Duration
+	verifySignedFlag                  *bool
+	codeSigningConfigFlag             *bool
+	codeSigningPolicyFlag             *string
+	signerFallbackFlag                *string
+	publishTimeoutFlag                *time.Duration
+	versionDescriptionFlag            *string
+	aliasHistoryFlag                  *int
+	historyTableFlag                  *string
+	historyLimitFlag                  *int
+	rollbackToFlag                    *string
+	orderFlag                         *string
+	sourceMetadataFlag                *bool
+	runIDFlag                         *string
+	eventsDirFlag                     *string
+	inspectDirFlag                    *string
+	folderFlag                        *string
+	bootstrapFormatFlag               *string
+	graphFormatFlag                   *string
+	payloadFlag                       *string
+	invokePortFlag                    *int
+	emulatorImageFlag                 *string
+	functionPrefixFlag                *string
+	functionSuffixFlag                *string
+	functionFlag                      *string
+	fromFlag                          *string
+	allFlag                           *bool
+	toFlag                            *string
+	confirmFlag                       *bool
+	tailStepFlag                      *string
+	followFlag                        *bool
+	colorFlag                         *string
+	logModeFlag                       *string
+	logFormatFlag                     *string
+	createAliasFlag                   *bool
+	previewFlag                       *string
+	previewURLAuthFlag                *string
+	previewTTLFlag                    *time.Duration
+	unsignedTTLFlag                   *time.Duration
+	keepSignedFlag                    *int
+	keepVersionsFlag                  *int
+	gcIntervalFlag                    *time.Duration
+	githubRepoFlag                    *string
+	githubTokenEnvFlag                *string
+	environmentFlag                   *string
+	regionFlag                        *string
+	assumeRoleARNFlag                 *string
+	externalIDFlag                    *string
+	roleDurationFlag                  *time.Duration
+	expectedRunTimeFlag               *time.Duration
+	sessionNameFlag                   *string
+	lockFileFlag                      *string
+	fromTagFlag                       *string
+	toTagFlag                         *string
+	regionsFlag                       *string
+	profileFlag                       *string
+	endpointURLFlag                   *string
+	endpointURLsFlag                  *string
+	s3PathStyleFlag                   *bool
+	includeFlag                       *string
+	excludeFlag                       *string
+	foldersFlag                       *string
+	forceFlag                         *bool
+	noUploadFlag                      *bool
+	noSignFlag                        *bool
+	noCopySignedFlag                  *bool
+	createMissingFlag                 *bool
+	noUpdateFunctionsFlag             *bool
+	uploadPartSizeFlag                *int64
+	uploadConcurrencyFlag             *int
+	s3PricePerGBFlag                  *float64
+	concurrencyFlag                   *int
+	buildConcurrencyFlag              *int
+	awsConcurrencyFlag                *int
+	maxAttemptsFlag                   *int
+	maxBackoffFlag                    *time.Duration
+	notifySlackWebhookFlag            *string
+	notifySNSTopicFlag                *string
+	slackWebhookEnvFlag               *string
+	eventBusFlag                      *string
+	outputFlag                        *string
+	tfvarsFlag                        *string
+	stepTotalsFlag                    *bool
+	sizeReportFlag                    *bool
+	zipLevelFlag                      *int
+	zipCompressionFlag                *string
+	zipParallelFlag                   *bool
+	requiredVersionFlag               *string
+	requiredVersionWarnFlag           *bool
+	porcelainFlag                     *bool
+	telemetryEndpointFlag             *string
+	cloudWatchNamespaceFlag           *string
+	otlpEndpointFlag                  *string
+	metricsFileFlag                   *string
+	policyFlag                        *string
+	policyQueryFlag                   *string
+	approveFlag                       *bool
+	readOnlyFlag                      *bool
+	instanceFlag                      *int
+	numInstancesFlag                  *int
+	envAllowFlag                      *string
+	envDenyFlag                       *string
+	envFlag                           listFlag
+	maxFailuresFlag                   failureBudget
+	codeArtifactDomainFlag            *string
+	codeArtifactOwnerFlag             *string
+	codeArtifactRepositoryFlag        *string
+	goprivateFlag                     *string
+	gitHostFlag                       *string
+	gitUsernameFlag                   *string
+	gitTokenEnvFlag                   *string
+)
+
+// Defaults of the flags that DeployFolder applies too, see newFolderRun.
+const (
+	defaultArch                          = "amd64"
+	defaultAlias                         = "TEST"
+	defaultVerifyAliasTimeout            = 30 * time.Second
+	defaultCanaryBake                    = 10 * time.Minute
+	defaultSigningTimeout                = 30 * time.Second
+	defaultUpdateTimeout                 = 30 * time.Second
+	defaultWaiterMinDelay                = 2 * time.Second
+	defaultWaiterMaxDelay                = 10 * time.Second
+	defaultProvisionedConcurrencyTimeout = 10 * time.Minute
+	defaultVerifySigned                  = true
+	defaultPublishTimeout                = 10 * time.Minute
+	// in MiB
+	defaultUploadPartSize = 8
+	defaultS3PricePerGB   = 0.023
+	defaultMaxAttempts    = 10
+	defaultMaxBackoff     = 20 * time.Second
+)
+
+// Registers the flags of the builder on fs.
+// Only Main registers them, so programs that import the package for DeployFolder keep flag.CommandLine to themselves.
+func registerFlags(fs *flag.FlagSet) {
+	// required
+	bucketFlag = fs.String("bucket", "", "Which bucket to use for any of the buckets below that are not passed in.")
+	unsignedBucketFlag = fs.String("unsigned-bucket", "", "Which bucket to upload unsigned deployment packages to. Defaults to -bucket.")
+	stagingBucketFlag = fs.String("staging-bucket", "", "Which bucket to upload signed deployment packages to for staging. Defaults to -bucket.")
+	signedBucketFlag = fs.String("signed-bucket", "", "Which bucket to upload signed deployment packages to for consumption. Defaults to -bucket.")
+	unsignedPrefixFlag = fs.String("unsigned-prefix", "", "Where to upload unsigned deployment packages.")
+	stagingPrefixFlag = fs.String("staging-prefix", "", "Where to upload signed deployment packages for staging.")
+	signedPrefixFlag = fs.String("signed-prefix", "", "Where to upload unsigned deployment packages for consumption.")
+
+	// optional
+	signingProfileFlag = fs.String("signing-profile", "", "Which profile to use to sign deployment packages. Deploys unsigned deployment packages if empty.")
+	configFlag = fs.String("config", "", "Which config file to read options from. Defaults to ~/.config/go-lambda-builder/config.hcl.")
+	archFlag = fs.String("arch", "", "The architecture for which to compile and deploy, amd64 or arm64. Defaults to -goarch.")
+	archOverridesFlag = fs.String("arch-overrides", "", "Comma-separated folder=arch pairs that override -arch, e.g. testLambda01=arm64.")
+	goarchFlag = fs.String("goarch", defaultArch, "Deprecated: use -arch.")
+	toolsPrefixFlag = fs.String("tools-prefix", "tools", "Where to upload tools in the signed bucket. Tools are folders with targets in the config file.")
+	stampFlag = fs.Bool("stamp", false, "Set the git commit, branch, tag, and commit time in each executable with -ldflags=-X, and add them to the metadata of each signed deployment package and the tags of each function.")
+	stampPackageFlag = fs.String("stamp-package", "main", "Which package declares the gitCommit, gitBranch, gitTag, and buildTime variables set by -stamp.")
+	debugSymbolsPrefixFlag = fs.String("debug-symbols-prefix", "", "Also build each executable with debug symbols and upload it here in the signed bucket, keyed by signed hash. The function is tagged with its location.")
+	layersDirFlag = fs.String("layers-dir", "layers", "Each subfolder of this folder is published as a Lambda layer. Functions use layers through the config file.")
+	watchDebounceFlag = fs.Duration("watch-debounce", 500*time.Millisecond, "How long builder watch waits after the last change to a folder before deploying it.")
+	hashCommandFlag = fs.String("hash-command", "", "A shell command whose output is hashed instead of the source code of each folder, e.g. to use the build graph of Bazel or Nix. Gets the folder in $FOLDER.")
+	artifactFlag = fs.String("artifact", "", "Comma-separated folder=path pairs of deployment packages built elsewhere, e.g. by Bazel, to deploy instead of building. A zip is deployed as is, anything else is zipped as the executable.")
+	handlerFlag = fs.String("handler", autoHandler, "The entrypoint for the Lambda function. If auto, bootstrap for functions on an OS-only runtime, e.g. provided.al2023, and main for the rest.")
+	aliasFlag = fs.String("alias", defaultAlias, "Which alias to point at the new version of each Lambda function.")
+	aliasOverridesFlag = fs.String("alias-overrides", "", "Comma-separated folder=alias pairs that override -alias, e.g. testLambda01=LIVE.")
+	verifyAliasTimeoutFlag = fs.Duration("verify-alias-timeout", defaultVerifyAliasTimeout, "How long to wait for an updated alias to settle on the new version.")
+	canaryPercentFlag = fs.Float64("canary-percent", 0, "Shift this percentage of the alias's traffic to the new version before pointing the alias at it.")
+	canaryBakeFlag = fs.Duration("canary-bake", defaultCanaryBake, "How long to shift -canary-percent of traffic before pointing the alias at the new version.")
+	canaryAlarmsFlag = fs.String("canary-alarms", "", "Comma-separated CloudWatch alarms that roll back the canary if they fire while baking.")
+	latestOnlyFlag = fs.Bool("latest-only", false, "Only update $LATEST, do not publish a version or update an alias.")
+	signingTimeoutFlag = fs.Duration("signing-timeout", defaultSigningTimeout, "How long to wait for a signing job to complete.")
+	updateTimeoutFlag = fs.Duration("update-timeout", defaultUpdateTimeout, "How long to wait for updated function code to be ready. Large packages take longer.")
+	waiterMinDelayFlag = fs.Duration("waiter-min-delay", defaultWaiterMinDelay, "The shortest to wait between checks of a signing job or function update.")
+	waiterMaxDelayFlag = fs.Duration("waiter-max-delay", defaultWaiterMaxDelay, "The longest to wait between checks of a signing job or function update.")
+	provisionedConcurrencyTimeoutFlag = fs.Duration("provisioned-concurrency-timeout", defaultProvisionedConcurrencyTimeout, "How long to wait for the provisioned-concurrency of a folder to be ready on its new version.")
+	verifySignedFlag = fs.Bool("verify-signed", defaultVerifySigned, "After copying a signed deployment package, check that its signing job and profile were not revoked and that the copy matches its hash.")
+	codeSigningConfigFlag = fs.Bool("code-signing-config", false, "Attach a code signing config that allows each folder's signing profile to its function, creating it if there is none, so Lambda itself rejects unsigned code.")
+	codeSigningPolicyFlag = fs.String("code-signing-policy", string(lambdaTypes.CodeSigningPolicyEnforce), "What code signing configs created by -code-signing-config do with code that fails the signature check: Enforce to reject it, or Warn to only log it.")
+	signerFallbackFlag = fs.String("signer-fallback", "", "What to do in regions without AWS Signer: a region to sign in instead, copying the signed package back, or skip to deploy unsigned packages there. Fails if empty.")
+	publishTimeoutFlag = fs.Duration("publish-timeout", defaultPublishTimeout, "How long to wait for a published version to become active. Versions of functions with SnapStart are snapshotted first, which can take minutes.")
+	versionDescriptionFlag = fs.String("version-description", "", "A text/template of the description of each published version, e.g. '{{.ShortSHA}} by {{.User}} at {{.Time}}'. Can use GitSHA, ShortSHA, RunID, User, Time, Folder, Function, Hash, and Environment.")
+	aliasHistoryFlag = fs.Int("alias-history", 0, "How many previous deploys to keep in the alias description.")
+	historyTableFlag = fs.String("history-table", "", "Record each deployment in this DynamoDB table, keyed by function (string) and deployed (string), for builder history and builder rollback -rollback-to.")
+	historyLimitFlag = fs.Int("history-limit", 20, "How many deployments of each function builder history lists. Lists every deployment if 0.")
+	rollbackToFlag = fs.String("rollback-to", "", "Which version builder rollback points the alias at, or a commit to look up in -history-table. Defaults to the version before the one the alias points at.")
+	orderFlag = fs.String("order", orderSlowestFirst, "Which folders to start first under -concurrency: slowest-first, by how long each took in the most recent runs in -events-dir, or name.")
+	sourceMetadataFlag = fs.Bool("source-metadata", false, "Record the git remote and branch on signed deployment packages, and only treat packages built from the same remote and branch as up to date, for pipelines of forks or other branches that share a bucket.")
+	runIDFlag = fs.String("run-id", "", "An ID for this run, stamped on everything it deploys. Defaults to GITHUB_RUN_ID or a random ID.")
+	eventsDirFlag = fs.String("events-dir", "", "Where to record the output of each run for builder tail-run. Defaults to the user cache directory.")
+	inspectDirFlag = fs.String("inspect-dir", "", "Where builder inspect unzips the deployment package. Defaults to a new temporary directory.")
+	folderFlag = fs.String("folder", "", "Which folder to operate on, for builder tail-run, builder adopt, builder artifact-diff, builder inspect, and builder invoke.")
+	bootstrapFormatFlag = fs.String("bootstrap-format", bootstrapCloudFormation, "What builder bootstrap prints, a cloudformation template or terraform JSON.")
+	graphFormatFlag = fs.String("graph-format", "dot", "What builder graph prints, dot or mermaid.")
+	payloadFlag = fs.String("payload", "", "Which file builder invoke sends to the function, - for stdin. Sends {} if empty.")
+	invokePortFlag = fs.Int("invoke-port", 9000, "Which local port builder invoke runs the Lambda Runtime Interface Emulator on.")
+	emulatorImageFlag = fs.String("emulator-image", "public.ecr.aws/lambda/provided:al2023", "Which image builder invoke runs the function in, with the Lambda Runtime Interface Emulator.")
+	functionPrefixFlag = fs.String("function-prefix", "", "Prepended to each folder to get the name of its Lambda function, e.g. prod-.")
+	functionSuffixFlag = fs.String("function-suffix", "", "Appended to each folder to get the name of its Lambda function, e.g. -v2.")
+	functionFlag = fs.String("function", "", "Which Lambda function to operate on, for builder adopt, builder artifact-diff, builder rollback, and builder promote. Defaults to -folder.")
+	fromFlag = fs.String("from", "", "Which version of the Lambda function to compare, for builder artifact-diff, or which alias to promote, for builder promote.")
+	allFlag = fs.Bool("all", false, "Operate on every folder matched by -include and -exclude, for builder rollback and builder promote.")
+	toFlag = fs.String("to", "", "Which version of the Lambda function to compare against, for builder artifact-diff, or which alias to point at the promoted version, for builder promote.")
+	confirmFlag = fs.Bool("confirm", false, "Ask for confirmation before builder promote changes any alias.")
+	tailStepFlag = fs.String("step", "", "Which step to show events for in builder tail-run, e.g. build or sign.")
+	followFlag = fs.Bool("follow", false, "Keep showing new events in builder tail-run until the run is complete.")
+	colorFlag = fs.String("color", colorAuto, "When to color each line of output by its step, and failures red: auto on a terminal unless NO_COLOR is set, always, or never.")
+	logModeFlag = fs.String("log-mode", logModeStream, "How to print the text output of concurrent folders: stream every line as it happens, grouped to print each folder's lines in one block once it is done, or live for one updating status line per folder on a terminal.")
+	logFormatFlag = fs.String("log-format", "text", "How to print the output of each folder, text or json. With json, prints one event per line.")
+	createAliasFlag = fs.Bool("create-alias", false, "Create the alias if it does not exist.")
+	previewFlag = fs.String("preview", "", "Deploy a preview for this pull request number to the alias PR-<number>.")
+	previewURLAuthFlag = fs.String("preview-url-auth", "AWS_IAM", "How preview function URLs authenticate callers, AWS_IAM or NONE.")
+	previewTTLFlag = fs.Duration("preview-ttl", 72*time.Hour, "How long a preview lives before builder gc deletes it.")
+	unsignedTTLFlag = fs.Duration("unsigned-ttl", 6*time.Hour, "How old an unsigned deployment package must be before builder clean-unsigned deletes it.")
+	keepSignedFlag = fs.Int("keep-signed", 10, "How many versions of each signed deployment package builder clean keeps.")
+	keepVersionsFlag = fs.Int("keep-versions", 10, "How many of the latest versions of each function builder clean keeps, besides versions an alias points at.")
+	gcIntervalFlag = fs.Duration("gc-interval", 0, "Keep running builder gc at this interval instead of exiting after one sweep.")
+	githubRepoFlag = fs.String("github-repo", os.Getenv("GITHUB_REPOSITORY"), "Which GitHub repository (owner/name) to post preview URLs to.")
+	githubTokenEnvFlag = fs.String("github-token-env", "GITHUB_TOKEN", "Which environment variable holds the token to post preview URLs to GitHub with.")
+	environmentFlag = fs.String("environment", "", "Which environment from the config file to deploy to. Refuses to run if the account or region do not match it.")
+	regionFlag = fs.String("region", "", "Which AWS region to use.")
+	assumeRoleARNFlag = fs.String("assume-role-arn", "", "Deploy with this role, e.g. a role in another account. Defaults to the role-arn of the environment.")
+	externalIDFlag = fs.String("external-id", "", "The external ID to assume the role with. Defaults to the external-id of the environment.")
+	roleDurationFlag = fs.Duration("role-duration", time.Hour, "How long the credentials of the assumed role last before they are refreshed, at most the maximum session duration of the role.")
+	expectedRunTimeFlag = fs.Duration("expected-run-time", 0, "How long the run is expected to take, to warn if the credentials expire first. Estimated from the number of folders and -concurrency if 0.")
+	sessionNameFlag = fs.String("session-name", "go-lambda-builder", "The session name to assume the role with, shown in CloudTrail.")
+	lockFileFlag = fs.String("lock-file", "", "Record the version and signed hash each function runs in this file, e.g. deployed.lock, for builder verify-lock.")
+	fromTagFlag = fs.String("from-tag", "", "The previous release, for builder release.")
+	toTagFlag = fs.String("to-tag", "", "The release to deploy, for builder release. Must be checked out.")
+	regionsFlag = fs.String("regions", "", "Comma-separated AWS regions to deploy to, building each folder once. Bucket names must contain {region}. teardown-preview and gc act in each of them.")
+	profileFlag = fs.String("profile", "", "Which AWS profile to use.")
+	endpointURLFlag = fs.String("endpoint-url", os.Getenv("AWS_ENDPOINT_URL"), "Send every AWS API call to this endpoint instead of AWS, e.g. http://localhost:4566 for LocalStack. Defaults to AWS_ENDPOINT_URL.")
+	endpointURLsFlag = fs.String("endpoint-urls", "", "Comma-separated service=url endpoints that override -endpoint-url for their service, e.g. s3=http://localhost:9000 for minio.")
+	s3PathStyleFlag = fs.Bool("s3-path-style", false, "Address S3 buckets in the path of the URL instead of the host name, as minio and LocalStack need.")
+	includeFlag = fs.String("include", "", "Comma-separated glob patterns of folders to deploy, e.g. api-*. Deploys every folder if empty.")
+	excludeFlag = fs.String("exclude", "internal", "Comma-separated glob patterns of folders to skip, e.g. internal,legacy-*.")
+	foldersFlag = fs.String("folders", "", "Deprecated: use -include.")
+	forceFlag = fs.Bool("force", false, "Deploy even if signed deployment package is up-to-date.")
+	noUploadFlag = fs.Bool("no-upload", false, "Do not upload unsigned deployment packages to S3.")
+	noSignFlag = fs.Bool("no-sign", false, "Do not run any signing jobs.")
+	noCopySignedFlag = fs.Bool("no-copy-signed", false, "Do not copy signed deployment packages to signed prefix.")
+	createMissingFlag = fs.Bool("create-missing", false, "Create Lambda functions that do not exist, with the role, runtime, memory, timeout, and environment of their folder block.")
+	noUpdateFunctionsFlag = fs.Bool("no-update-functions", false, "Do not update Lambda functions.")
+	uploadPartSizeFlag = fs.Int64("upload-part-size", defaultUploadPartSize, "The size in MiB of each part of multipart uploads to S3, at least 5.")
+	uploadConcurrencyFlag = fs.Int("upload-concurrency", manager.DefaultUploadConcurrency, "How many parts of each deployment package to upload to S3 at once.")
+	s3PricePerGBFlag = fs.Float64("s3-price-per-gb", defaultS3PricePerGB, "Price of S3 storage per GB-month, used to estimate costs in read-only mode.")
+	concurrencyFlag = fs.Int("concurrency", 0, "How many folders to deploy at once. Deploys every folder at once if 0.")
+	buildConcurrencyFlag = fs.Int("build-concurrency", runtime.NumCPU(), "How many go builds to run at once. Unlimited if 0.")
+	awsConcurrencyFlag = fs.Int("aws-concurrency", 0, "How many AWS API calls to make at once. Unlimited if 0.")
+	maxAttemptsFlag = fs.Int("max-attempts", defaultMaxAttempts, "How many times to try each AWS API call that fails with throttling or a transient 5xx error.")
+	maxBackoffFlag = fs.Duration("max-backoff", defaultMaxBackoff, "The longest to wait between attempts of an AWS API call.")
+	notifySlackWebhookFlag = fs.String("notify-slack-webhook", "", "The Slack incoming webhook URL to post the start, failures, and a summary of each run to. Prefer -slack-webhook-env in CI logs.")
+	notifySNSTopicFlag = fs.String("notify-sns-topic", "", "The ARN of an SNS topic to publish a summary of each run to when it completes: the versions deployed, the failures, and how long it took.")
+	slackWebhookEnvFlag = fs.String("slack-webhook-env", "", "Which environment variable holds the Slack incoming webhook URL to post the start, failures, and end of each run to.")
+	eventBusFlag = fs.String("event-bus", "", "Which EventBridge bus to put an event on for the start and end of each run and for every folder.")
+	outputFlag = fs.String("output", "", "Write a manifest of the run to this file, e.g. manifest.json, with the hashes, S3 keys, signing job, and version of each folder.")
+	tfvarsFlag = fs.String("tfvars", "", "Write the signed S3 key, object version, and source code hash of each function to this file, e.g. lambda.auto.tfvars.json, for Terraform.")
+	stepTotalsFlag = fs.Bool("step-totals", false, "Print how long each step took across all folders at the end of the run.")
+	sizeReportFlag = fs.Bool("size-report", false, "Report the packages that contribute the most code to each executable, also in the -output manifest.")
+	zipLevelFlag = fs.Int("zip-level", -1, "How hard to compress deployment packages: 0 stores them, 1 is fastest, 9 is smallest, -1 is the deflate default.")
+	zipCompressionFlag = fs.String("zip-compression", "", "How hard to compress deployment packages by name: store, fastest, default, or best. Sets -zip-level.")
+	zipParallelFlag = fs.Bool("zip-parallel", false, "Compress each deployment package on every CPU. Faster for large executables, at the cost of slightly larger packages.")
+	requiredVersionFlag = fs.String("required-version", "", "The builder versions the repo supports, e.g. '>= 1.4.0, < 2'. Read from .builderversion if empty.")
+	requiredVersionWarnFlag = fs.Bool("required-version-warn", false, "Only warn when the builder does not satisfy the required version, instead of refusing to run.")
+	porcelainFlag = fs.Bool("porcelain", false, "Print progress to stderr, and only a stable tab-separated line per folder and one for the run to stdout, for scripts to read.")
+	telemetryEndpointFlag = fs.String("telemetry-endpoint", "", "Opt in to posting anonymous statistics of the run to this URL: folder and region counts, how long each step took, which steps failed, and the builder version. Never sends names or error messages.")
+	cloudWatchNamespaceFlag = fs.String("cloudwatch-namespace", "", "Publish how long each step took, package sizes, how many folders were deployed, up to date, and failed, and the cache hit rate to this CloudWatch namespace at the end of each run.")
+	otlpEndpointFlag = fs.String("otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "Export a span for the run, each folder, and each of its steps to this OpenTelemetry collector over OTLP/HTTP, e.g. http://localhost:4318. Defaults to OTEL_EXPORTER_OTLP_ENDPOINT.")
+	metricsFileFlag = fs.String("metrics-file", "", "Write how long each step took across all folders to this file in the Prometheus text format.")
+	policyFlag = fs.String("policy", "", "Which rego policy to evaluate with opa before deploying each folder.")
+	policyQueryFlag = fs.String("policy-query", "data.builder", "Which rego query returns the deny and require_approval sets.")
+	approveFlag = fs.Bool("approve", false, "Approve deploys that the policy requires approval for.")
+	readOnlyFlag = fs.Bool("read-only", false, "Build, zip, hash, and print the deploy plan, but never change anything in AWS. Overrides every other flag.")
+	instanceFlag = fs.Int("instance", -1, "Which instance this builder is.")
+	numInstancesFlag = fs.Int("num-instances", -1, "Number of instances running.")
+	envAllowFlag = fs.String("env-allow", "", "Comma-separated patterns of host environment variables to pass to go build. Passes everything if empty.")
+	envDenyFlag = fs.String("env-deny", "", "Comma-separated patterns of host environment variables to hide from go build.")
+	codeArtifactDomainFlag = fs.String("codeartifact-domain", "", "Which CodeArtifact domain to download modules from, sets GOPROXY for go build.")
+	codeArtifactOwnerFlag = fs.String("codeartifact-domain-owner", "", "Which account owns the CodeArtifact domain. Defaults to the account deploying.")
+	codeArtifactRepositoryFlag = fs.String("codeartifact-repository", "", "Which CodeArtifact repository to download modules from.")
+	goprivateFlag = fs.String("goprivate", "", "Comma-separated module path patterns to treat as private, sets GOPRIVATE for go build.")
+	gitHostFlag = fs.String("git-host", "github.com", "Which git host to authenticate to when downloading private modules.")
+	gitUsernameFlag = fs.String("git-username", "x-access-token", "Which username to authenticate to the git host with.")
+	gitTokenEnvFlag = fs.String("git-token-env", "", "Which environment variable holds the token (or GitHub App installation token) to authenticate to the git host with.")
+	fs.Var(&maxFailuresFlag, "max-failures", "Stop deploying once more than this many folders fail, e.g. 5 or 10%, and exit with code 3. Unlimited if not set.")
+	fs.Var(&envFlag, "env", "An extra KEY=VALUE environment variable to pass to go build, e.g. GOPRIVATE. Can be repeated.")
+}
+
+// TODO(kesav): look into ClientRequestToken
+// TODO(kesav): delete both the object and the delete marker from unsigned/ and staging/ (wait till monday)
+//
+// if you run two zips on the same input, the hashes of the outputs will be the same
+//
+// if you run two signing jobs on the same input, the hashes of the outputs will be different
+//
+// no need to use upx
+// default is -7
+//
+// command                | time | compression ratio
+//
+// upx main               | 3s   | 52.49%
+// upx --brute main       | 229s | 43.04%
+// upx --ultra-brute main | 239s | 42.99%
+//
+// size of unsigned deployment package without upx | 6.04 M
+// size of unsigned deployment package with upx -7 | 5.82 M
+
+// The flag set of the builder command, created by Main.
+var commandLine *flag.FlagSet
+
+// Runs the builder with the arguments of the process, as the builder command does, and exits.
+func Main() {
+	commandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	registerFlags(commandLine)
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
+	err := parseFlags(os.Args[1:])
+	if err != nil {
+		exit(configError{err})
+	}
+
+	d, err := deploy()
+	printf("\nTook %s.\n\n", formatDuration(runMetrics.elapsed()))
+	if *porcelainFlag {
+		d.writePorcelain(os.Stdout, err)
+	}
+	sendTelemetry(d, runMetrics, err)
+	d.putCloudWatchMetrics(runMetrics, err)
+	runTrace.export(d, err)
+	metricsErr := runMetrics.writePrometheusFile(*metricsFileFlag)
+	if metricsErr != nil {
+		printf("Failed to write metrics to %s: %s.\n\n", *metricsFileFlag, metricsErr.Error())
+	}
+	// distinct codes let CI tell a systemic problem apart from a few broken folders
+	exit(err)
+}
+
+// Returned by deploy once more folders fail than -max-failures allows.
+var errTooManyFailures = errors.New("too many folders failed")
+
+// Given to folders that were not started because of errTooManyFailures.
+var errCancelled = errors.New("cancelled")
+
+// Deploys the folders selected by the flags.
+// Returns the run, with what each function deployed by it runs in its lock file,
+// and an error listing the folders that failed to deploy.
+func deploy() (*data, error) {
+	// unsigned and staging are only used for signing
+	unsignedBucket := orDefault(*unsignedBucketFlag, *bucketFlag)
+	stagingBucket := orDefault(*stagingBucketFlag, *bucketFlag)
+	if signingEnabled() {
+		if unsignedBucket == "" {
+			return nil, configErrorf(`flag "unsigned-bucket" or "bucket" is required`)
+		}
+		if stagingBucket == "" {
+			return nil, configErrorf(`flag "staging-bucket" or "bucket" is required`)
+		}
+		if *unsignedPrefixFlag == "" {
+			return nil, configErrorf(`flag "unsigned-prefix" is required`)
+		}
+		if *stagingPrefixFlag == "" {
+			return nil, configErrorf(`flag "staging-prefix" is required`)
+		}
+	}
+	signedBucket := orDefault(*signedBucketFlag, *bucketFlag)
+	if signedBucket == "" {
+		return nil, configErrorf(`flag "signed-bucket" or "bucket" is required`)
+	}
+	if *signedPrefixFlag == "" {
+		return nil, configErrorf(`flag "signed-prefix" is required`)
+	}
+
+	var folders []string
+	var err error
+	if singleFolder != "" {
+		// builder run-one skips looking through every folder
+		folders = expandMatrix([]string{singleFolder})
+	} else {
+		folders, err = selectFolders()
+		if err != nil {
+			return nil, configError{err}
+		}
+	}
+
+	if singleFolder == "" && *instanceFlag != -1 && *numInstancesFlag != -1 {
+		chunks := spread(folders, 10)
+		for i, chunk := range chunks {
+			printf("Instance %d: (%d) %s\n", i, len(chunk), strings.Join(chunk, ", "))
+		}
+		printf("\n")
+		printf("Running instance %d of %d.\n\n", *instanceFlag, *numInstancesFlag-1)
+		folders = chunks[*instanceFlag]
+	}
+	levels, err := deployLevels(folders)
+	if err != nil {
+		return nil, configError{err}
+	}
+
+	if len(folders) == 0 {
+		return nil, configErrorf("no folders found")
+	}
+
+	printf("Deploying (%d) folders: %s.\n\n", len(folders), strings.Join(folders, ", "))
+
+	arch := *archFlag
+	if arch == "" {
+		arch = *goarchFlag
+	}
+	err = validateArch(arch)
+	if err != nil {
+		return nil, configError{err}
+	}
+	artifacts, err := folderArtifacts(*artifactFlag, folders)
+	if err != nil {
+		return nil, configError{err}
+	}
+	archOverrides, err := parseArchOverrides(*archOverridesFlag)
+	if err != nil {
+		return nil, configError{err}
+	}
+
+	aliasOverrides, err := parseOverrides(*aliasOverridesFlag)
+	if err != nil {
+		return nil, configError{err}
+	}
+	signingProfileOverrides := map[string]string{}
+	err = mergeFolderConfigs(archOverrides, aliasOverrides, signingProfileOverrides)
+	if err != nil {
+		return nil, configError{err}
+	}
+	tools, err := toolTargets()
+	if err != nil {
+		return nil, configError{err}
+	}
+	layers, err := layerNames(*layersDirFlag)
+	if err != nil {
+		return nil, configError{err}
+	}
+	layersOfFunctions, err := functionLayers(layers)
+	if err != nil {
+		return nil, configError{err}
+	}
+
+	env, err := goBuildEnv()
+	if err != nil {
+		return nil, configError{err}
+	}
+	toolchain, err := installedGo(env)
+	if err != nil {
+		return nil, configError{err}
+	}
+	if toolchain.version != "" {
+		printf("Building with Go %s.\n\n", toolchain.version)
+	}
+
+	gitEnv := []string{}
+	secrets := []string{}
+	if *gitTokenEnvFlag != "" {
+		token := os.Getenv(*gitTokenEnvFlag)
+		if token == "" {
+			return nil, configErrorf(`environment variable "%s" is empty`, *gitTokenEnvFlag)
+		}
+		gitEnv = gitCredentialEnv(*gitHostFlag, *gitUsernameFlag, token)
+		secrets = append(secrets, token)
+		printf("Authenticating to %s with the token in %s.\n\n", *gitHostFlag, *gitTokenEnvFlag)
+	}
+	if *envAllowFlag != "" || *envDenyFlag != "" || len(envFlag) != 0 || *goprivateFlag != "" {
+		keys := envKeys(env)
+		printf("Passing (%d) environment variables to go build: %s.\n\n", len(keys), strings.Join(keys, ", "))
+	}
+
+	alias := *aliasFlag
+	createAlias := *createAliasFlag
+	if *previewFlag != "" && *latestOnlyFlag {
+		return nil, configErrorf(`flags "preview" and "latest-only" cannot be used together`)
+	}
+	var versionDescriptionTemplate *template.Template
+	if *versionDescriptionFlag != "" {
+		versionDescriptionTemplate, err = template.New("version-description").Option("missingkey=error").Parse(*versionDescriptionFlag)
+		if err != nil {
+			return nil, configErrorf(`flag "version-description" is not a valid template: %s`, err.Error())
+		}
+	}
+	if *canaryPercentFlag < 0 || *canaryPercentFlag >= 100 {
+		return nil, configErrorf(`flag "canary-percent" must be at least 0 and less than 100, got %g`, *canaryPercentFlag)
+	}
+	if *previewFlag != "" {
+		alias = previewAlias(*previewFlag)
+		aliasOverrides = map[string]string{}
+		createAlias = true
+		switch lambdaTypes.FunctionUrlAuthType(*previewURLAuthFlag) {
+		case lambdaTypes.FunctionUrlAuthTypeAwsIam, lambdaTypes.FunctionUrlAuthTypeNone:
+		default:
+			return nil, configErrorf(`flag "preview-url-auth" must be AWS_IAM or NONE, got "%s"`, *previewURLAuthFlag)
+		}
+		printf("Deploying preview for pull request %s to alias %s.\n\n", *previewFlag, alias)
+	}
+
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return nil, configError{err}
+	}
+	limitAWSConcurrency(&cfg, *awsConcurrencyFlag)
+	checkCredentialLifetime(context.TODO(), expectedRunTime(len(folders), *concurrencyFlag))
+
+	if *codeArtifactDomainFlag != "" {
+		if *codeArtifactRepositoryFlag == "" {
+			return nil, configErrorf(`flag "codeartifact-repository" is required with "codeartifact-domain"`)
+		}
+		proxyEnv, token, err := codeArtifactEnv(context.TODO(), cfg, *codeArtifactDomainFlag, *codeArtifactOwnerFlag, *codeArtifactRepositoryFlag)
+		if err != nil {
+			return nil, configError{err}
+		}
+		// like the git credentials, the token is only given to go build
+		gitEnv = append(gitEnv, proxyEnv...)
+		secrets = append(secrets, token)
+		printf("Downloading modules from CodeArtifact repository %s/%s.\n\n", *codeArtifactDomainFlag, *codeArtifactRepositoryFlag)
+	}
+
+	regions := splitList(*regionsFlag)
+	regionalCfgs := []aws.Config{}
+	if len(regions) != 0 {
+		err = checkRegionalBuckets(unsignedBucket, stagingBucket, signedBucket)
+		if err != nil {
+			return nil, configError{err}
+		}
+		regionalCfgs, err = loadRegionalAWSConfigs(cfg, regions)
+		if err != nil {
+			return nil, configError{err}
+		}
+		printf("Deploying to (%d) regions: %s.\n\n", len(regions), strings.Join(regions, ", "))
+	} else {
+		unsignedBucket = regionalBucket(unsignedBucket, cfg.Region)
+		stagingBucket = regionalBucket(stagingBucket, cfg.Region)
+		signedBucket = regionalBucket(signedBucket, cfg.Region)
+	}
+
+	s3Client := newS3Client(cfg)
+
+	signerClient := signer.NewFromConfig(cfg)
+	signingJobWaiter := newSigningJobWaiter(signerClient, *waiterMinDelayFlag, *waiterMaxDelayFlag)
+
+	lambdaClient := lambda.NewFromConfig(cfg)
+	functionUpdatedWaiter := newFunctionUpdatedWaiter(lambdaClient, *waiterMinDelayFlag, *waiterMaxDelayFlag)
+	publishedVersionWaiter := newPublishedVersionWaiter(lambdaClient)
+
+	runID := *runIDFlag
+	if runID == "" {
+		runID = newRunID()
+	}
+	printf("Starting run %s.\n\n", runID)
+	if *orderFlag == orderSlowestFirst {
+		durations, err := previousDurations(runID)
+		if err != nil {
+			printf("Not ordering folders by duration, failed to read previous runs: %s.\n\n", err.Error())
+		} else {
+			orderByDuration(levels, durations)
+		}
+	}
+	events, err := newEventLog(runID)
+	if err != nil {
+		printf("Not recording events, failed to create event log: %s.\n\n", err.Error())
+	}
+	defer events.close()
+
+	previousLock := lockFile{}
+	if *lockFileFlag != "" {
+		previousLock, err = readLockFile(*lockFileFlag)
+		if err != nil {
+			return nil, configError{err}
+		}
+	}
+
+	sha := gitSHA()
+	source := ""
+	if *sourceMetadataFlag {
+		source, err = gitSource()
+		if err != nil {
+			return nil, configErrorf("failed to get git remote and branch for -source-metadata: %s", err.Error())
+		}
+		printf("Deploying from %s.\n\n", source)
+	}
+	tags := defaultTags
+	var stamp buildStamp
+	if *stampFlag {
+		stamp = newBuildStamp(sha)
+		tags = map[string]string{}
+		for key, value := range defaultTags {
+			tags[key] = value
+		}
+		for key, value := range stamp.tags() {
+			tags[key] = value
+		}
+	}
+
+	d := &data{
+		// context to use in api calls
+		ctx: context.TODO(),
+		// provenance of this run
+		runID:  runID,
+		gitSHA: sha,
+		source: source,
+		// where to record the output of this run
+		events:     events,
+		metrics:    runMetrics,
+		trace:      runTrace,
+		report:     newRunReport(),
+		sizeReport: *sizeReportFlag,
+		units:      newReleaseUnits(),
+		// what each function runs after this run
+		lock:   lockFile{},
+		lockMu: &sync.Mutex{},
+		// tags to apply to every function and S3 object
+		tags:         tags,
+		stamp:        stamp,
+		stampPackage: *stampPackageFlag,
+		// what this run deploys, for the policy
+		folders:        folders,
+		configs:        folderConfigs,
+		functionPrefix: *functionPrefixFlag,
+		functionSuffix: *functionSuffixFlag,
+		environment:    *environmentFlag,
+		region:         cfg.Region,
+		// policy config
+		policy:      *policyFlag,
+		policyQuery: *policyQueryFlag,
+		approved:    *approveFlag,
+		// flags
+		noUpload:          *noUploadFlag,
+		noSigningJobs:     *noSignFlag,
+		noCopySigned:      *noCopySignedFlag,
+		noUpdateFunctions: *noUpdateFunctionsFlag,
+		createMissing:     *createMissingFlag,
+		force:             *forceFlag,
+		readOnly:          *readOnlyFlag,
+		// environment variables to pass to go build
+		env:           env,
+		goToolchain:   toolchain,
+		arch:          arch,
+		archOverrides: archOverrides,
+		gitEnv:        gitEnv,
+		secrets:       secrets,
+		builds:        newSemaphore(*buildConcurrencyFlag),
+		handler:       *handlerFlag,
+		zip:           zipCompressionFlags(),
+		artifacts:     artifacts,
+		hashCommand:   *hashCommandFlag,
+		// s3 config
+		s3:                 s3Client,
+		uploader:           newUploader(s3Client, *uploadPartSizeFlag, *uploadConcurrencyFlag),
+		uploadPartSize:     *uploadPartSizeFlag,
+		uploadConcurrency:  *uploadConcurrencyFlag,
+		unsignedBucket:     unsignedBucket,
+		stagingBucket:      stagingBucket,
+		signedBucket:       signedBucket,
+		unsignedPrefix:     *unsignedPrefixFlag,
+		stagingPrefix:      *stagingPrefixFlag,
+		signedPrefix:       *signedPrefixFlag,
+		toolsPrefix:        *toolsPrefixFlag,
+		debugSymbolsPrefix: *debugSymbolsPrefixFlag,
+		toolTargets:        tools,
+		functionLayers:     layersOfFunctions,
+		// signer config
+		signer:                  signerClient,
+		signingProfile:          *signingProfileFlag,
+		signingProfileOverrides: signingProfileOverrides,
+		signingJobWaiter:        signingJobWaiter,
+		signingTimeout:          *signingTimeoutFlag,
+		verifySigned:            *verifySignedFlag,
+		codeSigningConfig:       *codeSigningConfigFlag,
+		codeSigningPolicy:       lambdaTypes.CodeSigningPolicy(*codeSigningPolicyFlag),
+		codeSigningConfigs:      newCodeSigningConfigs(),
+		waiterMinDelay:          *waiterMinDelayFlag,
+		waiterMaxDelay:          *waiterMaxDelayFlag,
+		// lambda config
+		lambda:                     lambdaClient,
+		functionUpdatedWaiter:      functionUpdatedWaiter,
+		updateTimeout:              *updateTimeoutFlag,
+		publishedVersionWaiter:     publishedVersionWaiter,
+		publishTimeout:             *publishTimeoutFlag,
+		alias:                      alias,
+		aliasOverrides:             aliasOverrides,
+		createAlias:                createAlias,
+		aliasHistory:               *aliasHistoryFlag,
+		user:                       deployingUser(),
+		versionDescriptionTemplate: versionDescriptionTemplate,
+		latestOnly:                 *latestOnlyFlag,
+		verifyAliasTimeout:         *verifyAliasTimeoutFlag,
+		// provisioned concurrency config
+		provisionedConcurrencyTimeout: *provisionedConcurrencyTimeoutFlag,
+		// canary config
+		canaryPercent: *canaryPercentFlag,
+		canaryBake:    *canaryBakeFlag,
+		canaryAlarms:  splitList(*canaryAlarmsFlag),
+		cloudwatch:    cloudwatch.NewFromConfig(cfg),
+		// history config
+		history:      dynamodb.NewFromConfig(cfg),
+		historyTable: *historyTableFlag,
+		// preview config
+		preview:        *previewFlag,
+		previewURLAuth: lambdaTypes.FunctionUrlAuthType(*previewURLAuthFlag),
+		previewURLs:    map[string]string{},
+		previewURLsMu:  &sync.Mutex{},
+		previewTTL:     *previewTTLFlag,
+		// plan config
+		plan:         &planTotals{},
+		s3PricePerGB: *s3PricePerGBFlag,
+	}
+	for _, regionalCfg := range regionalCfgs {
+		d.regions = append(d.regions, d.inRegion(regionalCfg))
+	}
+	err = d.applySignerFallback(context.TODO(), cfg)
+	if err != nil {
+		return d, configError{err}
+	}
+	if !*readOnlyFlag {
+		for _, t := range d.targets() {
+			err := t.checkBucketRegions(context.TODO())
+			if err != nil {
+				return d, configError{err}
+			}
+		}
+	}
+	if signingEnabled() && !*readOnlyFlag && !*noUploadFlag {
+		for _, t := range d.targets() {
+			s := t.signingRun()
+			if !s.signsInRegion() {
+				continue
+			}
+			err := checkUnsignedBucketVersioning(context.TODO(), s.s3, s.unsignedBucket)
+			if err != nil {
+				return d, configError{err}
+			}
+		}
+	}
+	// layers are regional, and published before the functions that use them
+	for _, t := range d.targets() {
+		err := t.publishLayers(*layersDirFlag, layers)
+		if err != nil {
+			return d, err
+		}
+	}
+
+	// read-only runs change nothing worth notifying about
+	var notify notifiers
+	if !d.readOnly {
+		notify, err = flagNotifiers(d.ctx, cfg)
+		if err != nil {
+			return d, configError{err}
+		}
+	}
+	summary := runSummary{
+		RunID:       d.runID,
+		Environment: *environmentFlag,
+		Folders:     folders,
+		Start:       time.Now(),
+		report:      d.report,
+	}
+	notify.onRunStart(summary)
+
+	type result struct {
+		string
+		error
+	}
+	concurrency := *concurrencyFlag
+	if concurrency <= 0 || concurrency > len(folders) {
+		concurrency = len(folders)
+	}
+	printf("Deploying %d folders at once.\n\n", concurrency)
+	output.setTotal(len(folders))
+	if len(levels) > 1 {
+		printf("Deploying in (%d) levels of dependencies.\n\n", len(levels))
+	}
+	results := make(chan result, len(folders))
+	// closed once more folders fail than -max-failures allows, folders already running still finish
+	stop := make(chan struct{})
+	stopped := func() bool {
+		select {
+		case <-stop:
+			return true
+		default:
+			return false
+		}
+	}
+	go func() {
+		// each level starts once every folder of the previous level is done
+		failed := map[string]bool{}
+		for _, level := range levels {
+			jobs := make(chan string, len(level))
+			queued := 0
+			for _, folder := range level {
+				if dependency, ok := failedDependency(folder, failed); ok {
+					d.failf(folder, "run", errDependencyFailed, "Not deploying, %s failed.", dependency)
+					failed[folder] = true
+					results <- result{folder, errDependencyFailed}
+					continue
+				}
+				jobs <- folder
+				queued++
+			}
+			close(jobs)
+			levelResults := make(chan result, len(level))
+			workers := concurrency
+			if workers > len(level) {
+				workers = len(level)
+			}
+			for i := 0; i < workers; i++ {
+				go func() {
+					for folder := range jobs {
+						if stopped() {
+							d.skipf(folder, "run", "Not deploying, more than %s folders failed.", maxFailuresFlag.String())
+							levelResults <- result{folder, errCancelled}
+							continue
+						}
+						levelResults <- result{folder, d.run(folder)}
+					}
+				}()
+			}
+			for i := 0; i < queued; i++ {
+				r := <-levelResults
+				if r.error != nil {
+					failed[r.string] = true
+				}
+				results <- r
+			}
+		}
+	}()
+
+	numResults := 0
+	failures := []string{}
+	postConditionFailures := []string{}
+	cancelled := []string{}
+	failedFolders := 0
+	// folders that failed to build, or because a folder they depend on did, see exitBuildFailure
+	buildFailures := 0
+	for result := range results {
+		numResults++
+		if errors.Is(result.error, errCancelled) {
+			cancelled = append(cancelled, result.string)
+			for _, t := range d.targets() {
+				d.report.update(result.string, t.targetRegion, func(r *folderReport) { r.Status = reportCancelled })
+			}
+			if numResults == len(folders) {
+				close(results)
+			}
+			continue
+		}
+		if result.error != nil {
+			failedFolders++
+			if errors.As(result.error, &buildError{}) || errors.Is(result.error, errDependencyFailed) {
+				buildFailures++
+			}
+			if !stopped() && maxFailuresFlag.exceeded(failedFolders, len(folders)) {
+				printf("\n(%d) folders failed, more than %s, not deploying the rest.\n\n", failedFolders, maxFailuresFlag.String())
+				close(stop)
+			}
+		}
+		var errs regionErrors
+		if errors.As(result.error, &errs) {
+			// report each region the folder failed in
+			for region, err := range errs {
+				label := fmt.Sprintf("%s (%s)", result.string, region)
+				if errors.Is(err, errPostCondition) {
+					postConditionFailures = append(postConditionFailures, label)
+				} else {
+					failures = append(failures, label)
+				}
+			}
+		} else if errors.Is(result.error, errPostCondition) {
+			postConditionFailures = append(postConditionFailures, result.string)
+		} else if result.error != nil {
+			failures = append(failures, result.string)
+		}
+		notify.onFolderComplete(summary, result.string, result.error)
+		output.folderDone(result.string, result.error != nil)
+		for _, t := range d.targets() {
+			err := result.error
+			if errs != nil {
+				err = errs[t.region]
+			}
+			if err != nil {
+				d.report.fail(result.string, t.targetRegion)
+			}
+		}
+		if numResults == len(folders) {
+			close(results)
+		}
+	}
+
+	printf("\n")
+
+	// the aliases of a unit only move once every folder is done
+	unitFailures := d.releaseUnits(append(failures, postConditionFailures...))
+	failures = append(failures, unitFailures...)
+	output.flushAll()
+
+	d.printReport()
+	if *stepTotalsFlag {
+		d.metrics.printStepTotals()
+	}
+	if *outputFlag != "" {
+		err := d.writeManifest(*outputFlag)
+		if err != nil {
+			return d, err
+		}
+		printf("Wrote manifest to %s.\n\n", *outputFlag)
+	}
+	if *tfvarsFlag != "" && !d.readOnly && d.preview == "" {
+		err := d.writeTfvars(*tfvarsFlag)
+		if err != nil {
+			return d, err
+		}
+		printf("Updated Terraform variables in %s.\n\n", *tfvarsFlag)
+	}
+
+	if d.readOnly {
+		d.printPlanTotals()
+	}
+
+	// failed folders keep their previous entries
+	if *lockFileFlag != "" && !d.readOnly {
+		previousLock.merge(d.lock)
+		err := previousLock.write(*lockFileFlag)
+		if err != nil {
+			return d, err
+		}
+		printf("Updated lock file %s.\n\n", *lockFileFlag)
+	}
+
+	if d.preview != "" {
+		err := d.reportPreviewURLs()
+		if err != nil {
+			return d, err
+		}
+	}
+
+	if len(postConditionFailures) != 0 {
+		sort.Strings(postConditionFailures)
+		printf(
+			"Updated, but failed to verify (%d) folders: %s.\n\n",
+			len(postConditionFailures),
+			strings.Join(postConditionFailures, ", "),
+		)
+		failures = append(failures, postConditionFailures...)
+	}
+
+	sort.Strings(failures)
+	notify.onRunComplete(summary, failures)
+	if stopped() {
+		if len(cancelled) != 0 {
+			sort.Strings(cancelled)
+			printf("Did not deploy (%d) folders: %s.\n\n", len(cancelled), strings.Join(cancelled, ", "))
+		}
+		return d, fmt.Errorf("%w: %s", errTooManyFailures, strings.Join(failures, ", "))
+	}
+	if len(failures) != 0 {
+		return d, &folderFailures{
+			folders: failures,
+			partial: numResults-len(cancelled)-failedFolders-len(unitFailures) > 0,
+			build:   buildFailures == failedFolders && len(postConditionFailures) == 0 && len(unitFailures) == 0,
+		}
+	}
+	return d, nil
+}
+
+// Returns the Lambda folders to operate on, filtered by the include and exclude flags.
+func selectFolders() ([]string, error) {
+	include := splitList(*includeFlag)
+	if *foldersFlag != "" {
+		printf("Flag \"folders\" is deprecated, use \"include\" instead.\n\n")
+		include = append(include, splitList(*foldersFlag)...)
+	}
+	exclude := splitList(*excludeFlag)
+	folders, err := lambdaFolders(include, exclude)
+	if err != nil {
+		return nil, err
+	}
+	return expandMatrix(folders), nil
+}
+
+// Returns the one function passed to -function, or with -all the Lambda folders filtered by the include and exclude flags.
+// Commands that change live aliases use this so they never operate on every folder by accident.
+func selectFunctions() ([]string, error) {
+	switch {
+	case *allFlag && *functionFlag != "":
+		return nil, errors.New(`flags "all" and "function" cannot be used together`)
+	case *allFlag:
+		return selectFolders()
+	case *functionFlag != "":
+		return []string{folderOfFunction(*functionFlag)}, nil
+	}
+	return nil, errors.New(`flag "function" or "all" is required`)
+}
+
+// Loads the AWS config using the region and profile flags, then assumes the role to deploy with if there is one.
+// Returns an error if the account or region do not match the environment flag.
+func loadAWSConfig() (aws.Config, error) {
+	var opts []func(*config.LoadOptions) error
+	if *regionFlag != "" {
+		opts = append(opts, config.WithRegion(*regionFlag))
+	}
+	if *profileFlag != "" {
+		opts = append(opts, config.WithSharedConfigProfile(*profileFlag))
+	}
+	opts = append(opts, config.WithCredentialsCacheOptions(refreshEarly))
+	cfg, err := config.LoadDefaultConfig(context.TODO(), opts...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	retryAWSCalls(&cfg, *maxAttemptsFlag, *maxBackoffFlag)
+	err = useCustomEndpoints(&cfg)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	regions := splitList(*regionsFlag)
+	if len(regions) == 0 {
+		regions = []string{cfg.Region}
+	}
+	err = checkPartition(regions, *environmentFlag)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	sourceCredentials = cfg.Credentials
+	if roleARN, externalID := roleToAssume(); roleARN != "" {
+		printf("Assuming role %s as session %s.\n\n", roleARN, *sessionNameFlag)
+		assumeRole(&cfg, roleARN, externalID, *sessionNameFlag)
+	}
+	err = checkGuardrails(context.TODO(), cfg, *environmentFlag)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	return cfg, nil
+}
+
+// Returns true if the slice contains the string.
+func contains(strs []string, match string) bool {
+	for _, str := range strs {
+		if str == match {
+			return true
+		}
+	}
+	return false
+}
+
+// https://stackoverflow.com/questions/64590042/split-a-slice-into-n-slices
+func spread(folders []string, numInstances int) [][]string {
+	chunks := make([][]string, 0, numInstances)
+	defSize := len(folders) / numInstances
+	numBigger := len(folders) - defSize*numInstances
+	size := defSize + 1
+	for i, idx := 0, 0; i < numInstances; i++ {
+		if i == numBigger {
+			size--
+			if size == 0 {
+				break // no folders left to scan
+			}
+		}
+		// fmt.Println(idx, idx+size)
+		chunks = append(chunks, folders[idx:idx+size])
+		idx += size
+	}
+	return chunks
+}
@@ -0,0 +1,102 @@
+package deploy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// One build of a folder in its matrix, deployed to its own function.
+//
+//	folder "testLambda01" {
+//	  matrix "tenant-a" {
+//	    function = "testLambda01-tenant-a"
+//	    tags     = ["tenant_a"]
+//	    vars     = { "main.tenant" = "a" }
+//	  }
+//	}
+//
+// A folder with a matrix only deploys its matrix builds, each like a folder named after its function.
+// The function defaults to the folder and the name joined with a dash.
+// Tags are passed to go build -tags, and vars are set with -ldflags=-X.
+// Every other option of the folder block applies to each build.
+type matrixConfig struct {
+	Name     string            `hcl:"name,label"`
+	Function string            `hcl:"function,optional"`
+	Tags     []string          `hcl:"tags,optional"`
+	Vars     map[string]string `hcl:"vars,optional"`
+	// the folder the build is of, set when the config file is read
+	folder string
+}
+
+// Matrix builds read from the config file, keyed by function.
+var matrixBuilds = map[string]matrixConfig{}
+
+// Adds the matrix builds of the folder block, each with the options of the folder.
+func addMatrixBuilds(fc folderConfig) error {
+	for _, mc := range fc.Matrix {
+		if mc.Function == "" {
+			mc.Function = fc.Name + "-" + mc.Name
+		}
+		if _, ok := folderConfigs[mc.Function]; ok {
+			return fmt.Errorf(`folder "%s": matrix "%s": function "%s" is already configured`, fc.Name, mc.Name, mc.Function)
+		}
+		mc.folder = fc.Name
+		matrixBuilds[mc.Function] = mc
+		build := fc
+		build.Name = mc.Function
+		build.Function = mc.Function
+		build.Matrix = nil
+		folderConfigs[mc.Function] = build
+	}
+	return nil
+}
+
+// Replaces each folder that has a matrix with its matrix builds.
+func expandMatrix(folders []string) []string {
+	expanded := []string{}
+	for _, folder := range folders {
+		builds := []string{}
+		for function, mc := range matrixBuilds {
+			if mc.folder == folder {
+				builds = append(builds, function)
+			}
+		}
+		if len(builds) == 0 {
+			expanded = append(expanded, folder)
+			continue
+		}
+		sort.Strings(builds)
+		printf("Building %s (%d) times: %s.\n\n", folder, len(builds), strings.Join(builds, ", "))
+		expanded = append(expanded, builds...)
+	}
+	return expanded
+}
+
+// Returns the folder with the source code of the folder or matrix build.
+func sourceFolder(folder string) string {
+	if mc, ok := matrixBuilds[folder]; ok {
+		return mc.folder
+	}
+	return folder
+}
+
+// Returns the go build -tags of the matrix build, empty for folders.
+func buildTags(folder string) string {
+	return strings.Join(matrixBuilds[folder].Tags, ",")
+}
+
+// Returns the -X flags that set the vars of the matrix build, in order, empty for folders.
+func buildVars(folder string) []string {
+	vars := matrixBuilds[folder].Vars
+	names := []string{}
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	flags := []string{}
+	for _, name := range names {
+		flags = append(flags, "-X", name+"="+vars[name])
+	}
+	return flags
+}
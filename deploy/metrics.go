@@ -0,0 +1,222 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// The upper bounds in seconds of the buckets of each step's histogram.
+var stepBuckets = []float64{1, 2, 5, 10, 30, 60, 120, 300, 600}
+
+// Times the steps of every folder and collects how long each step took across all folders.
+// Safe to use from multiple goroutines. Every run and command shares runMetrics.
+type metrics struct {
+	mu    sync.Mutex
+	start time.Time
+	// when the running step of each folder started, keyed by folder, region, and step
+	starts map[string]time.Time
+	// keyed by step
+	histograms map[string]*histogram
+	// how long each step of each folder took, in the order the steps ran, keyed by folder and region
+	folders map[string][]stepDuration
+	// how many times each step failed, keyed by step
+	failures map[string]int
+}
+
+// How long one step of one folder took in total.
+type stepDuration struct {
+	step string
+	took time.Duration
+}
+
+// How long one step took across all folders.
+type histogram struct {
+	count int
+	sum   float64
+	min   float64
+	max   float64
+	// counts of the durations at most each of stepBuckets, not cumulative
+	buckets []int
+}
+
+var runMetrics = newMetrics()
+
+func newMetrics() *metrics {
+	return &metrics{
+		start:      time.Now(),
+		starts:     map[string]time.Time{},
+		histograms: map[string]*histogram{},
+		folders:    map[string][]stepDuration{},
+		failures:   map[string]int{},
+	}
+}
+
+// Returns how long the step has been running, starting from its first event.
+// Once the step is done, failed, or skipped, records how long it took,
+// and the next event of the step starts it again.
+func (m *metrics) observe(folder, region, step, status string, now time.Time) time.Duration {
+	if m == nil {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := folder + "/" + region + "/" + step
+	start, ok := m.starts[key]
+	if !ok {
+		start = now
+		m.starts[key] = now
+	}
+	took := now.Sub(start)
+	if status != statusRunning {
+		delete(m.starts, key)
+		m.record(step, took)
+		m.recordFolder(folder+"/"+region, step, took)
+	}
+	if status == statusFailed {
+		m.failures[step]++
+	}
+	return took
+}
+
+func (m *metrics) record(step string, took time.Duration) {
+	h, ok := m.histograms[step]
+	if !ok {
+		h = &histogram{buckets: make([]int, len(stepBuckets))}
+		m.histograms[step] = h
+	}
+	seconds := took.Seconds()
+	if h.count == 0 || seconds < h.min {
+		h.min = seconds
+	}
+	h.count++
+	h.sum += seconds
+	if seconds > h.max {
+		h.max = seconds
+	}
+	for i, bound := range stepBuckets {
+		if seconds <= bound {
+			h.buckets[i]++
+			break
+		}
+	}
+}
+
+func (m *metrics) recordFolder(key, step string, took time.Duration) {
+	steps := m.folders[key]
+	for i := range steps {
+		if steps[i].step == step {
+			steps[i].took += took
+			return
+		}
+	}
+	m.folders[key] = append(steps, stepDuration{step, took})
+}
+
+// Returns how long each step of the folder took in the region, in the order the steps ran.
+func (m *metrics) folderSteps(folder, region string) []stepDuration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]stepDuration{}, m.folders[folder+"/"+region]...)
+}
+
+// Formats the duration for printing, e.g. 2m5s042ms. Runs longer than an hour print 75m0s000ms.
+func formatDuration(took time.Duration) string {
+	took = took.Round(time.Millisecond)
+	minutes := took / time.Minute
+	seconds := (took % time.Minute) / time.Second
+	milliseconds := (took % time.Second) / time.Millisecond
+	return fmt.Sprintf("%dm%ds%03dms", minutes, seconds, milliseconds)
+}
+
+// Returns how long since the run started.
+func (m *metrics) elapsed() time.Duration {
+	return time.Since(m.start)
+}
+
+// Prints how long each step took across all folders, slowest first, to show where the time of the run goes.
+//
+//	STEP   COUNT  TOTAL        MEAN        MAX
+//	sign   42     3m10s500ms   0m4s536ms   0m9s120ms
+func (m *metrics) printStepTotals() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	steps := []string{}
+	for step := range m.histograms {
+		steps = append(steps, step)
+	}
+	sort.Slice(steps, func(i, j int) bool { return m.histograms[steps[i]].sum > m.histograms[steps[j]].sum })
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STEP\tCOUNT\tTOTAL\tMEAN\tMAX")
+	for _, step := range steps {
+		h := m.histograms[step]
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", step, h.count,
+			formatDuration(seconds(h.sum)), formatDuration(seconds(h.sum/float64(h.count))), formatDuration(seconds(h.max)))
+	}
+	w.Flush()
+	printf("%s\n", buf.String())
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Writes the histograms in the Prometheus text format, e.g. for the node exporter's textfile collector.
+//
+//	builder_step_duration_seconds_bucket{step="build",le="30"} 12
+func (m *metrics) writePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	steps := []string{}
+	for step := range m.histograms {
+		steps = append(steps, step)
+	}
+	sort.Strings(steps)
+	_, err := fmt.Fprintf(w, "# HELP builder_step_duration_seconds How long each step of each folder took.\n# TYPE builder_step_duration_seconds histogram\n")
+	if err != nil {
+		return err
+	}
+	for _, step := range steps {
+		h := m.histograms[step]
+		cumulative := 0
+		for i, bound := range stepBuckets {
+			cumulative += h.buckets[i]
+			_, err := fmt.Fprintf(w, "builder_step_duration_seconds_bucket{step=%q,le=\"%g\"} %d\n", step, bound, cumulative)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintf(w,
+			"builder_step_duration_seconds_bucket{step=%q,le=\"+Inf\"} %d\nbuilder_step_duration_seconds_sum{step=%q} %g\nbuilder_step_duration_seconds_count{step=%q} %d\n",
+			step, h.count, step, h.sum, step, h.count,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "# HELP builder_run_duration_seconds How long the run took.\n# TYPE builder_run_duration_seconds gauge\nbuilder_run_duration_seconds %g\n", m.elapsed().Seconds())
+	return err
+}
+
+// Writes the histograms to path in the Prometheus text format, if path is not empty.
+func (m *metrics) writePrometheusFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = m.writePrometheus(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
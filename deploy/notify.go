@@ -0,0 +1,268 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventbridgeTypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snsTypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// Told about the progress of a deploy, e.g. to post it to Slack.
+// Notifiers are called from one goroutine, in the order the folders complete.
+// A notifier that fails only prints why, it never fails the deploy.
+type notifier interface {
+	onRunStart(run runSummary)
+	onFolderComplete(run runSummary, folder string, err error)
+	onRunComplete(run runSummary, failures []string)
+}
+
+// What notifiers are told about the run.
+type runSummary struct {
+	RunID       string    `json:"runId"`
+	Environment string    `json:"environment,omitempty"`
+	Folders     []string  `json:"folders"`
+	Start       time.Time `json:"start"`
+	// what happened to each folder, for the summary of the run
+	report *runReport
+}
+
+// Returns what each deployed folder's alias moved to, e.g. testLambda01 (us-east-1) TEST: 41 → 42.
+func (run runSummary) deployed() []string {
+	lines := []string{}
+	if run.report == nil {
+		return lines
+	}
+	run.report.mu.Lock()
+	defer run.report.mu.Unlock()
+	for _, key := range run.report.keys() {
+		fr := run.report.folders[key]
+		if fr.Status != reportDeployed || fr.Version == "" {
+			continue
+		}
+		folder := fr.Folder
+		if fr.Region != "" {
+			folder += " (" + fr.Region + ")"
+		}
+		if fr.Alias == "" {
+			lines = append(lines, fmt.Sprintf("%s %s", folder, fr.Version))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", folder, aliasChange(fr.Alias, fr.PreviousVersion, fr.Version)))
+	}
+	return lines
+}
+
+// Returns the summary of the completed run posted by notifiers.
+//
+//	Run 123 deployed (2) folders to prod in 1m12s.
+//	testLambda01 TEST: 41 → 42
+//	testLambda02 TEST: 7 → 8
+func summaryText(run runSummary, failures []string) string {
+	took := time.Since(run.Start).Round(time.Second)
+	lines := []string{fmt.Sprintf("Run %s deployed (%d) folders%s in %s.", run.RunID, len(run.Folders), inEnvironment(run), took)}
+	if len(failures) != 0 {
+		lines = []string{
+			fmt.Sprintf("Run %s failed to deploy (%d) of (%d) folders%s in %s: %s.",
+				run.RunID, len(failures), len(run.Folders), inEnvironment(run), took, strings.Join(failures, ", ")),
+		}
+	}
+	return strings.Join(append(lines, run.deployed()...), "\n")
+}
+
+// Returns the notifiers the flags ask for.
+func flagNotifiers(ctx context.Context, cfg aws.Config) (notifiers, error) {
+	n := notifiers{}
+	if *slackWebhookEnvFlag != "" {
+		url := os.Getenv(*slackWebhookEnvFlag)
+		if url == "" {
+			return nil, fmt.Errorf(`environment variable "%s" is empty`, *slackWebhookEnvFlag)
+		}
+		n = append(n, &slackNotifier{ctx: ctx, url: url})
+	}
+	if *notifySlackWebhookFlag != "" {
+		n = append(n, &slackNotifier{ctx: ctx, url: *notifySlackWebhookFlag})
+	}
+	if *notifySNSTopicFlag != "" {
+		n = append(n, &snsNotifier{ctx: ctx, client: sns.NewFromConfig(cfg), topic: *notifySNSTopicFlag})
+	}
+	if *eventBusFlag != "" {
+		n = append(n, &eventBridgeNotifier{ctx: ctx, client: eventbridge.NewFromConfig(cfg), bus: *eventBusFlag})
+	}
+	return n, nil
+}
+
+// Tells every notifier in turn.
+type notifiers []notifier
+
+func (n notifiers) onRunStart(run runSummary) {
+	for _, notifier := range n {
+		notifier.onRunStart(run)
+	}
+}
+
+func (n notifiers) onFolderComplete(run runSummary, folder string, err error) {
+	for _, notifier := range n {
+		notifier.onFolderComplete(run, folder, err)
+	}
+}
+
+func (n notifiers) onRunComplete(run runSummary, failures []string) {
+	for _, notifier := range n {
+		notifier.onRunComplete(run, failures)
+	}
+}
+
+// Posts the start and end of the run to a Slack incoming webhook.
+// Only failed folders are posted, so large runs do not flood the channel.
+type slackNotifier struct {
+	ctx context.Context
+	url string
+}
+
+func (s *slackNotifier) onRunStart(run runSummary) {
+	s.post(fmt.Sprintf("Run %s started deploying (%d) folders%s.", run.RunID, len(run.Folders), inEnvironment(run)))
+}
+
+func (s *slackNotifier) onFolderComplete(run runSummary, folder string, err error) {
+	if err != nil {
+		s.post(fmt.Sprintf("Run %s failed to deploy %s%s: %s", run.RunID, folder, inEnvironment(run), err.Error()))
+	}
+}
+
+func (s *slackNotifier) onRunComplete(run runSummary, failures []string) {
+	s.post(summaryText(run, failures))
+}
+
+func (s *slackNotifier) post(text string) {
+	payload, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		printf("Failed to notify Slack: %s.\n", err.Error())
+		return
+	}
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		printf("Failed to notify Slack: %s.\n", err.Error())
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		printf("Failed to notify Slack: %s.\n", err.Error())
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		printf("Failed to notify Slack: Slack responded with %s.\n", res.Status)
+	}
+}
+
+func inEnvironment(run runSummary) string {
+	if run.Environment == "" {
+		return ""
+	}
+	return " to " + run.Environment
+}
+
+// Publishes a summary of the run to an SNS topic when it completes, e.g. to email it.
+// Subscribers can filter on the "status" message attribute, succeeded or failed.
+type snsNotifier struct {
+	ctx    context.Context
+	client *sns.Client
+	topic  string
+}
+
+// SNS rejects subjects longer than this.
+const maxSNSSubject = 100
+
+func (s *snsNotifier) onRunStart(run runSummary) {}
+
+func (s *snsNotifier) onFolderComplete(run runSummary, folder string, err error) {}
+
+func (s *snsNotifier) onRunComplete(run runSummary, failures []string) {
+	status := "succeeded"
+	if len(failures) != 0 {
+		status = "failed"
+	}
+	subject := fmt.Sprintf("go-lambda-builder run %s %s%s", run.RunID, status, inEnvironment(run))
+	if len(subject) > maxSNSSubject {
+		subject = subject[:maxSNSSubject]
+	}
+	_, err := s.client.Publish(s.ctx, &sns.PublishInput{
+		TopicArn: aws.String(s.topic),
+		Subject:  aws.String(subject),
+		Message:  aws.String(summaryText(run, failures)),
+		MessageAttributes: map[string]snsTypes.MessageAttributeValue{
+			"status": {DataType: aws.String("String"), StringValue: aws.String(status)},
+		},
+	})
+	if err != nil {
+		printf("Failed to publish summary to SNS: %s.\n", err.Error())
+	}
+}
+
+// Puts an event on an EventBridge bus for the start and end of the run and for every folder.
+//
+//	{"source": ["go-lambda-builder"], "detail-type": ["Folder Completed"], "detail": {"status": ["failed"]}}
+type eventBridgeNotifier struct {
+	ctx    context.Context
+	client *eventbridge.Client
+	bus    string
+}
+
+func (e *eventBridgeNotifier) onRunStart(run runSummary) {
+	e.put("Run Started", run)
+}
+
+func (e *eventBridgeNotifier) onFolderComplete(run runSummary, folder string, err error) {
+	detail := map[string]string{
+		"runId":  run.RunID,
+		"folder": folder,
+		"status": statusDone,
+	}
+	if err != nil {
+		detail["status"] = statusFailed
+		detail["error"] = err.Error()
+	}
+	e.put("Folder Completed", detail)
+}
+
+func (e *eventBridgeNotifier) onRunComplete(run runSummary, failures []string) {
+	e.put("Run Completed", struct {
+		runSummary
+		Failures []string `json:"failures"`
+		Duration float64  `json:"duration"`
+	}{run, failures, time.Since(run.Start).Seconds()})
+}
+
+func (e *eventBridgeNotifier) put(detailType string, detail interface{}) {
+	b, err := json.Marshal(detail)
+	if err != nil {
+		printf("Failed to put %s event: %s.\n", detailType, err.Error())
+		return
+	}
+	output, err := e.client.PutEvents(e.ctx, &eventbridge.PutEventsInput{
+		Entries: []eventbridgeTypes.PutEventsRequestEntry{{
+			EventBusName: aws.String(e.bus),
+			Source:       aws.String("go-lambda-builder"),
+			DetailType:   aws.String(detailType),
+			Detail:       aws.String(string(b)),
+		}},
+	})
+	if err != nil {
+		printf("Failed to put %s event: %s.\n", detailType, err.Error())
+		return
+	}
+	if output.FailedEntryCount != 0 {
+		printf("Failed to put %s event: %s.\n", detailType, aws.ToString(output.Entries[0].ErrorMessage))
+	}
+}
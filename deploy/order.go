@@ -0,0 +1,178 @@
+package deploy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Errors of folders that were not deployed because a folder they depend on failed.
+var errDependencyFailed = errors.New("a folder it depends on failed")
+
+// Returns the folders grouped into levels, where every folder only depends on folders in earlier levels.
+// The folders of a level are deployed at once, after every folder of the previous level.
+//
+//	folder "api" {
+//	  depends-on = ["authorizer"]
+//	}
+//
+// Dependencies that are not being deployed are ignored.
+// Returns an error if the dependencies have a cycle.
+func deployLevels(folders []string) ([][]string, error) {
+	dependencies := map[string][]string{}
+	for _, folder := range folders {
+		for _, dependency := range folderConfigs[folder].DependsOn {
+			if contains(folders, dependency) {
+				dependencies[folder] = append(dependencies[folder], dependency)
+			}
+		}
+	}
+	levels := [][]string{}
+	placed := map[string]bool{}
+	for len(placed) != len(folders) {
+		level := []string{}
+		for _, folder := range folders {
+			if !placed[folder] && allPlaced(dependencies[folder], placed) {
+				level = append(level, folder)
+			}
+		}
+		if len(level) == 0 {
+			cycle := []string{}
+			for _, folder := range folders {
+				if !placed[folder] {
+					cycle = append(cycle, folder)
+				}
+			}
+			sort.Strings(cycle)
+			return nil, fmt.Errorf("folders depend on each other in a cycle: %s", strings.Join(cycle, ", "))
+		}
+		for _, folder := range level {
+			placed[folder] = true
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+func allPlaced(folders []string, placed map[string]bool) bool {
+	for _, folder := range folders {
+		if !placed[folder] {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the first dependency of the folder that failed, if any.
+func failedDependency(folder string, failed map[string]bool) (string, bool) {
+	for _, dependency := range folderConfigs[folder].DependsOn {
+		if failed[dependency] {
+			return dependency, true
+		}
+	}
+	return "", false
+}
+
+// Orders of the folders of each level, see -order.
+const (
+	orderSlowestFirst = "slowest-first"
+	orderName         = "name"
+)
+
+// How many of the most recent runs to average each folder's duration over.
+const orderRuns = 5
+
+// Orders the folders of each level slowest first, by how long each took in the most recent runs,
+// so the slowest folders do not start last and hold up the end of the run.
+// Folders that were not deployed in any of the runs go first, since they may be slow.
+func orderByDuration(levels [][]string, durations map[string]time.Duration) {
+	for _, level := range levels {
+		sort.SliceStable(level, func(i, j int) bool {
+			a, aok := durations[level[i]]
+			b, bok := durations[level[j]]
+			if aok != bok {
+				return !aok
+			}
+			return a > b
+		})
+	}
+}
+
+// Returns how long each folder took on average in the most recent runs recorded in the events directory,
+// from its first event to its last, across every region. Skips the run with the ID.
+func previousDurations(runID string) (map[string]time.Duration, error) {
+	dir, err := eventsDir()
+	if err != nil {
+		return nil, err
+	}
+	runIDs, err := recentRunIDs(dir)
+	if err != nil {
+		return nil, err
+	}
+	totals := map[string]time.Duration{}
+	counts := map[string]int{}
+	runs := 0
+	for _, id := range runIDs {
+		if runs == orderRuns {
+			break
+		}
+		if id == runID {
+			continue
+		}
+		durations, err := runDurations(filepath.Join(dir, id+".jsonl"))
+		if err != nil {
+			return nil, err
+		}
+		if len(durations) == 0 {
+			continue
+		}
+		runs++
+		for folder, took := range durations {
+			totals[folder] += took
+			counts[folder]++
+		}
+	}
+	averages := map[string]time.Duration{}
+	for folder, total := range totals {
+		averages[folder] = total / time.Duration(counts[folder])
+	}
+	return averages, nil
+}
+
+// Returns how long each folder took in the event log, from its first event to its last.
+// Skips lines that are not events, e.g. the last line of a run that is still being written.
+func runDurations(path string) (map[string]time.Duration, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	first := map[string]time.Time{}
+	last := map[string]time.Time{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		e := event{}
+		if json.Unmarshal(scanner.Bytes(), &e) != nil || e.Folder == "" {
+			continue
+		}
+		if _, ok := first[e.Folder]; !ok {
+			first[e.Folder] = e.Time
+		}
+		last[e.Folder] = e.Time
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	durations := map[string]time.Duration{}
+	for folder, start := range first {
+		durations[folder] = last[folder].Sub(start)
+	}
+	return durations, nil
+}
@@ -0,0 +1,88 @@
+package deploy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Replaces the folder blocks of the config file for the test.
+func useFolderConfigs(t *testing.T, configs map[string]folderConfig) {
+	previous := folderConfigs
+	folderConfigs = configs
+	t.Cleanup(func() { folderConfigs = previous })
+}
+
+func TestDeployLevels(t *testing.T) {
+	tests := []struct {
+		name      string
+		folders   []string
+		dependsOn map[string][]string
+		want      [][]string
+		wantErr   string
+	}{
+		{
+			name:    "independent",
+			folders: []string{"a", "b", "c"},
+			want:    [][]string{{"a", "b", "c"}},
+		},
+		{
+			name:      "chain",
+			folders:   []string{"a", "b", "c"},
+			dependsOn: map[string][]string{"a": {"b"}, "b": {"c"}},
+			want:      [][]string{{"c"}, {"b"}, {"a"}},
+		},
+		{
+			name:      "diamond",
+			folders:   []string{"api", "auth", "db", "web"},
+			dependsOn: map[string][]string{"api": {"auth", "db"}, "auth": {"db"}, "web": {"api", "auth"}},
+			want:      [][]string{{"db"}, {"auth"}, {"api"}, {"web"}},
+		},
+		{
+			name:      "siblings",
+			folders:   []string{"a", "b", "c", "d"},
+			dependsOn: map[string][]string{"b": {"a"}, "c": {"a"}, "d": {"b", "c"}},
+			want:      [][]string{{"a"}, {"b", "c"}, {"d"}},
+		},
+		{
+			name:      "cycle",
+			folders:   []string{"a", "b", "c", "d"},
+			dependsOn: map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}},
+			wantErr:   "folders depend on each other in a cycle: a, b, c",
+		},
+		{
+			name:      "self",
+			folders:   []string{"a"},
+			dependsOn: map[string][]string{"a": {"a"}},
+			wantErr:   "cycle: a",
+		},
+		{
+			name:      "unknown dependency",
+			folders:   []string{"a", "b"},
+			dependsOn: map[string][]string{"a": {"missing"}, "b": {"a"}},
+			want:      [][]string{{"a"}, {"b"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs := map[string]folderConfig{}
+			for folder, dependsOn := range tt.dependsOn {
+				configs[folder] = folderConfig{Name: folder, DependsOn: dependsOn}
+			}
+			useFolderConfigs(t, configs)
+			got, err := deployLevels(tt.folders)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How lines of output of each folder are printed, see -log-mode.
+const (
+	// every line as it happens, interleaved with the lines of other folders
+	logModeStream = "stream"
+	// the lines of each folder in one block, once the folder is done
+	logModeGrouped = "grouped"
+	// one status line per folder, updated in place, on a terminal
+	logModeLive = "live"
+)
+
+// Prints the lines of output of each folder in the -log-mode.
+// Safe to use from multiple goroutines.
+type folderOutput struct {
+	mu   sync.Mutex
+	mode string
+	// the lines of each folder that are not printed yet, in grouped mode
+	buffers map[string]*bytes.Buffer
+	// the folders in the order they first printed, and the last line of each, in live mode
+	order  []string
+	status map[string]string
+	// the folders that are done, and whether each failed
+	done map[string]bool
+	// how many folders the run deploys, for the progress line
+	total int
+	// the frame of the spinner of running folders
+	frame int
+	// how many status lines are on the terminal
+	drawn int
+}
+
+// Frames of the spinner in front of each running folder in live mode.
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// How often the spinner turns.
+const spinnerInterval = 100 * time.Millisecond
+
+var output = newFolderOutput(logModeStream)
+
+func newFolderOutput(mode string) *folderOutput {
+	return &folderOutput{mode: mode, buffers: map[string]*bytes.Buffer{}, status: map[string]string{}, done: map[string]bool{}}
+}
+
+// Switches to the -log-mode. Live mode needs a terminal, and falls back to grouped mode without one.
+func useLogMode(mode string) {
+	if mode == logModeLive && !isTerminal(logOutput) {
+		mode = logModeGrouped
+	}
+	output = newFolderOutput(mode)
+	if mode == logModeLive {
+		go output.spin()
+	}
+}
+
+// Turns the spinner until the end of the run.
+func (o *folderOutput) spin() {
+	ticker := time.NewTicker(spinnerInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		o.mu.Lock()
+		if o.mode != logModeLive {
+			o.mu.Unlock()
+			return
+		}
+		o.frame = (o.frame + 1) % len(spinnerFrames)
+		if o.drawn != 0 {
+			o.draw()
+		}
+		o.mu.Unlock()
+	}
+}
+
+// Sets how many folders the run deploys, for the progress line in live mode.
+func (o *folderOutput) setTotal(total int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.total = total
+}
+
+// Returns true if w is a terminal, so lines can be rewritten in place.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+}
+
+// Prints a line of output of the folder, including its trailing newline.
+func (o *folderOutput) line(folder, line string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	switch o.mode {
+	case logModeGrouped:
+		b, ok := o.buffers[folder]
+		if !ok {
+			b = &bytes.Buffer{}
+			o.buffers[folder] = b
+		}
+		b.WriteString(line)
+	case logModeLive:
+		if _, ok := o.status[folder]; !ok {
+			o.order = append(o.order, folder)
+		}
+		o.status[folder] = strings.TrimRight(line, "\n")
+		o.draw()
+	default:
+		io.WriteString(logOutput, line)
+	}
+}
+
+// Prints output that is not a line of a folder, keeping the status lines below it in live mode.
+func (o *folderOutput) print(s string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.mode != logModeLive || o.drawn == 0 {
+		io.WriteString(logOutput, s)
+		return
+	}
+	o.clear()
+	io.WriteString(logOutput, s)
+	o.draw()
+}
+
+// Prints the lines the folder buffered once it is done, or marks its status line as done or failed in live mode.
+func (o *folderOutput) folderDone(folder string, failed bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.done[folder] = failed
+	if o.mode == logModeLive && o.drawn != 0 {
+		o.draw()
+	}
+	if b, ok := o.buffers[folder]; ok {
+		io.WriteString(logOutput, b.String())
+		delete(o.buffers, folder)
+	}
+}
+
+// Prints every line not printed yet and leaves the status lines as they are, at the end of the run.
+// Output after this is printed as it happens.
+func (o *folderOutput) flushAll() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	folders := []string{}
+	for folder := range o.buffers {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+	for _, folder := range folders {
+		io.WriteString(logOutput, o.buffers[folder].String())
+	}
+	o.buffers = map[string]*bytes.Buffer{}
+	o.drawn = 0
+	o.mode = logModeStream
+}
+
+// Rewrites the status lines in place. Expects the lock to be held.
+func (o *folderOutput) draw() {
+	b := &strings.Builder{}
+	if o.drawn != 0 {
+		fmt.Fprintf(b, "\033[%dA", o.drawn)
+	}
+	// leaves room for the spinner
+	width := terminalWidth() - 2
+	for _, folder := range o.order {
+		symbol := spinnerFrames[o.frame]
+		if failed, ok := o.done[folder]; ok && failed {
+			symbol = colorize(ansiRed, "✗")
+		} else if ok {
+			symbol = colorize(ansiGreen, "✓")
+		}
+		line := truncateLine(o.status[folder], width)
+		fmt.Fprintf(b, "\r\033[K%s %s\n", symbol, line)
+	}
+	o.drawn = len(o.order)
+	if o.total != 0 {
+		fmt.Fprintf(b, "\r\033[K(%d/%d) folders done.\n", len(o.done), o.total)
+		o.drawn++
+	}
+	io.WriteString(logOutput, b.String())
+}
+
+// Cuts the line to width columns, ending it with an ellipsis if it was cut.
+// Escape sequences that color the line take up no columns and are never cut in half,
+// and with color a cut line ends with a reset so its color does not spill into the next line.
+func truncateLine(line string, width int) string {
+	runes := []rune(line)
+	if visibleWidth(runes) <= width {
+		return line
+	}
+	visible := 0
+	for i := 0; i < len(runes); i++ {
+		if n := escapeLength(runes[i:]); n > 0 {
+			i += n - 1
+			continue
+		}
+		if visible == width-1 && useColor {
+			return string(runes[:i]) + "…" + ansiReset
+		} else if visible == width-1 {
+			return string(runes[:i]) + "…"
+		}
+		visible++
+	}
+	return line
+}
+
+// Returns how many columns the runes take up, not counting escape sequences.
+func visibleWidth(runes []rune) int {
+	width := 0
+	for i := 0; i < len(runes); i++ {
+		if n := escapeLength(runes[i:]); n > 0 {
+			i += n - 1
+			continue
+		}
+		width++
+	}
+	return width
+}
+
+// Returns the length of the escape sequence the runes start with, e.g. 5 for \033[31m, or 0 if they do not start with one.
+func escapeLength(runes []rune) int {
+	if len(runes) < 2 || runes[0] != '\033' || runes[1] != '[' {
+		return 0
+	}
+	for i := 2; i < len(runes); i++ {
+		// the final byte of the sequence, e.g. the m of \033[31m
+		if runes[i] >= 0x40 && runes[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(runes)
+}
+
+// Erases the status lines. Expects the lock to be held.
+func (o *folderOutput) clear() {
+	fmt.Fprintf(logOutput, "\033[%dA\033[J", o.drawn)
+	o.drawn = 0
+}
+
+// Returns how many columns the terminal has, from COLUMNS, or 100 if it is not set.
+func terminalWidth() int {
+	width, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil || width < 20 {
+		return 100
+	}
+	return width
+}
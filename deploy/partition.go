@@ -0,0 +1,113 @@
+package deploy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// What differs between the AWS partitions a repo can deploy to.
+// Credentials, buckets, and roles of one partition cannot be used in another,
+// so every region of a run must be in the same partition.
+type partition struct {
+	// the prefix of the partition's regions, empty for the standard partition
+	regionPrefix string
+	// the domain of endpoints that are not resolved by an SDK client, e.g. CodeArtifact's
+	dnsSuffix string
+	// Lambda code signing needs AWS Signer in the region
+	signer bool
+}
+
+var partitions = map[string]partition{
+	"aws":        {dnsSuffix: "amazonaws.com", signer: true},
+	"aws-cn":     {regionPrefix: "cn-", dnsSuffix: "amazonaws.com.cn"},
+	"aws-us-gov": {regionPrefix: "us-gov-", dnsSuffix: "amazonaws.com", signer: true},
+}
+
+// Returns the partition of the region, e.g. aws-cn for cn-north-1.
+func regionPartition(region string) string {
+	for name, p := range partitions {
+		if p.regionPrefix != "" && strings.HasPrefix(region, p.regionPrefix) {
+			return name
+		}
+	}
+	return "aws"
+}
+
+// Returns the domain of endpoints in the region, e.g. amazonaws.com.cn for cn-north-1.
+func dnsSuffix(region string) string {
+	return partitions[regionPartition(region)].dnsSuffix
+}
+
+// Returns the partition of the ARN, e.g. aws-us-gov for arn:aws-us-gov:iam::123456789012:role/deployer.
+func arnPartition(arn string) (string, error) {
+	parts := strings.SplitN(arn, ":", 3)
+	if len(parts) < 3 || parts[0] != "arn" {
+		return "", fmt.Errorf(`invalid ARN "%s"`, arn)
+	}
+	if _, ok := partitions[parts[1]]; !ok {
+		return "", fmt.Errorf(`ARN "%s" is in unknown partition "%s"`, arn, parts[1])
+	}
+	return parts[1], nil
+}
+
+// Returns an error if the regions are in different partitions, the environment is in a different partition,
+// a role to deploy with or create functions with belongs to a different partition, or the partition lacks a service the run uses.
+//
+//	environment "china" {
+//	  partition = "aws-cn"
+//	  regions   = ["cn-north-1", "cn-northwest-1"]
+//	  role-arn  = "arn:aws-cn:iam::123456789012:role/deployer"
+//	}
+func checkPartition(regions []string, environment string) error {
+	name := regionPartition(regions[0])
+	for _, region := range regions[1:] {
+		if other := regionPartition(region); other != name {
+			return fmt.Errorf("regions %s and %s are in different partitions, %s and %s: deploy to each partition in its own run", regions[0], region, name, other)
+		}
+	}
+	ec := environmentConfigs[environment]
+	if ec.Partition != "" {
+		if _, ok := partitions[ec.Partition]; !ok {
+			return fmt.Errorf(`environment "%s": unknown partition "%s", expected one of: %s`, environment, ec.Partition, strings.Join(partitionNames(), ", "))
+		}
+		if ec.Partition != name {
+			return fmt.Errorf(`region %s is in partition %s, but environment "%s" is in partition %s`, regions[0], name, environment, ec.Partition)
+		}
+	}
+	roles := map[string]string{}
+	if roleARN, _ := roleToAssume(); roleARN != "" {
+		roles["role to deploy with"] = roleARN
+	}
+	for folder, fc := range folderConfigs {
+		if fc.Role != "" {
+			roles[fmt.Sprintf(`role of folder "%s"`, folder)] = fc.Role
+		}
+	}
+	for label, arn := range roles {
+		p, err := arnPartition(arn)
+		if err != nil {
+			return fmt.Errorf("%s: %w", label, err)
+		}
+		if p != name {
+			return fmt.Errorf("%s %s is in partition %s, but the run deploys to partition %s", label, arn, p, name)
+		}
+	}
+	// other regions of the partition cannot sign for it either, since they cannot share credentials or buckets with other partitions
+	if signingEnabled() && !partitions[name].signer && *signerFallbackFlag != signerFallbackSkip {
+		return fmt.Errorf(
+			"AWS Signer is not available in partition %s: pass -signer-fallback=%s to deploy unsigned packages to %s",
+			name, signerFallbackSkip, strings.Join(regions, ", "),
+		)
+	}
+	return nil
+}
+
+func partitionNames() []string {
+	names := []string{}
+	for name := range partitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,172 @@
+package deploy
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Lambda keeps the deployment package of every published version, up to this much per region.
+const codeStorageQuota = 75 * 1000 * 1000 * 1000
+
+// What a read-only run would have done, summed across every folder.
+type planTotals struct {
+	mu sync.Mutex
+	// number of folders that would be deployed
+	folders int
+	// number of signing jobs that would run
+	signingJobs int
+	// bytes that would be stored in S3 as signed deployment packages
+	s3Bytes int
+	// bytes that new Lambda versions would add to the code storage quota
+	codeStorageBytes int
+	// number of execution environments that would be provisioned for new versions
+	provisionedConcurrency int
+}
+
+// Prints the steps that would run if the builder was not in read-only mode,
+// and adds their cost to the plan totals.
+func (d *data) printPlan(folder, unsignedKey, signedKey string, size int) {
+	d.logf(folder, "plan", "Read-only mode, printing plan instead of deploying.")
+	if d.noUpload {
+		d.logf(folder, "plan", "Plan: nothing to do.")
+		return
+	}
+	d.plan.mu.Lock()
+	defer d.plan.mu.Unlock()
+	d.plan.folders++
+	if d.signingProfileFor(folder) == "" {
+		d.logf(folder, "plan", "Plan: no signing profile, deploy unsigned deployment package.")
+	} else {
+		d.logf(
+			folder,
+			"plan",
+			"Plan: upload unsigned deployment package to s3://%s/%s.",
+			d.unsignedBucket,
+			unsignedKey,
+		)
+		if d.noSigningJobs {
+			return
+		}
+		d.logf(
+			folder,
+			"plan",
+			"Plan: sign with profile %s into s3://%s/%s.",
+			d.signingProfileFor(folder),
+			d.stagingBucket,
+			prefixDir(d.stagingPrefix),
+		)
+		d.plan.signingJobs++
+	}
+	if d.noCopySigned {
+		return
+	}
+	d.logf(
+		folder,
+		"plan",
+		"Plan: copy signed deployment package to s3://%s/%s.",
+		d.signedBucket,
+		signedKey,
+	)
+	d.plan.s3Bytes += size
+	if d.debugSymbolsPrefix != "" {
+		d.logf(
+			folder,
+			"plan",
+			"Plan: upload debug symbols to s3://%s/%s.",
+			d.signedBucket,
+			objectKey(d.debugSymbolsPrefix, folder, "<signed hash>"),
+		)
+	}
+	if d.noUpdateFunctions {
+		return
+	}
+	alias := d.aliasFor(folder)
+	d.logf(
+		folder,
+		"plan",
+		"Plan: update code of Lambda function %s for %s.",
+		d.functionName(folder),
+		d.archFor(folder),
+	)
+	if d.latestOnly {
+		return
+	}
+	d.logf(folder, "plan", "Plan: publish new version and point alias %s at it.", alias)
+	if d.canaryPercent > 0 {
+		d.logf(folder, "plan", "Plan: shift %g%% of alias %s to the new version for %s first.", d.canaryPercent, alias, d.canaryBake)
+	}
+	d.plan.codeStorageBytes += size
+	provisioned, err := d.getProvisionedConcurrency(folder, alias)
+	if err != nil {
+		d.failf(
+			folder,
+			"plan",
+			err,
+			"Failed to get provisioned concurrency of alias %s: %s",
+			alias,
+			err.Error(),
+		)
+		return
+	}
+	if provisioned != 0 {
+		d.logf(
+			folder,
+			"plan",
+			"Plan: provision %d execution environments for the new version.",
+			provisioned,
+		)
+		d.plan.provisionedConcurrency += provisioned
+	}
+	if want := d.configs[folder].ProvisionedConcurrency; want != 0 {
+		d.logf(folder, "plan", "Plan: provision %d execution environments for the new version and remove them from the previous version.", want)
+		d.plan.provisionedConcurrency += want
+	}
+}
+
+// Returns the provisioned concurrency requested for the alias, or 0 if there is none.
+func (d *data) getProvisionedConcurrency(folder, alias string) (int, error) {
+	output, err := d.lambda.GetProvisionedConcurrencyConfig(d.ctx, &lambda.GetProvisionedConcurrencyConfigInput{
+		FunctionName: aws.String(d.functionName(folder)),
+		Qualifier:    aws.String(alias),
+	})
+	var notFound *lambdaTypes.ProvisionedConcurrencyConfigNotFoundException
+	if errors.As(err, &notFound) {
+		return 0, nil
+	}
+	var resourceNotFound *lambdaTypes.ResourceNotFoundException
+	if errors.As(err, &resourceNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int(aws.ToInt32(output.RequestedProvisionedConcurrentExecutions)), nil
+}
+
+// Prints what the whole run would have cost.
+// Signing jobs for Lambda are free, and code storage is not billed but counts toward a quota.
+func (d *data) printPlanTotals() {
+	d.plan.mu.Lock()
+	defer d.plan.mu.Unlock()
+	printf("Plan: (%d) folders would be deployed.\n", d.plan.folders)
+	printf("Plan: (%d) signing jobs would run.\n", d.plan.signingJobs)
+	s3GB := float64(d.plan.s3Bytes) / 1000000000
+	printf(
+		"Plan: %.2f M of signed deployment packages would be stored in S3, about $%.4f per month.\n",
+		float64(d.plan.s3Bytes)/1000000,
+		s3GB*d.s3PricePerGB,
+	)
+	printf(
+		"Plan: %.2f M of code storage would be added by new versions, %.4f%% of the Lambda quota.\n",
+		float64(d.plan.codeStorageBytes)/1000000,
+		float64(d.plan.codeStorageBytes)/codeStorageQuota*100,
+	)
+	printf(
+		"Plan: %d execution environments would be provisioned for new versions.\n\n",
+		d.plan.provisionedConcurrency,
+	)
+}
@@ -0,0 +1,141 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// What the policy is asked about, once per folder before it is uploaded.
+type policyInput struct {
+	RunID       string       `json:"runId"`
+	GitSHA      string       `json:"gitSha"`
+	Actor       string       `json:"actor"`
+	Environment string       `json:"environment"`
+	Region      string       `json:"region"`
+	Time        time.Time    `json:"time"`
+	Weekday     string       `json:"weekday"`
+	Hour        int          `json:"hour"`
+	Folders     []string     `json:"folders"`
+	Folder      policyFolder `json:"folder"`
+}
+
+type policyFolder struct {
+	Name           string `json:"name"`
+	Size           int    `json:"size"`
+	Arch           string `json:"arch"`
+	Alias          string `json:"alias"`
+	SigningProfile string `json:"signingProfile"`
+}
+
+// What the policy decided.
+// The policy denies the deploy by adding reasons to deny,
+// and asks for approval by adding reasons to require_approval.
+//
+//	package builder
+//
+//	deny[msg] {
+//	  input.folder.size > 50000000
+//	  msg := sprintf("%s is larger than 50 M", [input.folder.name])
+//	}
+//
+//	require_approval[msg] {
+//	  input.environment == "prod"
+//	  input.weekday == "Friday"
+//	  msg := "deploying to prod on a Friday"
+//	}
+type policyDecision struct {
+	Deny            []string `json:"deny"`
+	RequireApproval []string `json:"require_approval"`
+}
+
+// Returns the user that started the run.
+func actor() string {
+	if actor := os.Getenv("GITHUB_ACTOR"); actor != "" {
+		return actor
+	}
+	return os.Getenv("USER")
+}
+
+// Evaluates the policy with opa and returns an error if it denies the deploy,
+// or if it requires approval and the approve flag was not passed in.
+func (d *data) checkPolicy(folder string, size int) error {
+	if d.policy == "" {
+		return nil
+	}
+	d.logf(folder, "policy", "Evaluating policy %s.", d.policy)
+	now := time.Now().UTC()
+	input := policyInput{
+		RunID:       d.runID,
+		GitSHA:      d.gitSHA,
+		Actor:       actor(),
+		Environment: d.environment,
+		Region:      d.region,
+		Time:        now,
+		Weekday:     now.Weekday().String(),
+		Hour:        now.Hour(),
+		Folders:     d.folders,
+		Folder: policyFolder{
+			Name:           folder,
+			Size:           size,
+			Arch:           d.archFor(folder),
+			Alias:          d.aliasFor(folder),
+			SigningProfile: d.signingProfileFor(folder),
+		},
+	}
+	decision, err := evalPolicy(d.policy, d.policyQuery, input)
+	if err != nil {
+		d.failf(folder, "policy", err, "Failed to evaluate policy: %s", err.Error())
+		return err
+	}
+	if len(decision.Deny) != 0 {
+		d.logf(folder, "policy", "Policy denied deploy: %s.", strings.Join(decision.Deny, "; "))
+		return fmt.Errorf("policy denied deploy: %s", strings.Join(decision.Deny, "; "))
+	}
+	if len(decision.RequireApproval) != 0 && !d.approved {
+		d.logf(
+			folder,
+			"policy",
+			"Policy requires approval, pass -approve to deploy: %s.",
+			strings.Join(decision.RequireApproval, "; "),
+		)
+		return fmt.Errorf("policy requires approval: %s", strings.Join(decision.RequireApproval, "; "))
+	}
+	d.donef(folder, "policy", "Policy allowed deploy.")
+	return nil
+}
+
+func evalPolicy(policy, query string, input policyInput) (policyDecision, error) {
+	b, err := json.Marshal(input)
+	if err != nil {
+		return policyDecision{}, err
+	}
+	cmd := exec.Command("opa", "eval", "--format=json", "--stdin-input", "--data", policy, query)
+	cmd.Stdin = bytes.NewReader(b)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.Output()
+	if err != nil {
+		return policyDecision{}, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	output := struct {
+		Result []struct {
+			Expressions []struct {
+				Value policyDecision `json:"value"`
+			} `json:"expressions"`
+		} `json:"result"`
+	}{}
+	err = json.Unmarshal(stdout, &output)
+	if err != nil {
+		return policyDecision{}, err
+	}
+	// an undefined query has no result, which allows the deploy
+	if len(output.Result) == 0 || len(output.Result[0].Expressions) == 0 {
+		return policyDecision{}, nil
+	}
+	return output.Result[0].Expressions[0].Value, nil
+}
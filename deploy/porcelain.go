@@ -0,0 +1,55 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Outcomes of a run in the porcelain output.
+const (
+	porcelainSucceeded       = "succeeded"
+	porcelainFailed          = "failed"
+	porcelainTooManyFailures = "too-many-failures"
+)
+
+// Sends everything but the porcelain output to stderr with -porcelain, so stdout is only the porcelain.
+// Called again once the config file is read, since it can set -porcelain too.
+func usePorcelain() {
+	if *porcelainFlag {
+		logOutput = os.Stderr
+	}
+}
+
+// Writes what happened to each folder and how the run ended to w, one tab-separated line each,
+// in a format that does not change between releases, for scripts to read. Fields that do not apply are empty.
+//
+//	folder	testLambda01	us-east-1	deployed	42	LIVE	GgbC24x...
+//	folder	testLambda02		up-to-date
+//	run	4b48927c2f9a38f3	succeeded
+//
+// Statuses use dashes instead of spaces, e.g. up-to-date.
+func (d *data) writePorcelain(w io.Writer, err error) {
+	if d != nil {
+		d.report.mu.Lock()
+		for _, key := range d.report.keys() {
+			fr := d.report.folders[key]
+			fields := []string{"folder", fr.Folder, fr.Region, strings.ReplaceAll(fr.Status, " ", "-"), fr.Version, fr.Alias, fr.SignedHash}
+			fmt.Fprintln(w, strings.TrimRight(strings.Join(fields, "\t"), "\t"))
+		}
+		d.report.mu.Unlock()
+	}
+	outcome := porcelainSucceeded
+	if errors.Is(err, errTooManyFailures) {
+		outcome = porcelainTooManyFailures
+	} else if err != nil {
+		outcome = porcelainFailed
+	}
+	runID := ""
+	if d != nil {
+		runID = d.runID
+	}
+	fmt.Fprintf(w, "run\t%s\t%s\n", runID, outcome)
+}
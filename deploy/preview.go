@@ -0,0 +1,236 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Returns the alias that previews of the pull request are deployed to.
+func previewAlias(pr string) string {
+	return "PR-" + pr
+}
+
+// Returns the key of the folder's signed deployment package for the pull request.
+// Previews never overwrite the signed deployment packages of the main pipeline.
+func previewKey(signedPrefix, pr, folder string) string {
+	return objectKey(signedPrefix, previewAlias(pr), folder+".zip")
+}
+
+// Creates a function URL for the preview alias, or returns the existing one.
+func (d *data) createPreviewURL(folder, alias string) (string, error) {
+	if err := d.refuseInReadOnly(folder, "creating preview function URL"); err != nil {
+		return "", err
+	}
+	d.logf(folder, "preview", "Creating function URL for alias %s.", alias)
+	output, err := d.lambda.CreateFunctionUrlConfig(d.ctx, &lambda.CreateFunctionUrlConfigInput{
+		FunctionName: aws.String(d.functionName(folder)),
+		Qualifier:    aws.String(alias),
+		AuthType:     d.previewURLAuth,
+	})
+	var conflict *lambdaTypes.ResourceConflictException
+	if errors.As(err, &conflict) {
+		existing, err := d.lambda.GetFunctionUrlConfig(d.ctx, &lambda.GetFunctionUrlConfigInput{
+			FunctionName: aws.String(d.functionName(folder)),
+			Qualifier:    aws.String(alias),
+		})
+		if err != nil {
+			d.failf(folder, "preview", err, "Failed to get function URL: %s", err.Error())
+			return "", err
+		}
+		d.logf(folder, "preview", "Function URL already exists: %s.", *existing.FunctionUrl)
+		return *existing.FunctionUrl, nil
+	}
+	if err != nil {
+		d.failf(folder, "preview", err, "Failed to create function URL: %s", err.Error())
+		return "", err
+	}
+	if d.previewURLAuth == lambdaTypes.FunctionUrlAuthTypeNone {
+		// public function URLs also need a resource-based policy
+		_, err := d.lambda.AddPermission(d.ctx, &lambda.AddPermissionInput{
+			FunctionName:        aws.String(d.functionName(folder)),
+			Qualifier:           aws.String(alias),
+			StatementId:         aws.String("preview-function-url"),
+			Action:              aws.String("lambda:InvokeFunctionUrl"),
+			Principal:           aws.String("*"),
+			FunctionUrlAuthType: lambdaTypes.FunctionUrlAuthTypeNone,
+		})
+		if err != nil {
+			d.failf(
+				folder,
+				"preview",
+				err,
+				"Failed to allow public access to function URL: %s",
+				err.Error(),
+			)
+			return "", err
+		}
+	}
+	d.logf(folder, "preview", "Created function URL: %s.", *output.FunctionUrl)
+	return *output.FunctionUrl, nil
+}
+
+// Records the function URL of the folder's preview.
+// Safe to call from multiple goroutines.
+func (d *data) addPreviewURL(folder, url string) {
+	d.previewURLsMu.Lock()
+	defer d.previewURLsMu.Unlock()
+	if d.targetRegion != "" {
+		folder = fmt.Sprintf("%s (%s)", folder, d.targetRegion)
+	}
+	d.previewURLs[folder] = url
+}
+
+// Prints the function URLs of every preview in this run and posts them to the pull request.
+// Only prints them if there is no GitHub token.
+func (d *data) reportPreviewURLs() error {
+	if len(d.previewURLs) == 0 {
+		printf("No previews were deployed.\n\n")
+		return nil
+	}
+	folders := []string{}
+	for folder := range d.previewURLs {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+	lines := []string{fmt.Sprintf("Preview deployed to alias `%s`:", previewAlias(d.preview)), ""}
+	for _, folder := range folders {
+		lines = append(lines, fmt.Sprintf("- %s: %s", folder, d.previewURLs[folder]))
+	}
+	body := strings.Join(lines, "\n")
+	printf("%s\n\n", body)
+	token := os.Getenv(*githubTokenEnvFlag)
+	if token == "" || *githubRepoFlag == "" {
+		printf("Not posting preview URLs to GitHub, no token or repository.\n\n")
+		return nil
+	}
+	err := postPullRequestComment(d.ctx, *githubRepoFlag, d.preview, token, body)
+	if err != nil {
+		printf("Failed to post preview URLs to pull request %s: %s.\n\n", d.preview, err.Error())
+		return err
+	}
+	printf("Posted preview URLs to pull request %s.\n\n", d.preview)
+	return nil
+}
+
+func postPullRequestComment(ctx context.Context, repo, pr, token, body string) error {
+	payload, err := json.Marshal(map[string]string{"body": body})
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("https://api.github.com/repos/%s/issues/%s/comments", repo, pr)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		return fmt.Errorf("GitHub responded with %s", res.Status)
+	}
+	return nil
+}
+
+// Deletes the preview alias, its function URL, and its signed deployment package from every folder.
+//
+//	builder teardown-preview \
+//	    -preview=1234 \
+//	    -bucket=kesav-go-lambda-builder-test \
+//	    -signed-prefix=test/signed
+func teardownPreview() error {
+	if *previewFlag == "" {
+		return errors.New(`flag "preview" is required`)
+	}
+	signedBucket := orDefault(*signedBucketFlag, *bucketFlag)
+	if signedBucket == "" {
+		return errors.New(`flag "signed-bucket" or "bucket" is required`)
+	}
+	if *signedPrefixFlag == "" {
+		return errors.New(`flag "signed-prefix" is required`)
+	}
+	folders, err := selectFolders()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return err
+	}
+	d := &data{
+		configs:        folderConfigs,
+		functionPrefix: *functionPrefixFlag,
+		functionSuffix: *functionSuffixFlag,
+		ctx:            context.TODO(),
+		metrics:        runMetrics,
+		s3:             newS3Client(cfg),
+		signedBucket:   signedBucket,
+		signedPrefix:   *signedPrefixFlag,
+		lambda:         lambda.NewFromConfig(cfg),
+		preview:        *previewFlag,
+	}
+	// previews deployed with -regions are torn down in each of them
+	targets, err := d.commandTargets(cfg)
+	if err != nil {
+		return err
+	}
+	alias := previewAlias(d.preview)
+	failures := []string{}
+	for _, r := range targets {
+		printf("Tearing down alias %s of (%d) folders in %s: %s.\n\n", alias, len(folders), r.region, strings.Join(folders, ", "))
+		for _, folder := range folders {
+			err := r.teardownPreview(folder, r.preview)
+			if err != nil {
+				failures = append(failures, r.folderInRegion(folder))
+			}
+		}
+	}
+	if len(failures) != 0 {
+		return fmt.Errorf("failed to tear down previews: %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+func (d *data) teardownPreview(folder, pr string) error {
+	alias := previewAlias(pr)
+	var notFound *lambdaTypes.ResourceNotFoundException
+	d.logf(folder, "teardown", "Deleting function URL for alias %s.", alias)
+	_, err := d.lambda.DeleteFunctionUrlConfig(d.ctx, &lambda.DeleteFunctionUrlConfigInput{
+		FunctionName: aws.String(d.functionName(folder)),
+		Qualifier:    aws.String(alias),
+	})
+	if err != nil && !errors.As(err, &notFound) {
+		d.failf(folder, "teardown", err, "Failed to delete function URL: %s", err.Error())
+		return err
+	}
+	d.logf(folder, "teardown", "Deleting alias %s.", alias)
+	_, err = d.lambda.DeleteAlias(d.ctx, &lambda.DeleteAliasInput{
+		FunctionName: aws.String(d.functionName(folder)),
+		Name:         aws.String(alias),
+	})
+	if err != nil && !errors.As(err, &notFound) {
+		d.failf(folder, "teardown", err, "Failed to delete alias: %s", err.Error())
+		return err
+	}
+	d.deleteObject(folder, d.signedBucket, previewKey(d.signedPrefix, pr, folder))
+	err = d.untagPreview(folder, alias)
+	if err != nil && !errors.As(err, &notFound) {
+		return err
+	}
+	d.donef(folder, "teardown", "Tore down alias %s.", alias)
+	return nil
+}
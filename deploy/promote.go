@@ -0,0 +1,161 @@
+package deploy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Moving an alias of a function to the version another alias points at.
+type promotion struct {
+	folder string
+	// the version the target alias points at, empty if it does not exist
+	current string
+	version string
+}
+
+// Points the -to alias of each function at the version its -from alias points at.
+//
+//	builder promote -from=TEST -to=PROD -all -confirm
+//
+// Prints every change before making any, and with -confirm, asks before making them.
+// With -read-only, only prints the changes.
+func promote() error {
+	if *fromFlag == "" || *toFlag == "" {
+		return errors.New(`flags "from" and "to" are required`)
+	}
+	if *fromFlag == *toFlag {
+		return errors.New(`flags "from" and "to" must be different aliases`)
+	}
+	folders, err := selectFunctions()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return err
+	}
+	d := &data{
+		configs:            folderConfigs,
+		functionPrefix:     *functionPrefixFlag,
+		functionSuffix:     *functionSuffixFlag,
+		ctx:                context.TODO(),
+		metrics:            runMetrics,
+		readOnly:           *readOnlyFlag,
+		lambda:             lambda.NewFromConfig(cfg),
+		createAlias:        *createAliasFlag,
+		verifyAliasTimeout: *verifyAliasTimeoutFlag,
+	}
+	printf("Promoting alias %s to %s of (%d) functions: %s.\n\n", *fromFlag, *toFlag, len(folders), strings.Join(folders, ", "))
+	promotions := []promotion{}
+	failures := []string{}
+	for _, folder := range folders {
+		p, err := d.planPromotion(folder, *fromFlag, *toFlag)
+		if err != nil {
+			failures = append(failures, folder)
+			continue
+		}
+		if p.current == p.version {
+			d.skipf(folder, "promote", "Alias %s already points at version %s.", *toFlag, p.version)
+			continue
+		}
+		promotions = append(promotions, p)
+	}
+	if len(failures) != 0 {
+		return fmt.Errorf("failed to look up aliases: %s", strings.Join(failures, ", "))
+	}
+	if len(promotions) == 0 {
+		printf("\nNothing to promote.\n")
+		return nil
+	}
+	printf("\n")
+	for _, p := range promotions {
+		printf("%s: %s %s -> %s\n", p.folder, *toFlag, orDefault(p.current, "(new)"), p.version)
+	}
+	printf("\n")
+	if d.readOnly {
+		printf("Read-only mode, not promoting.\n")
+		return nil
+	}
+	if *confirmFlag && !confirm(fmt.Sprintf("Promote (%d) functions?", len(promotions))) {
+		return errors.New("promotion was not confirmed")
+	}
+	for _, p := range promotions {
+		err := d.applyPromotion(p, *toFlag)
+		if err != nil {
+			failures = append(failures, p.folder)
+		}
+	}
+	if len(failures) != 0 {
+		return fmt.Errorf("failed to promote: %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// Looks up the versions the from and to aliases of the folder's function point at.
+func (d *data) planPromotion(folder, from, to string) (promotion, error) {
+	p := promotion{folder: folder}
+	d.logf(folder, "promote", "Getting aliases %s and %s of Lambda function.", from, to)
+	output, err := d.lambda.GetAlias(d.ctx, &lambda.GetAliasInput{
+		FunctionName: aws.String(d.functionName(folder)),
+		Name:         aws.String(from),
+	})
+	if err != nil {
+		d.failf(folder, "promote", err, "Failed to get alias %s of Lambda function: %s", from, err.Error())
+		return p, err
+	}
+	p.version = aws.ToString(output.FunctionVersion)
+	output, err = d.lambda.GetAlias(d.ctx, &lambda.GetAliasInput{
+		FunctionName: aws.String(d.functionName(folder)),
+		Name:         aws.String(to),
+	})
+	var notFound *lambdaTypes.ResourceNotFoundException
+	if errors.As(err, &notFound) && d.createAlias {
+		d.donef(folder, "promote", "Alias %s points at version %s, alias %s does not exist.", from, p.version, to)
+		return p, nil
+	}
+	if err != nil {
+		d.failf(folder, "promote", err, "Failed to get alias %s of Lambda function: %s", to, err.Error())
+		return p, err
+	}
+	p.current = aws.ToString(output.FunctionVersion)
+	d.donef(folder, "promote", "Alias %s points at version %s, alias %s at version %s.", from, p.version, to, p.current)
+	return p, nil
+}
+
+func (d *data) applyPromotion(p promotion, alias string) error {
+	if p.current == "" {
+		err := d.createFunctionAlias(p.folder, alias, p.version, "")
+		if err != nil {
+			return err
+		}
+		return d.verifyAlias(p.folder, alias, p.version)
+	}
+	d.logf(p.folder, "promote", "Pointing alias %s at version %s instead of %s.", alias, p.version, p.current)
+	_, err := d.lambda.UpdateAlias(d.ctx, &lambda.UpdateAliasInput{
+		FunctionName:    aws.String(d.functionName(p.folder)),
+		Name:            aws.String(alias),
+		FunctionVersion: aws.String(p.version),
+	})
+	if err != nil {
+		d.failf(p.folder, "promote", err, "Failed to update alias of Lambda function: %s", err.Error())
+		return err
+	}
+	d.donef(p.folder, "promote", "Promoted alias %s to version %s.", alias, p.version)
+	return d.verifyAlias(p.folder, alias, p.version)
+}
+
+// Asks a yes or no question on stdin, defaulting to no.
+func confirm(question string) bool {
+	printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
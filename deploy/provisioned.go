@@ -0,0 +1,129 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// How long to wait between checks of provisioned concurrency that is not ready yet.
+const provisionedConcurrencyDelay = 5 * time.Second
+
+// Provisions the folder's provisioned-concurrency for the version the alias now points at,
+// waits until it is ready, then removes provisioned concurrency from previous, the version the alias pointed at before.
+// Provisioned concurrency on aliases is left alone, Lambda moves it with the alias.
+//
+//	folder "testLambda01" {
+//	  provisioned-concurrency = 5
+//	}
+func (d *data) provisionConcurrency(folder, version, previous string) error {
+	want := d.configs[folder].ProvisionedConcurrency
+	if want == 0 {
+		return nil
+	}
+	if err := d.refuseInReadOnly(folder, "provisioning concurrency"); err != nil {
+		return err
+	}
+	d.logf(folder, "provision", "Provisioning %d execution environments for version %s.", want, version)
+	_, err := d.lambda.PutProvisionedConcurrencyConfig(d.ctx, &lambda.PutProvisionedConcurrencyConfigInput{
+		FunctionName:                    aws.String(d.functionName(folder)),
+		Qualifier:                       aws.String(version),
+		ProvisionedConcurrentExecutions: aws.Int32(int32(want)),
+	})
+	if err != nil {
+		d.failf(folder, "provision", err, "Failed to provision concurrency for version %s: %s", version, err.Error())
+		return err
+	}
+	err = d.waitForProvisionedConcurrency(folder, version)
+	if err != nil {
+		d.failf(folder, "provision", err, "Failed to wait for provisioned concurrency of version %s: %s", version, err.Error())
+		return err
+	}
+	d.donef(folder, "provision", "Provisioned concurrency of version %s is ready.", version)
+	return d.deprovisionPreviousVersion(folder, version, previous)
+}
+
+// Returns an error wrapping errPostCondition if the provisioned concurrency of the version fails or is not ready within the provisioned concurrency timeout.
+func (d *data) waitForProvisionedConcurrency(folder, version string) error {
+	deadline := time.Now().Add(d.provisionedConcurrencyTimeout)
+	for {
+		output, err := d.lambda.GetProvisionedConcurrencyConfig(d.ctx, &lambda.GetProvisionedConcurrencyConfigInput{
+			FunctionName: aws.String(d.functionName(folder)),
+			Qualifier:    aws.String(version),
+		})
+		if err != nil {
+			return err
+		}
+		switch output.Status {
+		case lambdaTypes.ProvisionedConcurrencyStatusEnumReady:
+			return nil
+		case lambdaTypes.ProvisionedConcurrencyStatusEnumFailed:
+			return fmt.Errorf("%w: provisioned concurrency failed: %s", errPostCondition, aws.ToString(output.StatusReason))
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: provisioned concurrency is still %s after %s", errPostCondition, output.Status, d.provisionedConcurrencyTimeout)
+		}
+		d.logf(
+			folder,
+			"provision",
+			"Provisioned concurrency is %s, %d of %d execution environments are ready.",
+			output.Status,
+			aws.ToInt32(output.AvailableProvisionedConcurrentExecutions),
+			aws.ToInt32(output.RequestedProvisionedConcurrentExecutions),
+		)
+		time.Sleep(provisionedConcurrencyDelay)
+	}
+}
+
+// Deletes the provisioned concurrency of previous, the version the alias pointed at before version, so it stops being billed.
+// Versions still pointed at or routed to by an alias, e.g. during a canary, keep theirs, and previews never remove any.
+func (d *data) deprovisionPreviousVersion(folder, version, previous string) error {
+	if d.preview != "" {
+		return nil
+	}
+	if previous == "" || previous == version || !isVersionNumber(previous) {
+		return nil
+	}
+	aliased, err := d.aliasedVersions(folder)
+	if err != nil {
+		d.failf(folder, "provision", err, "Failed to list aliases: %s", err.Error())
+		return err
+	}
+	if aliased[previous] {
+		d.donef(folder, "provision", "Keeping provisioned concurrency of version %s, an alias still uses it.", previous)
+		return nil
+	}
+	d.logf(folder, "provision", "Removing provisioned concurrency of version %s.", previous)
+	_, err = d.lambda.DeleteProvisionedConcurrencyConfig(d.ctx, &lambda.DeleteProvisionedConcurrencyConfigInput{
+		FunctionName: aws.String(d.functionName(folder)),
+		Qualifier:    aws.String(previous),
+	})
+	var notFound *lambdaTypes.ProvisionedConcurrencyConfigNotFoundException
+	if errors.As(err, &notFound) {
+		d.donef(folder, "provision", "Version %s had no provisioned concurrency.", previous)
+		return nil
+	}
+	if err != nil {
+		d.failf(folder, "provision", err, "Failed to remove provisioned concurrency of version %s: %s", previous, err.Error())
+		return err
+	}
+	d.donef(folder, "provision", "Removed provisioned concurrency of version %s.", previous)
+	return nil
+}
+
+// Returns true if the qualifier is a version rather than an alias.
+func isVersionNumber(qualifier string) bool {
+	if qualifier == "" {
+		return false
+	}
+	for _, r := range qualifier {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"builder/testutil"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestDeprovisionPreviousVersion(t *testing.T) {
+	chdirLambdas(t)
+	tests := []struct {
+		name string
+		// changes the function after LIVE moved from version 1 to 2
+		setup   func(t *testing.T, fakeLambda *testutil.FakeLambda)
+		preview string
+		want    bool
+	}{
+		{name: "previous version", want: false},
+		{
+			name: "previous version of another alias",
+			setup: func(t *testing.T, fakeLambda *testutil.FakeLambda) {
+				_, err := fakeLambda.CreateAlias(context.Background(), &lambda.CreateAliasInput{
+					FunctionName:    aws.String("testLambda01"),
+					Name:            aws.String("STABLE"),
+					FunctionVersion: aws.String("1"),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: true,
+		},
+		{
+			name: "previous version in a canary",
+			setup: func(t *testing.T, fakeLambda *testutil.FakeLambda) {
+				_, err := fakeLambda.UpdateAlias(context.Background(), &lambda.UpdateAliasInput{
+					FunctionName:  aws.String("testLambda01"),
+					Name:          aws.String("LIVE"),
+					RoutingConfig: &lambdaTypes.AliasRoutingConfiguration{AdditionalVersionWeights: map[string]float64{"1": 0.9}},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: true,
+		},
+		{name: "preview", preview: "pr-1", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeS3 := testutil.NewFakeS3("us-east-1")
+			fakeSigner := testutil.NewFakeSigner(fakeS3)
+			fakeLambda := testutil.NewFakeLambda(fakeS3, "us-east-1")
+			d := newProvisionedRun(t, fakeS3, fakeSigner, fakeLambda)
+			err := d.run("testLambda01")
+			if err != nil {
+				t.Fatal(err)
+			}
+			moveLiveToNewVersion(t, fakeLambda)
+			if tt.setup != nil {
+				tt.setup(t, fakeLambda)
+			}
+
+			d = newProvisionedRun(t, fakeS3, fakeSigner, fakeLambda)
+			d.preview = tt.preview
+			err = d.deprovisionPreviousVersion("testLambda01", "2", "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := isProvisioned(t, fakeLambda, "1"); got != tt.want {
+				t.Errorf("version 1 provisioned: got %t, want %t", got, tt.want)
+			}
+			if !isProvisioned(t, fakeLambda, "2") {
+				t.Error("version 2 lost its provisioned concurrency")
+			}
+		})
+	}
+}
+
+// Returns a fake run of testLambda01 with provisioned concurrency.
+func newProvisionedRun(t *testing.T, fakeS3 *testutil.FakeS3, fakeSigner *testutil.FakeSigner, fakeLambda *testutil.FakeLambda) *data {
+	d := newFakeRun(t, fakeS3, fakeSigner, fakeLambda)
+	c := d.configs["testLambda01"]
+	c.ProvisionedConcurrency = 2
+	d.configs["testLambda01"] = c
+	return d
+}
+
+// Publishes version 2 of testLambda01 with provisioned concurrency and points LIVE at it.
+func moveLiveToNewVersion(t *testing.T, fakeLambda *testutil.FakeLambda) {
+	ctx := context.Background()
+	_, err := fakeLambda.UpdateFunctionCode(ctx, &lambda.UpdateFunctionCodeInput{
+		FunctionName: aws.String("testLambda01"),
+		ZipFile:      []byte("version 2"),
+		Publish:      true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fakeLambda.PutProvisionedConcurrencyConfig(ctx, &lambda.PutProvisionedConcurrencyConfigInput{
+		FunctionName:                    aws.String("testLambda01"),
+		Qualifier:                       aws.String("2"),
+		ProvisionedConcurrentExecutions: aws.Int32(2),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fakeLambda.UpdateAlias(ctx, &lambda.UpdateAliasInput{
+		FunctionName:    aws.String("testLambda01"),
+		Name:            aws.String("LIVE"),
+		FunctionVersion: aws.String("2"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func isProvisioned(t *testing.T, fakeLambda *testutil.FakeLambda, version string) bool {
+	_, err := fakeLambda.GetProvisionedConcurrencyConfig(context.Background(), &lambda.GetProvisionedConcurrencyConfigInput{
+		FunctionName: aws.String("testLambda01"),
+		Qualifier:    aws.String(version),
+	})
+	var notFound *lambdaTypes.ProvisionedConcurrencyConfigNotFoundException
+	if errors.As(err, &notFound) {
+		return false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return true
+}
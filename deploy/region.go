@@ -0,0 +1,213 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/signer"
+)
+
+// Replaced by the region in bucket names, since buckets are global but Lambda and Signer only read buckets in their region.
+//
+//	-bucket=kesav-go-lambda-builder-{region}
+const regionPlaceholder = "{region}"
+
+// Returns the bucket to use in the region.
+func regionalBucket(bucket, region string) string {
+	return strings.ReplaceAll(bucket, regionPlaceholder, region)
+}
+
+// Returns a copy of the run that deploys to the region of cfg, with its own clients and buckets.
+// The copy shares the event log, plan totals, and preview URLs of the run.
+func (d *data) inRegion(cfg aws.Config) *data {
+	r := *d
+	r.region = cfg.Region
+	r.regions = nil
+	r.targetRegion = cfg.Region
+	r.unsignedBucket = regionalBucket(d.unsignedBucket, cfg.Region)
+	r.stagingBucket = regionalBucket(d.stagingBucket, cfg.Region)
+	r.signedBucket = regionalBucket(d.signedBucket, cfg.Region)
+	r.s3 = newS3Client(cfg)
+	r.uploader = newUploader(r.s3, r.uploadPartSize, r.uploadConcurrency)
+	r.signer = signer.NewFromConfig(cfg)
+	r.signingJobWaiter = newSigningJobWaiter(r.signer, r.waiterMinDelay, r.waiterMaxDelay)
+	r.codeSigningConfigs = newCodeSigningConfigs()
+	r.lambda = lambda.NewFromConfig(cfg)
+	r.functionUpdatedWaiter = newFunctionUpdatedWaiter(r.lambda, r.waiterMinDelay, r.waiterMaxDelay)
+	r.publishedVersionWaiter = newPublishedVersionWaiter(r.lambda)
+	r.cloudwatch = cloudwatch.NewFromConfig(cfg)
+	return &r
+}
+
+// Returns copies of a command to run in each of -regions, like inRegion,
+// or without -regions the command itself in the region of cfg, with the region in its bucket names.
+// Used by commands that act on what runs deployed, e.g. teardown-preview and gc, so they reach every region a run deployed to.
+func (d *data) commandTargets(cfg aws.Config) ([]*data, error) {
+	regions := splitList(*regionsFlag)
+	if len(regions) == 0 {
+		d.region = cfg.Region
+		d.unsignedBucket = regionalBucket(d.unsignedBucket, cfg.Region)
+		d.stagingBucket = regionalBucket(d.stagingBucket, cfg.Region)
+		d.signedBucket = regionalBucket(d.signedBucket, cfg.Region)
+		return []*data{d}, nil
+	}
+	err := checkRegionalBuckets(d.unsignedBucket, d.stagingBucket, d.signedBucket)
+	if err != nil {
+		return nil, err
+	}
+	regionalCfgs, err := loadRegionalAWSConfigs(cfg, regions)
+	if err != nil {
+		return nil, err
+	}
+	targets := []*data{}
+	for _, regionalCfg := range regionalCfgs {
+		targets = append(targets, d.inRegion(regionalCfg))
+	}
+	return targets, nil
+}
+
+// Returns an error if a bucket name does not contain the region placeholder,
+// since with -regions the same bucket would be used in every region.
+func checkRegionalBuckets(buckets ...string) error {
+	for _, bucket := range buckets {
+		if bucket != "" && !strings.Contains(bucket, regionPlaceholder) {
+			return fmt.Errorf(`flag "regions" needs bucket names that contain %s, got "%s"`, regionPlaceholder, bucket)
+		}
+	}
+	return nil
+}
+
+// Returns the folder as lines of output start with it, with the region the copy of the run deploys to, if it is one.
+func (d *data) folderInRegion(folder string) string {
+	if d.targetRegion == "" {
+		return folder
+	}
+	return fmt.Sprintf("%s (%s)", folder, d.targetRegion)
+}
+
+// Returns the copies of the run to deploy with, one per region, or the run itself.
+func (d *data) targets() []*data {
+	if len(d.regions) == 0 {
+		return []*data{d}
+	}
+	return d.regions
+}
+
+// Returns an error if a bucket the run uses is not in the region the run deploys to.
+// Lambda and Signer only read buckets in their own region, and fail with confusing errors for buckets in others.
+func (d *data) checkBucketRegions(ctx context.Context) error {
+	buckets := []string{d.signedBucket}
+	if d.signsInRegion() {
+		buckets = append(buckets, d.unsignedBucket, d.stagingBucket)
+	}
+	checked := map[string]bool{}
+	for _, bucket := range buckets {
+		if checked[bucket] {
+			continue
+		}
+		checked[bucket] = true
+		output, err := d.s3.GetBucketLocation(ctx, &s3.GetBucketLocationInput{
+			Bucket: aws.String(bucket),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get region of bucket %s: %w", bucket, err)
+		}
+		region := bucketRegion(output.LocationConstraint)
+		if region != d.region {
+			return fmt.Errorf(
+				"bucket %s is in %s, but Lambda and Signer in %s only read buckets in %s: use a bucket in %s, e.g. -bucket=name-%s",
+				bucket, region, d.region, d.region, d.region, regionPlaceholder,
+			)
+		}
+	}
+	return nil
+}
+
+// Returns the region of a bucket from its location constraint, which is empty for us-east-1 and EU for old buckets in eu-west-1.
+func bucketRegion(constraint s3Types.BucketLocationConstraint) string {
+	switch constraint {
+	case "":
+		return "us-east-1"
+	case s3Types.BucketLocationConstraintEu:
+		return "eu-west-1"
+	}
+	return string(constraint)
+}
+
+// Loads the AWS config of each region and checks it against the environment.
+func loadRegionalAWSConfigs(cfg aws.Config, regions []string) ([]aws.Config, error) {
+	cfgs := []aws.Config{}
+	for _, region := range regions {
+		regional := cfg.Copy()
+		regional.Region = region
+		err := checkGuardrails(context.TODO(), regional, *environmentFlag)
+		if err != nil {
+			return nil, err
+		}
+		cfgs = append(cfgs, regional)
+	}
+	return cfgs, nil
+}
+
+// The errors of a folder that failed to deploy to some of the regions, keyed by region.
+type regionErrors map[string]error
+
+func (e regionErrors) Error() string {
+	regions := []string{}
+	for region := range e {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	messages := []string{}
+	for _, region := range regions {
+		messages = append(messages, fmt.Sprintf("%s: %s", region, e[region].Error()))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Returns nil if no region failed, or the error itself if the run only deploys to one region.
+func (d *data) regionError(errs regionErrors) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	if len(d.regions) == 0 {
+		return errs[d.region]
+	}
+	return errs
+}
+
+func newSigningJobWaiter(client signerAPI, minDelay, maxDelay time.Duration) *signer.SuccessfulSigningJobWaiter {
+	return signer.NewSuccessfulSigningJobWaiter(
+		client,
+		func(o *signer.SuccessfulSigningJobWaiterOptions) {
+			o.MinDelay = minDelay
+			o.MaxDelay = maxDelay
+		})
+}
+
+// SnapStart snapshots take minutes, so polling more often than this only spends API calls.
+func newPublishedVersionWaiter(client lambdaAPI) *lambda.PublishedVersionActiveWaiter {
+	return lambda.NewPublishedVersionActiveWaiter(
+		client,
+		func(o *lambda.PublishedVersionActiveWaiterOptions) {
+			o.MinDelay = 5 * time.Second
+			o.MaxDelay = 30 * time.Second
+		})
+}
+
+func newFunctionUpdatedWaiter(client lambdaAPI, minDelay, maxDelay time.Duration) *lambda.FunctionUpdatedV2Waiter {
+	return lambda.NewFunctionUpdatedV2Waiter(
+		client,
+		func(o *lambda.FunctionUpdatedV2WaiterOptions) {
+			o.MinDelay = minDelay
+			o.MaxDelay = maxDelay
+		})
+}
@@ -0,0 +1,99 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Deploys the folders that changed between two tags and prints a release report.
+//
+//	git checkout v1.4.0
+//	builder release -from-tag=v1.3.0 -to-tag=v1.4.0
+//
+// The report lists the version each folder was deployed as and the commits that changed it.
+func release() error {
+	if *fromTagFlag == "" || *toTagFlag == "" {
+		return errors.New(`flags "from-tag" and "to-tag" are required`)
+	}
+	// the working tree is what gets built
+	head, err := git("rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	to, err := git("rev-parse", *toTagFlag+"^{commit}")
+	if err != nil {
+		return err
+	}
+	if head != to {
+		return fmt.Errorf("check out %s before releasing it", *toTagFlag)
+	}
+	changed, err := changedFolders(*fromTagFlag, *toTagFlag)
+	if err != nil {
+		return err
+	}
+	all, err := selectFolders()
+	if err != nil {
+		return err
+	}
+	folders := []string{}
+	for _, folder := range all {
+		if contains(changed, folder) {
+			folders = append(folders, folder)
+		}
+	}
+	if len(folders) == 0 {
+		printf("No folders changed between %s and %s.\n", *fromTagFlag, *toTagFlag)
+		return nil
+	}
+	printf("Releasing (%d) folders changed between %s and %s.\n\n", len(folders), *fromTagFlag, *toTagFlag)
+	err = commandLine.Set("include", strings.Join(folders, ","))
+	if err != nil {
+		return err
+	}
+	d, err := deploy()
+	if d != nil {
+		printReleaseReport(d, folders)
+	}
+	return err
+}
+
+// Prints the version each folder was deployed as and the commits that changed it.
+//
+//	Release v1.3.0 -> v1.4.0
+//
+//	testLambda01: version 42 (TEST)
+//	    0fd104e Bump the SDK
+func printReleaseReport(d *data, folders []string) {
+	printf("Release %s -> %s\n", *fromTagFlag, *toTagFlag)
+	environments := []string{}
+	for environment := range d.lock {
+		environments = append(environments, environment)
+	}
+	sort.Strings(environments)
+	for _, folder := range folders {
+		printf("\n")
+		deployed := false
+		for _, environment := range environments {
+			entry, ok := d.lock[environment][folder]
+			if !ok {
+				continue
+			}
+			deployed = true
+			printf("%s: version %s (%s) in %s\n", folder, entry.Version, orDefault(entry.Alias, "$LATEST"), environment)
+		}
+		if !deployed {
+			printf("%s: not deployed\n", folder)
+		}
+		commits, err := git("log", "--oneline", *fromTagFlag+".."+*toTagFlag, "--", folder)
+		if err != nil {
+			printf("    failed to list commits: %s\n", err.Error())
+			continue
+		}
+		for _, commit := range strings.Split(commits, "\n") {
+			printf("    %s\n", commit)
+		}
+	}
+	printf("\n")
+}
@@ -0,0 +1,180 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// Statuses of a folder in the summary report.
+const (
+	reportUpToDate = "up to date"
+	reportDeployed = "deployed"
+	reportPlanned  = "planned"
+	// published, but the alias did not move because another member of its release unit failed
+	reportPublished = "published"
+	reportSkipped   = "skipped"
+	reportFailed    = "failed"
+	// not started because more folders failed than -max-failures allows
+	reportCancelled = "cancelled"
+)
+
+// What happened to each folder in this run, printed at the end of the run.
+type runReport struct {
+	mu sync.Mutex
+	// keyed by folder and region
+	folders map[string]*folderReport
+}
+
+// Also written to the -output manifest, so the JSON field names must not change.
+type folderReport struct {
+	Folder string `json:"folder"`
+	Region string `json:"region,omitempty"`
+	Status string `json:"status"`
+	// the version published, or $LATEST in latest-only mode
+	Version string `json:"version,omitempty"`
+	Alias   string `json:"alias,omitempty"`
+	// the version the alias pointed at before this run, to roll back to
+	PreviousVersion string `json:"previousVersion,omitempty"`
+	// bytes of the unsigned deployment package
+	Size              int    `json:"size,omitempty"`
+	UnsignedHash      string `json:"unsignedHash,omitempty"`
+	SignedHash        string `json:"signedHash,omitempty"`
+	UnsignedKey       string `json:"unsignedKey,omitempty"`
+	UnsignedVersionID string `json:"unsignedVersionId,omitempty"`
+	SigningJobID      string `json:"signingJobId,omitempty"`
+	SignedKey         string `json:"signedKey,omitempty"`
+	SignedVersionID   string `json:"signedVersionId,omitempty"`
+	// the packages that contribute the most code, with -size-report
+	SizeReport []packageSize `json:"sizeReport,omitempty"`
+}
+
+func newRunReport() *runReport {
+	return &runReport{folders: map[string]*folderReport{}}
+}
+
+// Updates what happened to the folder in the region of the run.
+//
+//	d.report.update(folder, d.targetRegion, func(r *folderReport) { r.Status = reportDeployed })
+func (r *runReport) update(folder, region string, f func(*folderReport)) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := folder + "/" + region
+	fr, ok := r.folders[key]
+	if !ok {
+		fr = &folderReport{Folder: folder, Region: region}
+		r.folders[key] = fr
+	}
+	f(fr)
+}
+
+// Marks the folder as failed in the region.
+func (r *runReport) fail(folder, region string) {
+	r.update(folder, region, func(fr *folderReport) { fr.Status = reportFailed })
+}
+
+// Writes a record of the run to path for downstream tooling, e.g. Terraform or auditors.
+//
+//	{
+//	  "runId": "4b48927c2f9a38f3",
+//	  "folders": [
+//	    {"folder": "testLambda01", "status": "deployed", "version": "42", "signingJobId": "...", ...}
+//	  ]
+//	}
+func (d *data) writeManifest(path string) error {
+	d.report.mu.Lock()
+	defer d.report.mu.Unlock()
+	manifest := struct {
+		RunID       string          `json:"runId"`
+		GitSHA      string          `json:"gitSha,omitempty"`
+		Environment string          `json:"environment,omitempty"`
+		Bucket      string          `json:"signedBucket"`
+		Folders     []*folderReport `json:"folders"`
+	}{
+		RunID:       d.runID,
+		GitSHA:      d.gitSHA,
+		Environment: d.environment,
+		Bucket:      d.signedBucket,
+		Folders:     []*folderReport{},
+	}
+	for _, key := range d.report.keys() {
+		manifest.Folders = append(manifest.Folders, d.report.folders[key])
+	}
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// Returns the keys of the folders in order. Expects the lock to be held.
+func (r *runReport) keys() []string {
+	keys := []string{}
+	for key := range r.folders {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Prints a table of what happened to each folder, and how long each of its steps took,
+// then how to roll back each alias that moved.
+//
+//	FOLDER        STATUS      VERSION  ALIAS          SIZE    STEPS
+//	testLambda01  deployed    42       TEST: 41 → 42  5.12 M  check 312ms, build 4.2s, zip 180ms, ...
+//	testLambda02  up to date                                  check 290ms
+//
+//	To roll back testLambda01: builder rollback -function=testLambda01 -rollback-to=41
+func (d *data) printReport() {
+	d.report.mu.Lock()
+	defer d.report.mu.Unlock()
+	keys := d.report.keys()
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	header := "FOLDER\tSTATUS\tVERSION\tALIAS\tSIZE\tSTEPS"
+	if len(d.regions) != 0 {
+		header = "FOLDER\tREGION\tSTATUS\tVERSION\tALIAS\tSIZE\tSTEPS"
+	}
+	fmt.Fprintln(w, header)
+	rollbacks := []string{}
+	for _, key := range keys {
+		fr := d.report.folders[key]
+		size := ""
+		if fr.Size != 0 {
+			size = fmt.Sprintf("%.2f M", float64(fr.Size)/1000000)
+		}
+		steps := []string{}
+		for _, step := range d.metrics.folderSteps(fr.Folder, fr.Region) {
+			steps = append(steps, fmt.Sprintf("%s %s", step.step, formatDuration(step.took)))
+		}
+		alias := fr.Alias
+		if alias != "" && fr.Status == reportDeployed {
+			alias = aliasChange(fr.Alias, fr.PreviousVersion, fr.Version)
+			if fr.PreviousVersion != "" && fr.PreviousVersion != fr.Version {
+				rollback := fmt.Sprintf("To roll back %s: builder rollback -function=%s -rollback-to=%s", fr.Folder, d.functionName(fr.Folder), fr.PreviousVersion)
+				if fr.Region != "" {
+					rollback += " -region=" + fr.Region
+				}
+				rollbacks = append(rollbacks, rollback)
+			}
+		}
+		columns := []string{fr.Folder, fr.Status, fr.Version, alias, size, strings.Join(steps, ", ")}
+		if len(d.regions) != 0 {
+			columns = append([]string{fr.Folder, fr.Region}, columns[1:]...)
+		}
+		fmt.Fprintln(w, strings.Join(columns, "\t"))
+	}
+	w.Flush()
+	printf("%s\n", buf.String())
+	if len(rollbacks) != 0 {
+		printf("%s\n\n", strings.Join(rollbacks, "\n"))
+	}
+}
@@ -0,0 +1,34 @@
+package deploy
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+)
+
+// Retries AWS calls that fail with throttling or transient 5xx errors up to maxAttempts times,
+// waiting a jittered, exponentially growing delay of at most maxBackoff between attempts.
+func retryAWSCalls(cfg *aws.Config, maxAttempts int, maxBackoff time.Duration) {
+	cfg.Retryer = func() aws.Retryer {
+		return retry.NewStandard(func(o *retry.StandardOptions) {
+			o.MaxAttempts = maxAttempts
+			o.MaxBackoff = maxBackoff
+			// deploying many folders at once throttles often enough to drain the default retry quota,
+			// after which the SDK stops retrying altogether
+			o.RateLimiter = unlimitedRetries{}
+		})
+	}
+}
+
+// A retry quota that never runs out.
+type unlimitedRetries struct{}
+
+func (unlimitedRetries) GetToken(ctx context.Context, cost uint) (func() error, error) {
+	return func() error { return nil }, nil
+}
+
+func (unlimitedRetries) AddTokens(uint) error {
+	return nil
+}
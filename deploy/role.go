@@ -0,0 +1,31 @@
+package deploy
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Returns the role to deploy with and its external ID.
+// The flags take precedence over the role of the selected environment.
+func roleToAssume() (string, string) {
+	if *assumeRoleARNFlag != "" {
+		return *assumeRoleARNFlag, *externalIDFlag
+	}
+	ec := environmentConfigs[*environmentFlag]
+	return ec.RoleARN, orDefault(*externalIDFlag, ec.ExternalID)
+}
+
+// Makes cfg use temporary credentials of the role, e.g. to build in a CI account and deploy into a workload account.
+// The credentials cfg was loaded with are used to assume the role, and the temporary credentials are refreshed before they expire,
+// so only the credentials cfg was loaded with have to last the whole run, see checkCredentialLifetime.
+func assumeRole(cfg *aws.Config, roleARN, externalID, sessionName string) {
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(*cfg), roleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = sessionName
+		if externalID != "" {
+			o.ExternalID = aws.String(externalID)
+		}
+		o.Duration = *roleDurationFlag
+	})
+	cfg.Credentials = aws.NewCredentialsCache(provider, refreshEarly)
+}
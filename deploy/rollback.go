@@ -0,0 +1,150 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// Points the alias of each function back at the version published before the one it points at.
+//
+//	builder rollback -function=testLambda01
+//	builder rollback -all -exclude=internal,testLambda02
+//	builder rollback -function=testLambda01 -rollback-to=0fd104e -history-table=deployments
+//
+// With -rollback-to, points the alias at that version, or at the version deployed from that commit.
+// With -read-only, prints the version each alias would be pointed at.
+func rollback() error {
+	folders, err := selectFunctions()
+	if err != nil {
+		return err
+	}
+	aliasOverrides, err := parseOverrides(*aliasOverridesFlag)
+	if err != nil {
+		return err
+	}
+	err = mergeFolderConfigs(map[string]string{}, aliasOverrides, map[string]string{})
+	if err != nil {
+		return err
+	}
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return err
+	}
+	d := &data{
+		configs:            folderConfigs,
+		functionPrefix:     *functionPrefixFlag,
+		functionSuffix:     *functionSuffixFlag,
+		ctx:                context.TODO(),
+		metrics:            runMetrics,
+		readOnly:           *readOnlyFlag,
+		lambda:             lambda.NewFromConfig(cfg),
+		alias:              *aliasFlag,
+		aliasOverrides:     aliasOverrides,
+		verifyAliasTimeout: *verifyAliasTimeoutFlag,
+		region:             cfg.Region,
+		history:            dynamodb.NewFromConfig(cfg),
+		historyTable:       *historyTableFlag,
+	}
+	printf("Rolling back (%d) functions: %s.\n\n", len(folders), strings.Join(folders, ", "))
+	failures := []string{}
+	for _, folder := range folders {
+		err := d.rollback(folder)
+		if err != nil {
+			failures = append(failures, folder)
+		}
+	}
+	if len(failures) != 0 {
+		return fmt.Errorf("failed to roll back: %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+func (d *data) rollback(folder string) error {
+	alias := d.aliasFor(folder)
+	d.logf(folder, "rollback", "Getting alias %s of Lambda function.", alias)
+	output, err := d.lambda.GetAlias(d.ctx, &lambda.GetAliasInput{
+		FunctionName: aws.String(d.functionName(folder)),
+		Name:         aws.String(alias),
+	})
+	if err != nil {
+		d.failf(folder, "rollback", err, "Failed to get alias of Lambda function: %s", err.Error())
+		return err
+	}
+	current := aws.ToString(output.FunctionVersion)
+	previous, err := d.rollbackVersion(folder, current)
+	if err != nil {
+		d.failf(folder, "rollback", err, "Failed to find the version to roll back to: %s", err.Error())
+		return err
+	}
+	if previous == current {
+		d.donef(folder, "rollback", "Alias %s already points at version %s.", alias, current)
+		return nil
+	}
+	if d.readOnly {
+		d.donef(folder, "rollback", "Read-only mode, would point alias %s at version %s instead of %s.", alias, previous, current)
+		return nil
+	}
+	d.logf(folder, "rollback", "Pointing alias %s at version %s instead of %s.", alias, previous, current)
+	_, err = d.lambda.UpdateAlias(d.ctx, &lambda.UpdateAliasInput{
+		FunctionName:    aws.String(d.functionName(folder)),
+		Name:            aws.String(alias),
+		FunctionVersion: aws.String(previous),
+	})
+	if err != nil {
+		d.failf(folder, "rollback", err, "Failed to update alias of Lambda function: %s", err.Error())
+		return err
+	}
+	d.donef(folder, "rollback", "Rolled back alias %s to version %s.", alias, previous)
+	return d.verifyAlias(folder, alias, previous)
+}
+
+// Returns the version to point the alias at instead of current: -rollback-to if it is a version,
+// the version deployed from the commit -rollback-to if it is not, or the version before current.
+func (d *data) rollbackVersion(folder, current string) (string, error) {
+	to := *rollbackToFlag
+	switch {
+	case to == "":
+		return d.previousVersion(folder, current)
+	case isVersionNumber(to):
+		return to, nil
+	}
+	return d.historicalVersion(folder, to)
+}
+
+// Returns the newest published version of the function that is older than version.
+func (d *data) previousVersion(folder, version string) (string, error) {
+	current, err := strconv.Atoi(version)
+	if err != nil {
+		return "", fmt.Errorf("alias points at %s, not a published version", version)
+	}
+	previous := 0
+	paginator := lambda.NewListVersionsByFunctionPaginator(d.lambda, &lambda.ListVersionsByFunctionInput{
+		FunctionName: aws.String(d.functionName(folder)),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(d.ctx)
+		if err != nil {
+			return "", err
+		}
+		for _, configuration := range output.Versions {
+			// skips $LATEST
+			v, err := strconv.Atoi(aws.ToString(configuration.Version))
+			if err != nil {
+				continue
+			}
+			if v < current && v > previous {
+				previous = v
+			}
+		}
+	}
+	if previous == 0 {
+		return "", fmt.Errorf("no version was published before %s", version)
+	}
+	return strconv.Itoa(previous), nil
+}
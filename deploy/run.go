@@ -0,0 +1,1288 @@
+package deploy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/signer"
+	signerTypes "github.com/aws/aws-sdk-go-v2/service/signer/types"
+)
+
+type data struct {
+	// context to use in api calls
+	ctx context.Context
+	// provenance of this run
+	runID  string
+	gitSHA string
+	// the remote and branch recorded on signed deployment packages, empty without -source-metadata
+	source string
+	// where to record the output of this run
+	events *eventLog
+	// called with every line of output, see DeployFolder
+	onStatus func(Status)
+	// only send output to onStatus, not to stdout, for DeployFolder
+	quiet bool
+	// times the steps of each folder, shared by every copy of the run
+	metrics *metrics
+	// the spans of each folder and step to export with -otlp-endpoint, nil to not record them
+	trace *trace
+	// what happened to each folder, printed at the end of the run
+	report *runReport
+	// whether to report the packages that contribute the most code to each executable
+	sizeReport bool
+	// versions waiting for the rest of their release unit before their aliases move
+	units *releaseUnits
+	// what each function deployed by this run runs, added to the lock file if there is one
+	lock   lockFile
+	lockMu *sync.Mutex
+	// tags to apply to every function and S3 object
+	tags map[string]string
+	// git metadata to stamp into each executable, zero if not stamping
+	stamp        buildStamp
+	stampPackage string
+	// what this run deploys, for the policy
+	folders []string
+	// the folder blocks of the config file, keyed by folder, empty for DeployFolder
+	configs map[string]folderConfig
+	// what each function is named around its folder, see functionName
+	functionPrefix string
+	functionSuffix string
+	environment    string
+	region         string
+	// copies of this run for each region to deploy to, empty to only deploy to region
+	regions []*data
+	// the region to show in the output, only set on the copies in regions
+	targetRegion string
+	// policy config
+	policy      string
+	policyQuery string
+	approved    bool
+	// flags
+	noUpload          bool
+	noSigningJobs     bool
+	noCopySigned      bool
+	noUpdateFunctions bool
+	createMissing     bool
+	force             bool
+	readOnly          bool
+	// go build config
+	env           []string
+	arch          string
+	archOverrides map[string]string
+	// git credentials for private modules, only passed to go build
+	gitEnv []string
+	// the go that builds every folder
+	goToolchain goToolchain
+	// limits how many go builds run at once
+	builds semaphore
+	// values that must never be printed
+	secrets []string
+	// zip config
+	handler string
+	zip     zipCompression
+	// deployment packages built elsewhere to deploy instead of building, keyed by folder
+	artifacts map[string]string
+	// command whose output is hashed instead of the source code, empty to hash the source code
+	hashCommand string
+	// s3 config
+	s3 s3API
+	// uploads deployment packages in parts, so large ones upload reliably
+	uploader *manager.Uploader
+	// the size in MiB of each part of the uploader, and how many it uploads at once
+	uploadPartSize    int64
+	uploadConcurrency int
+	unsignedBucket    string
+	stagingBucket     string
+	signedBucket      string
+	unsignedPrefix    string
+	stagingPrefix     string
+	signedPrefix      string
+	// where tools are uploaded in the signed bucket, and the targets of each tool
+	toolsPrefix string
+	// where to upload executables with debug symbols in the signed bucket, empty to not build them
+	debugSymbolsPrefix string
+	toolTargets        map[string][]string
+	// the layers each function uses, and the ARN of the latest version of each layer
+	functionLayers map[string][]string
+	layerARNs      map[string]string
+	// signer config
+	signer                  signerAPI
+	signingProfile          string
+	signingProfileOverrides map[string]string
+	signingJobWaiter        *signer.SuccessfulSigningJobWaiter
+	signingTimeout          time.Duration
+	// the copy of the run in the -signer-fallback region, when this region has no AWS Signer
+	signingVia *data
+	// check the signing job and signed deployment package after copying it
+	verifySigned bool
+	// whether to attach code signing configs, and what the ones it creates do with unsigned code
+	codeSigningConfig bool
+	codeSigningPolicy lambdaTypes.CodeSigningPolicy
+	// the code signing config of each signing profile, with -code-signing-config
+	codeSigningConfigs *codeSigningConfigs
+	// how long to wait between checks of signing jobs and functions being updated or created
+	waiterMinDelay time.Duration
+	waiterMaxDelay time.Duration
+	// lambda config
+	lambda                lambdaAPI
+	functionUpdatedWaiter *lambda.FunctionUpdatedV2Waiter
+	updateTimeout         time.Duration
+	// how long to wait for a published version to become active, e.g. while SnapStart snapshots it
+	publishedVersionWaiter *lambda.PublishedVersionActiveWaiter
+	publishTimeout         time.Duration
+	alias                  string
+	aliasOverrides         map[string]string
+	createAlias            bool
+	latestOnly             bool
+	aliasHistory           int
+	// who is deploying and the template of each version's description, nil to publish versions without one
+	user                       string
+	versionDescriptionTemplate *template.Template
+	verifyAliasTimeout         time.Duration
+	// how long to wait for the provisioned concurrency of a new version to be ready
+	provisionedConcurrencyTimeout time.Duration
+	// how much traffic to shift to the new version, and for how long, before pointing the alias at it
+	canaryPercent float64
+	canaryBake    time.Duration
+	canaryAlarms  []string
+	cloudwatch    *cloudwatch.Client
+	// where to record each deployment, with -history-table
+	history      *dynamodb.Client
+	historyTable string
+	// preview config
+	preview        string
+	previewURLAuth lambdaTypes.FunctionUrlAuthType
+	previewURLs    map[string]string
+	previewURLsMu  *sync.Mutex
+	previewTTL     time.Duration
+	// plan config
+	plan         *planTotals
+	s3PricePerGB float64
+}
+
+func (d *data) run(folder string) error {
+	if targets, ok := d.toolTargets[folder]; ok {
+		errs := regionErrors{}
+		for _, t := range d.targets() {
+			err := t.runTool(folder, targets)
+			if err != nil {
+				errs[t.region] = err
+				continue
+			}
+			t.report.update(folder, t.targetRegion, func(r *folderReport) { r.Status = reportDeployed })
+		}
+		return d.regionError(errs)
+	}
+	executablePath := fmt.Sprintf("/tmp/%s", folder)
+	arch := d.archFor(folder)
+	artifact, prebuilt := d.artifacts[folder]
+	var unsignedHash string
+	var err error
+	if !prebuilt {
+		err = d.checkGoVersion(folder)
+		if err != nil {
+			return buildError{err}
+		}
+		err = d.runPrebuildHook(folder)
+		if err != nil {
+			return buildError{err}
+		}
+	}
+	if prebuilt {
+		unsignedHash, err = d.hashArtifact(folder, artifact)
+	} else if d.hashCommand != "" {
+		unsignedHash, err = d.hashWithCommand(folder)
+	} else {
+		unsignedHash, err = d.hashSourceCode(folder)
+	}
+	if err != nil {
+		return buildError{err}
+	}
+	// the executable is built once and deployed to every region that is out of date
+	targets := []*data{}
+	errs := regionErrors{}
+	for _, t := range d.targets() {
+		deploy, err := t.needsDeploy(folder, unsignedHash, arch)
+		if err != nil {
+			errs[t.region] = err
+		} else if deploy {
+			targets = append(targets, t)
+		} else {
+			t.report.update(folder, t.targetRegion, func(r *folderReport) {
+				r.Status = reportUpToDate
+				r.UnsignedHash = unsignedHash
+			})
+		}
+	}
+	if len(targets) != 0 && prebuilt {
+		pkg, size, err := d.readArtifact(folder, artifact)
+		if err != nil {
+			return buildError{err}
+		}
+		for _, t := range targets {
+			err := t.deploy(folder, pkg.Bytes(), size, unsignedHash, arch, "")
+			if err == nil {
+				err = t.runPostdeployHook(folder)
+			}
+			if err != nil {
+				errs[t.region] = err
+			}
+		}
+	} else if len(targets) != 0 {
+		err = d.buildExecutable(folder, executablePath, "linux", arch)
+		if err != nil {
+			return buildError{err}
+		}
+		defer d.deleteFile(folder, executablePath)
+		err = d.checkUnzippedSize(folder, executablePath)
+		if err != nil {
+			return buildError{err}
+		}
+		if d.sizeReport {
+			d.reportSize(folder, executablePath, targets)
+		}
+		unsignedR, err := d.zipExecutable(folder, executablePath, d.handlerFor(folder))
+		if err != nil {
+			return buildError{err}
+		}
+		pkg, size, err := d.sizeExecutable(folder, unsignedR)
+		if err != nil {
+			return buildError{err}
+		}
+		debugPath := ""
+		if d.debugSymbolsPrefix != "" {
+			debugPath = executablePath + ".debug"
+			err = d.buildDebugExecutable(folder, debugPath, arch)
+			if err != nil {
+				return buildError{err}
+			}
+			defer d.deleteFile(folder, debugPath)
+		}
+		for _, t := range targets {
+			err := t.deploy(folder, pkg.Bytes(), size, unsignedHash, arch, debugPath)
+			if err == nil {
+				err = t.runPostdeployHook(folder)
+			}
+			if err != nil {
+				errs[t.region] = err
+			}
+		}
+	}
+	return d.regionError(errs)
+}
+
+// Returns true if the folder's deployment package is out of date.
+// In latest-only mode, points $LATEST at an up to date deployment package if it is not running it.
+func (d *data) needsDeploy(folder, unsignedHash, arch string) (bool, error) {
+	if d.force {
+		d.skipf(folder, "run", "Not checking if previous deployment package is up to date.")
+		return true, nil
+	}
+	// previews are compared against the main pipeline so only changed folders are deployed
+	upToDateKey := objectKey(d.signedPrefix, folder+".zip")
+	isUpToDate, err := d.isUpToDate(folder, upToDateKey, unsignedHash, arch)
+	if err != nil {
+		return false, err
+	}
+	if isUpToDate && d.latestOnly {
+		// the deployment package is up to date, but $LATEST may not be running it
+		isDeployed, err := d.isLatestDeployed(folder, upToDateKey)
+		if err != nil {
+			return false, err
+		}
+		if !isDeployed {
+			return false, d.updateLatest(folder, upToDateKey, arch)
+		}
+	}
+	return !isUpToDate, nil
+}
+
+// Signs the deployment package and deploys it to the folder's function.
+// Uploads the executable at debugPath as the function's debug symbols, if debugPath is not empty.
+func (d *data) deploy(folder string, pkg []byte, size int, unsignedHash, arch, debugPath string) error {
+	unsignedKey := objectKey(d.unsignedPrefix, folder+".zip")
+	signedKey := objectKey(d.signedPrefix, folder+".zip")
+	if d.preview != "" {
+		signedKey = previewKey(d.signedPrefix, d.preview, folder)
+	}
+	d.report.update(folder, d.targetRegion, func(r *folderReport) {
+		r.Status = reportSkipped
+		r.Size = size
+		r.UnsignedHash = unsignedHash
+	})
+	err := d.checkPolicy(folder, size)
+	if err != nil {
+		return err
+	}
+	if d.readOnly {
+		d.printPlan(folder, unsignedKey, signedKey, size)
+		d.report.update(folder, d.targetRegion, func(r *folderReport) { r.Status = reportPlanned })
+		return nil
+	}
+	if d.noUpload {
+		d.skipf(folder, "run", "Not uploading unsigned deployment package to S3.")
+		return nil
+	}
+	// without a signing profile, the unsigned deployment package is deployed as is
+	unsigned := d.signingProfileFor(folder) == ""
+	signedHash := ""
+	stagingKey := ""
+	// only read into memory when the package was signed in another region
+	var signedPkg []byte
+	signingJobID := ""
+	if unsigned {
+		d.logf(folder, "run", "No signing profile, deploying unsigned deployment package.")
+		signedHash, err = d.hashObject(folder, bytes.NewReader(pkg))
+		if err != nil {
+			return err
+		}
+	} else {
+		// regions without AWS Signer sign in the -signer-fallback region
+		s := d.signingRun()
+		objectVersion, err := s.putObject(folder, unsignedKey, bytes.NewReader(pkg))
+		if err != nil {
+			return err
+		}
+		defer s.deleteObject(folder, s.unsignedBucket, unsignedKey)
+		if d.noSigningJobs {
+			d.skipf(folder, "run", "Not starting signing job.")
+			return nil
+		}
+		jobId, err := s.startSigningJob(folder, unsignedKey, objectVersion)
+		if err != nil {
+			return err
+		}
+		d.report.update(folder, d.targetRegion, func(r *folderReport) {
+			r.UnsignedKey = unsignedKey
+			r.UnsignedVersionID = objectVersion
+			r.SigningJobID = jobId
+		})
+		signingJobID = jobId
+		stagingKey = objectKey(d.stagingPrefix, jobId+".zip")
+		err = s.waitForSigningJob(folder, jobId)
+		if err != nil {
+			return err
+		}
+		defer s.deleteObject(folder, s.stagingBucket, stagingKey)
+		signedR, err := s.getObject(folder, stagingKey)
+		if err != nil {
+			return err
+		}
+		defer signedR.Close()
+		if s != d {
+			// the staging bucket is in another region, so the package cannot be copied server-side
+			signedPkg, err = io.ReadAll(signedR)
+			if err != nil {
+				d.failf(folder, "download", err, "Failed to download signed deployment package: %s", err.Error())
+				return err
+			}
+			signedHash, err = d.hashObject(folder, bytes.NewReader(signedPkg))
+		} else {
+			signedHash, err = d.hashObject(folder, signedR)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if d.noCopySigned {
+		d.skipf(folder, "run", "Not copying signed deployment package to signed/.")
+		return nil
+	}
+	metadata := map[string]string{
+		"unsignedHash":     unsignedHash,
+		"signedHash":       signedHash,
+		"source-code-hash": signedHash,
+		"arch":             arch,
+	}
+	for key, value := range d.stamp.tags() {
+		metadata[key] = value
+	}
+	if d.source != "" {
+		metadata[sourceMetadata] = d.source
+	}
+	if signingJobID != "" {
+		metadata[signingJobMetadata] = signingJobID
+		metadata[signingRegionMetadata] = d.signingRun().region
+	}
+	signedVersion := ""
+	if unsigned {
+		signedVersion, err = d.putUnsignedAsSigned(folder, signedKey, bytes.NewReader(pkg), metadata)
+	} else if d.signingVia != nil {
+		signedVersion, err = d.replicateSigned(folder, signedKey, bytes.NewReader(signedPkg), metadata)
+	} else {
+		signedVersion, err = d.copyObject(folder, stagingKey, signedKey, metadata)
+	}
+	if err != nil {
+		return err
+	}
+	d.report.update(folder, d.targetRegion, func(r *folderReport) {
+		r.SignedHash = signedHash
+		r.SignedKey = signedKey
+		r.SignedVersionID = signedVersion
+	})
+	if signingJobID != "" && d.verifySigned {
+		err = d.signingRun().checkSigningJob(folder, signingJobID)
+		if err != nil {
+			return err
+		}
+		err = d.checkSignedPackage(folder, signedKey, signedHash)
+		if err != nil {
+			return err
+		}
+	}
+	d.waitForSignedMetadata(folder, signedKey, unsignedHash)
+	// lets the function be traced back to the run and deployment package that last updated its code
+	tags := withTag(withTag(d.tags, "run-id", d.runID), "source-code-hash", signedHash)
+	if debugPath != "" {
+		location, err := d.uploadDebugSymbols(folder, debugPath, signedHash)
+		if err != nil {
+			return err
+		}
+		tags = withTag(tags, debugSymbolsTag, location)
+	}
+	if d.noUpdateFunctions {
+		d.skipf(folder, "run", "Not updating Lambda function code.")
+		return nil
+	}
+	err = d.checkCodeSigningConfig(folder)
+	if err != nil {
+		return err
+	}
+	err = d.updateFunctionCode(folder, signedKey, arch, tags)
+	if err != nil {
+		return err
+	}
+	err = d.waitForFunctionUpdate(folder)
+	if err != nil {
+		return err
+	}
+	err = d.updateConfiguration(folder)
+	if err != nil {
+		return err
+	}
+	if d.latestOnly {
+		d.recordDeploy(folder, lockEntry{SignedHash: signedHash, Version: "$LATEST"})
+		d.report.update(folder, d.targetRegion, func(r *folderReport) {
+			r.Status = reportDeployed
+			r.Version = "$LATEST"
+		})
+		d.skipf(folder, "run", "Not publishing a version in latest-only mode.")
+		return nil
+	}
+	functionVersion, err := d.publishLambdaVersion(folder, signedHash)
+	if err != nil {
+		return err
+	}
+	if unit := d.unitOf(folder); unit != "" {
+		d.report.update(folder, d.targetRegion, func(r *folderReport) {
+			r.Status = reportPublished
+			r.Version = functionVersion
+		})
+		d.holdAlias(unit, folder, functionVersion, signedHash)
+		return nil
+	}
+	err = d.moveAlias(folder, functionVersion, signedHash)
+	if err != nil {
+		return err
+	}
+	if d.preview != "" {
+		url, err := d.createPreviewURL(folder, d.aliasFor(folder))
+		if err != nil {
+			return err
+		}
+		d.addPreviewURL(folder, url)
+		err = d.tagPreview(folder, d.aliasFor(folder))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Points the folder's alias at the published version and checks that it took.
+func (d *data) moveAlias(folder, functionVersion, signedHash string) error {
+	err := d.switchAlias(folder, functionVersion)
+	if err != nil {
+		return err
+	}
+	d.recordAliasMove(folder, functionVersion, signedHash)
+	return nil
+}
+
+// Points the folder's alias at the version, checks that it took, and moves provisioned concurrency to the version.
+// The version the alias pointed at before is in the report's PreviousVersion.
+func (d *data) switchAlias(folder, functionVersion string) error {
+	err := d.updateFunctionAlias(folder, functionVersion)
+	if err != nil {
+		return err
+	}
+	err = d.verifyAlias(folder, d.aliasFor(folder), functionVersion)
+	if err != nil {
+		return err
+	}
+	return d.provisionConcurrency(folder, functionVersion, d.previousAliasVersion(folder))
+}
+
+// Records that the folder's alias moved to the version, in the report and, unless previewing, in the lock file and history table.
+func (d *data) recordAliasMove(folder, functionVersion, signedHash string) {
+	var deployed folderReport
+	d.report.update(folder, d.targetRegion, func(r *folderReport) {
+		r.Status = reportDeployed
+		r.Version = functionVersion
+		r.Alias = d.aliasFor(folder)
+		deployed = *r
+	})
+	if d.preview == "" {
+		d.recordDeploy(folder, lockEntry{SignedHash: signedHash, Version: functionVersion, Alias: d.aliasFor(folder)})
+		d.recordHistory(folder, deployed)
+	}
+}
+
+// Returns the version the folder's alias pointed at before the run moved it, or an empty string if it did not exist.
+func (d *data) previousAliasVersion(folder string) string {
+	previous := ""
+	d.report.update(folder, d.targetRegion, func(r *folderReport) { previous = r.PreviousVersion })
+	return previous
+}
+
+// Returns an error if the builder is running in read-only mode.
+// Every step that changes something in AWS must call this first.
+func (d *data) refuseInReadOnly(folder, action string) error {
+	if !d.readOnly {
+		return nil
+	}
+	d.logf(folder, "read-only", "Refusing to run %s in read-only mode.", action)
+	return fmt.Errorf("refusing to run %s in read-only mode", action)
+}
+
+func (d *data) hashSourceCode(folder string) (string, error) {
+	d.logf(folder, "hash", "Hashing source code.")
+	// search for files that match the patterns go.* or *.go e.g. go.mod go.sum main.go
+	filenames := []string{}
+	a, err := filepath.Glob(sourceFolder(folder) + "/go.*")
+	if err != nil {
+		d.failf(folder, "hash", err, "Failed to search with go.*: %s.", err.Error())
+		return "", err
+	}
+	filenames = append(filenames, a...)
+	b, err := filepath.Glob(sourceFolder(folder) + "/*.go")
+	if err != nil {
+		d.failf(folder, "hash", err, "Failed to search with *.go: %s.", err.Error())
+		return "", err
+	}
+	filenames = append(filenames, b...)
+	// packages outside the folder change the executable too, e.g. shared internal packages
+	deps, modules, err := d.dependencies(folder)
+	if err != nil {
+		d.failf(folder, "hash", err, "Failed to list dependencies: %s.", err.Error())
+		return "", err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		d.failf(folder, "hash", err, "Failed to get working directory: %s.", err.Error())
+		return "", err
+	}
+	for _, dep := range deps {
+		// go list paths are absolute, the hashed paths are relative so they match between machines
+		rel, err := filepath.Rel(wd, dep)
+		if err != nil {
+			d.failf(folder, "hash", err, "Failed to hash file (%s): %s.", dep, err.Error())
+			return "", err
+		}
+		if !contains(filenames, rel) {
+			filenames = append(filenames, rel)
+		}
+	}
+	sort.Strings(filenames)
+	d.logf(
+		folder,
+		"hash",
+		"Hashing %d files: %s",
+		len(filenames),
+		strings.Join(filenames, ", "),
+	)
+	// hash files
+	h := sha256.New()
+	// matrix builds of the same folder have the same files
+	if tags := buildTags(folder); tags != "" {
+		io.WriteString(h, "-tags="+tags+"\n")
+	}
+	for _, flag := range buildVars(folder) {
+		io.WriteString(h, flag+"\n")
+	}
+	for _, module := range modules {
+		io.WriteString(h, module+"\n")
+	}
+	for _, filename := range filenames {
+		file, err := os.Open(filename)
+		if err != nil {
+			d.failf(folder, "hash", err, "Failed to open file (%s): %s.", filename, err.Error())
+			return "", err
+		}
+		_, err = io.Copy(h, file)
+		file.Close()
+		if err != nil {
+			d.failf(folder, "hash", err, "Failed to hash file (%s): %s.", filename, err.Error())
+			return "", err
+		}
+	}
+	err = d.hashAssets(folder, h)
+	if err != nil {
+		d.failf(folder, "hash", err, "Failed to hash assets: %s.", err.Error())
+		return "", err
+	}
+	hash := string(base64.StdEncoding.EncodeToString(h.Sum(nil)))
+	d.donef(folder, "hash", "Hashed source code: %s", hash)
+	return hash, nil
+}
+
+func (d *data) deleteFile(folder, path string) {
+	d.logf(folder, "cleanup", "Deleting file: %s.", path)
+	err := os.Remove(path)
+	if err != nil {
+		d.failf(folder, "cleanup", err, "Failed to delete file (%s): %s.", path, err.Error())
+		return
+	}
+	d.donef(folder, "cleanup", "Deleted file: %s.", path)
+}
+
+func (d *data) buildExecutable(folder, executablePath, goos, arch string) error {
+	return d.goBuild(folder, executablePath, goos, arch, true)
+}
+
+// Builds the executable without stripping its symbol table and DWARF debug info.
+func (d *data) buildDebugExecutable(folder, executablePath, arch string) error {
+	return d.goBuild(folder, executablePath, "linux", arch, false)
+}
+
+func (d *data) goBuild(folder, executablePath, goos, arch string, strip bool) error {
+	d.builds.acquire()
+	defer d.builds.release()
+	what := "executable"
+	if !strip {
+		what = "executable with debug symbols"
+	}
+	if goos == "linux" {
+		d.logf(folder, "build", "Building %s for %s.", what, arch)
+	} else {
+		d.logf(folder, "build", "Building %s for %s/%s.", what, goos, arch)
+	}
+	args := []string{"build", "-trimpath"}
+	if tags := buildTags(folder); tags != "" {
+		args = append(args, "-tags="+tags)
+	}
+	ldflags := append(d.stamp.ldflags(d.stampPackage), buildVars(folder)...)
+	if strip {
+		ldflags = append([]string{"-s", "-w"}, ldflags...)
+	}
+	if len(ldflags) != 0 {
+		args = append(args, "-ldflags="+strings.Join(ldflags, " "))
+	}
+	// -trimpath keeps the paths of the machine out of the executable, so it is the same on every machine
+	cmd := exec.Command("go", append(args, "-o", executablePath)...)
+	cmd.Dir = sourceFolder(folder)
+	cmd.Env = append([]string{}, d.env...)
+	cmd.Env = append(cmd.Env, "GOOS="+goos)
+	cmd.Env = append(cmd.Env, "GOARCH="+arch)
+	cmd.Env = append(cmd.Env, "CGO_ENABLED=0")
+	// only give git credentials to the build step
+	cmd.Env = append(cmd.Env, d.gitEnv...)
+	// only print the output of go build if it fails
+	output := &bytes.Buffer{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := cmd.Run()
+	if err != nil {
+		d.failf(
+			folder,
+			"build",
+			err,
+			"Failed to build %s: %s.\n%s",
+			what,
+			err.Error(),
+			scrub(output.String(), d.secrets),
+		)
+		return err
+	}
+	d.donef(folder, "build", "Built %s.", what)
+	return nil
+}
+
+// Lambda refuses functions whose code and layers are bigger than this unzipped.
+const maxUnzippedSize = 250 * 1024 * 1024
+
+// Returns an error if the executable is too big for Lambda to run, before anything is uploaded.
+// Functions that big have to be deployed as container images instead.
+func (d *data) checkUnzippedSize(folder, executablePath string) error {
+	info, err := os.Stat(executablePath)
+	if err != nil {
+		d.failf(folder, "zip", err, "Failed to get size of executable: %s.", err.Error())
+		return err
+	}
+	if info.Size() > maxUnzippedSize {
+		err := fmt.Errorf(
+			"executable is %.2f M, Lambda only runs zip deployment packages up to %.2f M unzipped",
+			float64(info.Size())/1000000, float64(maxUnzippedSize)/1000000,
+		)
+		d.failf(folder, "zip", err, "Executable is too big: %s. Deploy it as a container image instead.", err.Error())
+		return err
+	}
+	return nil
+}
+
+// The -handler that names the executable after the runtime of each function.
+const autoHandler = "auto"
+
+// Returns the name of the executable in the folder's deployment package.
+// The handler of the folder block takes precedence over -handler.
+// With -handler=auto, functions on an OS-only runtime, e.g. provided.al2023, run bootstrap, and the rest run main.
+func (d *data) handlerFor(folder string) string {
+	if handler := d.configs[folder].Handler; handler != "" {
+		return handler
+	}
+	if d.handler != autoHandler {
+		return d.handler
+	}
+	output, err := d.lambda.GetFunctionConfiguration(d.ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(d.functionName(folder)),
+	})
+	runtime := ""
+	if isFunctionNotFound(err) && d.createMissing {
+		// the function gets the runtime of its folder block when it is created
+		runtime = orDefault(d.configs[folder].Runtime, defaultRuntime)
+	} else if err != nil {
+		d.skipf(folder, "zip", "Failed to get runtime of Lambda function, naming executable main: %s.", err.Error())
+		return "main"
+	} else {
+		runtime = string(output.Runtime)
+	}
+	if strings.HasPrefix(runtime, "provided") {
+		return "bootstrap"
+	}
+	return "main"
+}
+
+// Every zip entry has this modification time, so the same files always zip to the same bytes
+// no matter which machine zips them or when.
+var zipTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (d *data) zipExecutable(folder, executablePath, name string) (io.Reader, error) {
+	d.logf(folder, "zip", "Zipping executable.")
+	targetF := &bytes.Buffer{}
+	targetW := zip.NewWriter(targetF)
+	d.zip.register(targetW)
+	// create entry
+	entryW, err := targetW.CreateHeader(d.zip.header(name, 0777))
+	if err != nil {
+		d.failf(folder, "zip", err, "Failed to zip executable: %s.", err.Error())
+		return nil, err
+	}
+	// copy file into entry
+	sourceF, err := os.Open(executablePath)
+	if err != nil {
+		d.failf(folder, "zip", err, "Failed to zip executable: %s.", err.Error())
+		return nil, err
+	}
+	defer sourceF.Close()
+	written, err := io.Copy(entryW, sourceF)
+	if err != nil {
+		d.failf(folder, "zip", err, "Failed to zip executable: %s.", err.Error())
+		return nil, err
+	}
+	assetsWritten, err := d.zipAssets(folder, name, targetW)
+	if err != nil {
+		d.failf(folder, "zip", err, "Failed to zip assets: %s.", err.Error())
+		return nil, err
+	}
+	written += assetsWritten
+	if written > maxUnzippedSize {
+		err := fmt.Errorf(
+			"executable and assets are %.2f M, Lambda only runs zip deployment packages up to %.2f M unzipped",
+			float64(written)/1000000, float64(maxUnzippedSize)/1000000,
+		)
+		d.failf(folder, "zip", err, "Deployment package is too big: %s.", err.Error())
+		return nil, err
+	}
+	err = targetW.Close()
+	if err != nil {
+		d.failf(folder, "zip", err, "Failed to zip executable: %s.", err.Error())
+		return nil, err
+	}
+	ratio := 100.0
+	if written > 0 {
+		ratio = float64(targetF.Len()) / float64(written) * 100
+	}
+	d.donef(folder, "zip", "Zipped executable with %s to %.2f%% of its size.", d.zip.name(), ratio)
+	return targetF, nil
+}
+
+func (d *data) sizeExecutable(folder string, r io.Reader) (*bytes.Buffer, int, error) {
+	d.logf(folder, "zip", "Getting size of unsigned deployment package.")
+	// create a buffer to return back to the caller
+	copyBuf := &bytes.Buffer{}
+	// create a buffer to calculate the length of the input
+	lenBuf := &bytes.Buffer{}
+	// copy data from the input reader into the copy buffer
+	_, err := lenBuf.ReadFrom(io.TeeReader(r, copyBuf))
+	if err != nil {
+		d.failf(
+			folder,
+			"zip",
+			err,
+			"Failed to get size of unsigned deployment package: %s.",
+			err.Error(),
+		)
+		return nil, 0, err
+	}
+	// convert size to megabytes
+	size := float64(lenBuf.Len()) / 1000000
+	d.logf(folder, "zip", "Size of unsigned deployment package: %.2f M.", size)
+	// return the copy buffer so the data can still be accessed
+	return copyBuf, lenBuf.Len(), nil
+}
+
+// Returns true if previous deployment package is up to date.
+// Returns false if the previous deployment package does not exist.
+// Returns false if the previous deployment package does not have metadata.
+// Returns false if the previous deployment package does not have "unsignedhash".
+// Returns false if the previous deployment package's "unsignedhash" is not unsignedHash.
+// Returns false if the previous deployment package's "arch" is missing or is not arch.
+// Returns false with -source-metadata if the previous deployment package was built from another remote or branch.
+// Returns an error if the API call failed for any other reason, e.g. access denied,
+// so a misconfigured run fails instead of redeploying every folder.
+func (d *data) isUpToDate(folder, signedKey string, unsignedHash, arch string) (bool, error) {
+	d.logf(folder, "check", "Checking if previous deployment package is up to date.")
+	output, err := d.s3.HeadObject(d.ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(d.signedBucket),
+		Key:    aws.String(signedKey),
+	})
+	var notFound *s3Types.NotFound
+	if errors.As(err, &notFound) {
+		d.donef(folder, "check", "Previous deployment package %s does not exist, proceeding.", signedKey)
+		return false, nil
+	}
+	if err != nil {
+		d.failf(folder, "check", err, "Failed to get previous deployment package %s: %s", signedKey, err.Error())
+		return false, err
+	}
+	if output.Metadata == nil {
+		d.donef(folder, "check", "Previous deployment package does not have metadata, proceeding.")
+		return false, nil
+	}
+	previous, ok := output.Metadata["unsignedhash"]
+	if !ok {
+		d.donef(folder, "check", "Previous deployment package does not have unsignedhash, proceeding.")
+		return false, nil
+	}
+	if unsignedHash != previous {
+		d.donef(folder, "check", "Previous deployment is out of date, proceeding: %s.", previous)
+		return false, nil
+	}
+	if previousArch := output.Metadata["arch"]; previousArch != arch {
+		d.donef(
+			folder,
+			"check",
+			"Previous deployment was built for a different architecture, proceeding: %s.",
+			previousArch,
+		)
+		return false, nil
+	}
+	if previousSource := output.Metadata[sourceMetadata]; d.source != "" && previousSource != d.source {
+		d.donef(
+			folder,
+			"check",
+			"Previous deployment was built from %s, not %s, proceeding.",
+			orDefault(previousSource, "an unknown source"),
+			d.source,
+		)
+		return false, nil
+	}
+	d.report.update(folder, d.targetRegion, func(r *folderReport) {
+		r.SignedKey = signedKey
+		r.SignedVersionID = aws.ToString(output.VersionId)
+		r.SignedHash = output.Metadata["signedhash"]
+	})
+	d.donef(folder, "check", "Deployment package is up to date, stopping.")
+	return true, nil
+}
+
+// Returns an uploader that uploads in parts of partSize MiB, concurrency parts at once.
+func newUploader(client s3API, partSize int64, concurrency int) *manager.Uploader {
+	return manager.NewUploader(client, func(u *manager.Uploader) {
+		u.PartSize = partSize * 1024 * 1024
+		u.Concurrency = concurrency
+	})
+}
+
+func (d *data) putObject(folder, unsignedKey string, reader io.Reader) (string, error) {
+	if err := d.refuseInReadOnly(folder, "uploading unsigned deployment package"); err != nil {
+		return "", err
+	}
+	d.logf(folder, "upload", "Uploading unsigned deployment package to S3.")
+	output, err := d.uploader.Upload(d.ctx, &s3.PutObjectInput{
+		Bucket:  aws.String(d.unsignedBucket),
+		Key:     aws.String(unsignedKey),
+		Body:    reader,
+		Tagging: objectTagging(d.tags),
+		// lets builder clean-unsigned tell whether the run that uploaded it is still going
+		Metadata: map[string]string{
+			"run-id": d.runID,
+		},
+	})
+	if err != nil {
+		d.failf(folder, "upload", err, "Failed to upload unsigned deployment package: %s", err.Error())
+		return "", err
+	}
+	if output.VersionID == nil {
+		err := fmt.Errorf("bucket %s did not return a version ID, is versioning enabled", d.unsignedBucket)
+		d.failf(folder, "upload", err, "Failed to upload unsigned deployment package: %s", err.Error())
+		return "", err
+	}
+	d.logf(
+		folder,
+		"upload",
+		"Pushed unsigned deployment package to S3 with version ID: %s.",
+		*output.VersionID,
+	)
+	return *output.VersionID, nil
+}
+
+// Uploads the unsigned deployment package straight to the signed key, for folders without a signing profile.
+// Returns the version ID of the signed object, empty if the signed bucket is not versioned.
+func (d *data) putUnsignedAsSigned(folder, signedKey string, reader io.Reader, metadata map[string]string) (string, error) {
+	if err := d.refuseInReadOnly(folder, "uploading unsigned deployment package"); err != nil {
+		return "", err
+	}
+	d.logf(folder, "upload", "Uploading unsigned deployment package to signed/.")
+	output, err := d.uploader.Upload(d.ctx, &s3.PutObjectInput{
+		Bucket:   aws.String(d.signedBucket),
+		Key:      aws.String(signedKey),
+		Body:     reader,
+		Metadata: metadata,
+		Tagging:  objectTagging(d.tags),
+	})
+	if err != nil {
+		d.failf(folder, "upload", err, "Failed to upload unsigned deployment package: %s", err.Error())
+		return "", err
+	}
+	d.donef(folder, "upload", "Uploaded unsigned deployment package to signed/.")
+	return aws.ToString(output.VersionID), nil
+}
+
+func (d *data) startSigningJob(folder, unsignedKey, version string) (string, error) {
+	if err := d.refuseInReadOnly(folder, "starting signing job"); err != nil {
+		return "", err
+	}
+	d.logf(folder, "sign", "Starting signing job.")
+	output, err := d.signer.StartSigningJob(d.ctx, &signer.StartSigningJobInput{
+		ClientRequestToken: nil,
+		ProfileName:        aws.String(d.signingProfileFor(folder)),
+		Source: &signerTypes.Source{
+			S3: &signerTypes.S3Source{
+				BucketName: aws.String(d.unsignedBucket),
+				Key:        aws.String(unsignedKey),
+				Version:    aws.String(version),
+			},
+		},
+		Destination: &signerTypes.Destination{
+			S3: &signerTypes.S3Destination{
+				BucketName: aws.String(d.stagingBucket),
+				Prefix:     aws.String(prefixDir(d.stagingPrefix)),
+			},
+		},
+	})
+	if err != nil {
+		d.failf(folder, "sign", err, "Failed to start signing job: %s", err.Error())
+		return "", err
+	}
+	d.logf(folder, "sign", "Started signing job with id: %s.", *output.JobId)
+	return *output.JobId, nil
+}
+
+func (d *data) waitForSigningJob(folder string, jobId string) error {
+	d.logf(folder, "sign", "Waiting for signing job to complete.")
+	err := d.signingJobWaiter.Wait(d.ctx, &signer.DescribeSigningJobInput{
+		JobId: aws.String(jobId),
+	}, d.signingTimeout)
+	if err != nil {
+		d.failf(folder, "sign", err, "Failed to wait for signing job to complete: %s", err.Error())
+		return err
+	}
+	d.donef(folder, "sign", "Signing job is complete.")
+	return nil
+}
+
+func (d *data) deleteObject(folder, bucket, key string) {
+	if d.readOnly {
+		d.logf(folder, "cleanup", "Refusing to delete object in read-only mode: %s.", key)
+		return
+	}
+	d.logf(folder, "cleanup", "Deleting object: s3://%s/%s.", bucket, key)
+	_, err := d.s3.DeleteObject(d.ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		d.failf(folder, "cleanup", err, "Failed to delete object (%s): %s", key, err.Error())
+		return
+	}
+	d.donef(folder, "cleanup", "Deleted object: %s.", key)
+}
+
+func (d *data) getObject(folder string, key string) (io.ReadCloser, error) {
+	d.logf(folder, "download", "Downloading signed deployment package.")
+	output, err := d.s3.GetObject(d.ctx, &s3.GetObjectInput{
+		Bucket: aws.String(d.stagingBucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		d.failf(
+			folder,
+			"download",
+			err,
+			"Failed to download signed deployment package: %s",
+			err.Error(),
+		)
+		return nil, err
+	}
+	d.logf(folder, "download", "Downloaded signed deployment package.")
+	return output.Body, nil
+}
+
+func (d *data) hashObject(folder string, r io.Reader) (string, error) {
+	d.logf(folder, "hash", "Hashing signed deployment package.")
+	h := sha256.New()
+	_, err := io.Copy(h, r)
+	if err != nil {
+		d.failf(folder, "hash", err, "Failed to hash signed deployment package: %s.", err.Error())
+		return "", err
+	}
+	hash := string(base64.StdEncoding.EncodeToString(h.Sum(nil)))
+	d.donef(folder, "hash", "Hashed signed deployment package: %s.", hash)
+	return hash, nil
+}
+
+// How many times and how often to read the signed deployment package back after writing it.
+const (
+	signedMetadataAttempts = 5
+	signedMetadataDelay    = time.Second
+)
+
+// Reads the signed deployment package back until its metadata has the new unsigned hash,
+// so the up-to-date check of the next run does not see stale metadata and redeploy.
+// Only logs if the metadata never shows up, since the deploy itself succeeded.
+func (d *data) waitForSignedMetadata(folder, signedKey, unsignedHash string) {
+	d.logf(folder, "copy", "Checking metadata of signed deployment package.")
+	for attempt := 1; attempt <= signedMetadataAttempts; attempt++ {
+		output, err := d.s3.HeadObject(d.ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(d.signedBucket),
+			Key:    aws.String(signedKey),
+		})
+		if err == nil && output.Metadata["unsignedhash"] == unsignedHash {
+			d.donef(folder, "copy", "Metadata of signed deployment package is up to date.")
+			return
+		}
+		if attempt < signedMetadataAttempts {
+			time.Sleep(signedMetadataDelay)
+		}
+	}
+	d.skipf(folder, "copy", "Metadata of signed deployment package is still stale, the next run may redeploy.")
+}
+
+// Returns the version ID of the signed object, empty if the signed bucket is not versioned.
+func (d *data) copyObject(folder, stagingKey, signedKey string, metadata map[string]string) (string, error) {
+	if err := d.refuseInReadOnly(folder, "copying signed deployment package"); err != nil {
+		return "", err
+	}
+	d.logf(folder, "copy", "Copying signed deployment package to signed/.")
+	input := &s3.CopyObjectInput{
+		CopySource:        aws.String(d.stagingBucket + "/" + stagingKey),
+		Bucket:            aws.String(d.signedBucket),
+		Key:               aws.String(signedKey),
+		Metadata:          metadata,
+		MetadataDirective: s3Types.MetadataDirective("REPLACE"),
+	}
+	if len(d.tags) != 0 {
+		input.Tagging = objectTagging(d.tags)
+		input.TaggingDirective = s3Types.TaggingDirectiveReplace
+	}
+	output, err := d.s3.CopyObject(d.ctx, input)
+	if err != nil {
+		d.failf(folder, "copy", err, "Failed to copy signed deployment package: %s", err.Error())
+		return "", err
+	}
+	d.donef(folder, "copy", "Copied signed deployment package to signed/.")
+	return aws.ToString(output.VersionId), nil
+}
+
+// Tags the function with tags, e.g. d.tags.
+func (d *data) updateFunctionCode(folder, signedKey, arch string, tags map[string]string) error {
+	if err := d.refuseInReadOnly(folder, "updating Lambda function code"); err != nil {
+		return err
+	}
+	d.logf(folder, "update", "Updating Lambda function code.")
+	output, err := d.lambda.UpdateFunctionCode(d.ctx, &lambda.UpdateFunctionCodeInput{
+		FunctionName:  aws.String(d.functionName(folder)),
+		S3Bucket:      aws.String(d.signedBucket),
+		S3Key:         aws.String(signedKey),
+		Architectures: []lambdaTypes.Architecture{architectures[arch]},
+	})
+	if isFunctionNotFound(err) && d.createMissing {
+		d.skipf(folder, "update", "Lambda function does not exist.")
+		return d.createFunction(folder, signedKey, arch, tags)
+	}
+	if err != nil {
+		d.failf(folder, "update", err, "Failed to update Lambda function code: %s", err.Error())
+		return err
+	}
+	d.donef(folder, "update", "Updated Lambda function code.")
+	return d.tagFunction(folder, aws.ToString(output.FunctionArn), tags)
+}
+
+func (d *data) waitForFunctionUpdate(folder string) error {
+	d.logf(folder, "update", "Waiting for function code to update.")
+	err := d.functionUpdatedWaiter.Wait(d.ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(d.functionName(folder)),
+	}, d.updateTimeout)
+	if err != nil {
+		d.failf(folder, "update", err, "Failed to wait for function code to update: %s", err.Error())
+		return err
+	}
+	d.donef(folder, "update", "Function code is updated.")
+	return nil
+}
+
+func (d *data) publishLambdaVersion(folder, hash string) (string, error) {
+	if err := d.refuseInReadOnly(folder, "publishing Lambda function version"); err != nil {
+		return "", err
+	}
+	d.logf(folder, "publish", "Publishing new version of Lambda function.")
+	description, err := d.versionDescription(folder, hash, time.Now())
+	if err != nil {
+		d.failf(folder, "publish", err, "Failed to render version description: %s.", err.Error())
+		return "", err
+	}
+	output, err := d.lambda.PublishVersion(d.ctx, &lambda.PublishVersionInput{
+		FunctionName: aws.String(d.functionName(folder)),
+		CodeSha256:   aws.String(hash),
+		Description:  aws.String(description),
+	})
+	if err != nil {
+		d.failf(folder, "publish", err, "Failed to publish function version: %s", err.Error())
+		return "", err
+	}
+	if published := aws.ToString(output.CodeSha256); published != hash {
+		err := fmt.Errorf("published version %s runs %s, expected %s", aws.ToString(output.Version), published, hash)
+		d.failf(folder, "publish", err, "Published version does not run the signed deployment package: %s.", err.Error())
+		return "", err
+	}
+	if output.State != lambdaTypes.StateActive {
+		err = d.waitForPublishedVersion(folder, aws.ToString(output.Version), snapStartEnabled(output.SnapStart))
+		if err != nil {
+			return "", err
+		}
+	}
+	d.logf(
+		folder,
+		"publish",
+		"Published new version of Lambda function: %s.",
+		*output.Version,
+	)
+	return *output.Version, nil
+}
+
+func (d *data) updateFunctionAlias(folder, version string) error {
+	if err := d.refuseInReadOnly(folder, "updating Lambda function alias"); err != nil {
+		return err
+	}
+	alias := d.aliasFor(folder)
+	var notFound *lambdaTypes.ResourceNotFoundException
+	previous := ""
+	previousVersion := ""
+	output, err := d.lambda.GetAlias(d.ctx, &lambda.GetAliasInput{
+		FunctionName: aws.String(d.functionName(folder)),
+		Name:         aws.String(alias),
+	})
+	if err != nil && !errors.As(err, &notFound) {
+		d.failf(folder, "alias", err, "Failed to get alias of Lambda function: %s", err.Error())
+		return err
+	}
+	if err == nil {
+		previous = aws.ToString(output.Description)
+		previousVersion = aws.ToString(output.FunctionVersion)
+		d.report.update(folder, d.targetRegion, func(r *folderReport) { r.PreviousVersion = previousVersion })
+	}
+	description := d.aliasDescription(previous, version, time.Now())
+	// a new alias has no traffic to shift
+	if d.canaryPercent > 0 && previousVersion != "" && previousVersion != version {
+		return d.canaryFunctionAlias(folder, alias, previousVersion, version, description)
+	}
+	d.logf(folder, "alias", "Updating alias %s of Lambda function: %s.", alias, aliasChange(alias, previousVersion, version))
+	_, err = d.lambda.UpdateAlias(d.ctx, &lambda.UpdateAliasInput{
+		FunctionName:    aws.String(d.functionName(folder)),
+		Name:            aws.String(alias),
+		FunctionVersion: aws.String(version),
+		Description:     aws.String(description),
+	})
+	if errors.As(err, &notFound) && d.createAlias {
+		d.logf(folder, "alias", "Alias %s does not exist.", alias)
+		return d.createFunctionAlias(folder, alias, version, description)
+	}
+	if err != nil {
+		d.failf(folder, "alias", err, "Failed to update alias of Lambda function: %s", err.Error())
+		return err
+	}
+	d.donef(folder, "alias", "Updated alias %s of Lambda function: %s.", alias, description)
+	return nil
+}
+
+// Returns the move of the alias from the version it pointed at to the new one, for the log and the summary.
+//
+//	LIVE: 41 → 42
+func aliasChange(alias, previous, version string) string {
+	if previous == "" || previous == version {
+		return fmt.Sprintf("%s: %s", alias, version)
+	}
+	return fmt.Sprintf("%s: %s → %s", alias, previous, version)
+}
+
+func (d *data) createFunctionAlias(folder, alias, version, description string) error {
+	if err := d.refuseInReadOnly(folder, "creating Lambda function alias"); err != nil {
+		return err
+	}
+	d.logf(folder, "alias", "Creating alias %s of Lambda function.", alias)
+	_, err := d.lambda.CreateAlias(d.ctx, &lambda.CreateAliasInput{
+		FunctionName:    aws.String(d.functionName(folder)),
+		Name:            aws.String(alias),
+		FunctionVersion: aws.String(version),
+		Description:     aws.String(description),
+	})
+	if err != nil {
+		d.failf(folder, "alias", err, "Failed to create alias of Lambda function: %s", err.Error())
+		return err
+	}
+	d.donef(folder, "alias", "Created alias %s of Lambda function.", alias)
+	return nil
+}
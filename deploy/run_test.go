@@ -0,0 +1,225 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"builder/testutil"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+var (
+	_ s3API     = (*testutil.FakeS3)(nil)
+	_ signerAPI = (*testutil.FakeSigner)(nil)
+	_ lambdaAPI = (*testutil.FakeLambda)(nil)
+)
+
+// Runs the test in the folders of test/lambdas, as the builder is run in CI.
+func chdirLambdas(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir("../test/lambdas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// Returns a run that deploys testLambda01 to the fakes, signed and to the LIVE alias, creating the function if it does not exist.
+func newFakeRun(t *testing.T, fakeS3 *testutil.FakeS3, fakeSigner *testutil.FakeSigner, fakeLambda *testutil.FakeLambda) *data {
+	d, err := newFolderRun(context.Background(), Options{
+		Folder:         "testLambda01",
+		Bucket:         "bucket",
+		UnsignedPrefix: "test/unsigned",
+		StagingPrefix:  "test/staging",
+		SignedPrefix:   "test/signed",
+		SigningProfile: "main",
+		Alias:          "LIVE",
+		CreateAlias:    true,
+	}, "us-east-1", fakeS3, fakeSigner, fakeLambda)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.createMissing = true
+	// creating the function needs its role, from the folder block of the config file
+	d.configs["testLambda01"] = folderConfig{Name: "testLambda01", Role: "arn:aws:iam::123456789012:role/testLambda01"}
+	return d
+}
+
+func TestRunDeploysThenIsUpToDate(t *testing.T) {
+	chdirLambdas(t)
+	fakeS3 := testutil.NewFakeS3("us-east-1")
+	fakeSigner := testutil.NewFakeSigner(fakeS3)
+	fakeLambda := testutil.NewFakeLambda(fakeS3, "us-east-1")
+
+	d := newFakeRun(t, fakeS3, fakeSigner, fakeLambda)
+	err := d.run("testLambda01")
+	if err != nil {
+		t.Fatalf("first run: %s", err)
+	}
+	if result := d.result("testLambda01"); result.Status != reportDeployed || result.Version != "1" {
+		t.Fatalf("first run: got status %q and version %q, want deployed and 1", result.Status, result.Version)
+	}
+	if versions := fakeLambda.Versions("testLambda01"); len(versions) != 1 {
+		t.Fatalf("first run: published versions %v, want 1", versions)
+	}
+	if version, ok := fakeLambda.AliasVersion("testLambda01", "LIVE"); !ok || version != "1" {
+		t.Fatalf("first run: alias LIVE points at %q, want 1", version)
+	}
+	if _, ok := fakeS3.Body("bucket", "test/signed/testLambda01.zip"); !ok {
+		t.Fatal("first run: no signed deployment package")
+	}
+
+	d = newFakeRun(t, fakeS3, fakeSigner, fakeLambda)
+	err = d.run("testLambda01")
+	if err != nil {
+		t.Fatalf("second run: %s", err)
+	}
+	if result := d.result("testLambda01"); result.Status != reportUpToDate {
+		t.Fatalf("second run: got status %q, want up to date", result.Status)
+	}
+	if jobs := fakeSigner.JobCount(); jobs != 1 {
+		t.Fatalf("second run: (%d) signing jobs, want 1", jobs)
+	}
+	if versions := fakeLambda.Versions("testLambda01"); len(versions) != 1 {
+		t.Fatalf("second run: published versions %v, want 1", versions)
+	}
+}
+
+// Fails HeadObject with err.
+type headObjectFailingS3 struct {
+	*testutil.FakeS3
+	err error
+}
+
+func (f headObjectFailingS3) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	return nil, f.err
+}
+
+func TestIsUpToDateHeadObjectErrors(t *testing.T) {
+	chdirLambdas(t)
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{name: "not found", err: &s3Types.NotFound{}, wantErr: false},
+		{name: "access denied", err: &smithy.GenericAPIError{Code: "AccessDenied", Message: "Access Denied"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeS3 := testutil.NewFakeS3("us-east-1")
+			d := newFakeRun(t, fakeS3, testutil.NewFakeSigner(fakeS3), testutil.NewFakeLambda(fakeS3, "us-east-1"))
+			d.s3 = headObjectFailingS3{FakeS3: fakeS3, err: tt.err}
+			upToDate, err := d.isUpToDate("testLambda01", "test/signed/testLambda01.zip", "hash", "amd64")
+			if upToDate {
+				t.Error("up to date without a previous deployment package")
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Uploads to a bucket without versioning, which returns no version ID.
+type unversionedS3 struct {
+	*testutil.FakeS3
+}
+
+func (f unversionedS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	output, err := f.FakeS3.PutObject(ctx, params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	output.VersionId = nil
+	return output, nil
+}
+
+func TestPutObjectWithoutVersionID(t *testing.T) {
+	chdirLambdas(t)
+	fakeS3 := testutil.NewFakeS3("us-east-1")
+	d := newFakeRun(t, fakeS3, testutil.NewFakeSigner(fakeS3), testutil.NewFakeLambda(fakeS3, "us-east-1"))
+	d.s3 = unversionedS3{fakeS3}
+	d.uploader = newUploader(d.s3, defaultUploadPartSize, 1)
+	versionID, err := d.putObject("testLambda01", "test/unsigned/testLambda01.zip", bytes.NewReader([]byte("package")))
+	if err == nil {
+		t.Fatalf("uploaded with version ID %q, want an error", versionID)
+	}
+	if !strings.Contains(err.Error(), "version ID") {
+		t.Errorf("error %q, want it to mention the missing version ID", err)
+	}
+}
+
+// Publishes versions that run other code than was asked for.
+type mismatchedPublishLambda struct {
+	*testutil.FakeLambda
+}
+
+func (f mismatchedPublishLambda) PublishVersion(ctx context.Context, params *lambda.PublishVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishVersionOutput, error) {
+	return &lambda.PublishVersionOutput{
+		Version:    aws.String("1"),
+		CodeSha256: aws.String("other"),
+		State:      lambdaTypes.StateActive,
+	}, nil
+}
+
+func TestPublishLambdaVersionCodeSha256Mismatch(t *testing.T) {
+	chdirLambdas(t)
+	fakeS3 := testutil.NewFakeS3("us-east-1")
+	fakeLambda := testutil.NewFakeLambda(fakeS3, "us-east-1")
+	d := newFakeRun(t, fakeS3, testutil.NewFakeSigner(fakeS3), fakeLambda)
+	d.lambda = mismatchedPublishLambda{fakeLambda}
+	version, err := d.publishLambdaVersion("testLambda01", "hash")
+	if err == nil {
+		t.Fatalf("published version %q, want an error", version)
+	}
+	if version != "" {
+		t.Errorf("version %q, want none", version)
+	}
+}
+
+func TestRunMissingAlias(t *testing.T) {
+	chdirLambdas(t)
+	tests := []struct {
+		name        string
+		createAlias bool
+		wantErr     bool
+	}{
+		{name: "create alias", createAlias: true, wantErr: false},
+		{name: "without create alias", createAlias: false, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeS3 := testutil.NewFakeS3("us-east-1")
+			fakeLambda := testutil.NewFakeLambda(fakeS3, "us-east-1")
+			d := newFakeRun(t, fakeS3, testutil.NewFakeSigner(fakeS3), fakeLambda)
+			d.createAlias = tt.createAlias
+			err := d.run("testLambda01")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %t", err, tt.wantErr)
+			}
+			version, ok := fakeLambda.AliasVersion("testLambda01", "LIVE")
+			if tt.wantErr {
+				if ok {
+					t.Errorf("alias LIVE created at %q without -create-alias", version)
+				}
+				return
+			}
+			if !ok || version != "1" {
+				t.Errorf("alias LIVE points at %q, want 1", version)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// The folder builder run-one deploys, which skips looking for folders and sharding.
+var singleFolder string
+
+// Deploys one folder, for a developer iterating on one Lambda function, and prints the version it published.
+// Prints its progress without folder prefixes to stderr, so only the version goes to stdout.
+//
+//	builder run-one testLambda01 -alias=TEST
+//	version=$(builder run-one testLambda01)
+//
+// Prints a line of region and version per region with -regions, and nothing if the function was up to date.
+func runOne() error {
+	folder := commandArg
+	if folder == "" && commandLine.NArg() == 1 {
+		folder = commandLine.Arg(0)
+	} else if folder == "" || commandLine.NArg() != 0 {
+		return errors.New("usage: builder run-one FOLDER [flags]")
+	}
+	if !isLambdaFolder(folder) {
+		return fmt.Errorf(`"%s" is not a Lambda folder: it has no Go files, artifact, or matrix`, folder)
+	}
+	singleFolder = folder
+	logFolderPrefix = false
+	d, err := deploy()
+	if err != nil {
+		return err
+	}
+	d.report.mu.Lock()
+	defer d.report.mu.Unlock()
+	for _, key := range d.report.keys() {
+		fr := d.report.folders[key]
+		if fr.Version == "" {
+			continue
+		}
+		if fr.Region != "" {
+			fmt.Fprintf(os.Stdout, "%s\t%s\n", fr.Region, fr.Version)
+		} else {
+			fmt.Fprintln(os.Stdout, fr.Version)
+		}
+	}
+	return nil
+}
+
+// Returns true if the folder can be deployed without looking through every folder, see lambdaFolders.
+func isLambdaFolder(folder string) bool {
+	if _, ok := matrixBuilds[folder]; ok {
+		return true
+	}
+	if folderConfigs[folder].Artifact != "" {
+		return true
+	}
+	return hasGoFiles(folder)
+}
+
+// Returns true if the folder has Go files to build.
+func hasGoFiles(folder string) bool {
+	matches, err := filepath.Glob(filepath.Join(folder, "*.go"))
+	return err == nil && len(matches) != 0
+}
@@ -0,0 +1,108 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/signer"
+)
+
+// The -signer-fallback that deploys unsigned packages to regions without AWS Signer.
+const signerFallbackSkip = "skip"
+
+// Returns whether AWS Signer is offered in the region.
+// Signer has no endpoint in regions it is not offered in, so any response, even access denied, means it is offered.
+func signerAvailable(ctx context.Context, client signerAPI, region string) bool {
+	if !partitions[regionPartition(region)].signer {
+		return false
+	}
+	_, err := client.ListSigningPlatforms(ctx, &signer.ListSigningPlatformsInput{MaxResults: aws.Int32(1)})
+	var dnsErr *net.DNSError
+	return !errors.As(err, &dnsErr)
+}
+
+// Makes every region of the run without AWS Signer sign in the region of -signer-fallback and copy the signed package back,
+// or deploy unsigned packages with -signer-fallback=skip.
+// Returns an error naming the regions without Signer if there is no fallback.
+//
+//	-regions=us-east-1,ap-southeast-5 -signer-fallback=ap-southeast-1 -bucket=kesav-go-lambda-builder-{region}
+func (d *data) applySignerFallback(ctx context.Context, cfg aws.Config) error {
+	if !signingEnabled() {
+		return nil
+	}
+	fallback := *signerFallbackFlag
+	for _, t := range d.targets() {
+		if signerAvailable(ctx, t.signer, t.region) {
+			continue
+		}
+		switch {
+		case fallback == "":
+			return fmt.Errorf(
+				"AWS Signer is not available in %s: pass -signer-fallback=REGION to sign in another region, or -signer-fallback=%s to deploy unsigned packages there",
+				t.region, signerFallbackSkip,
+			)
+		case fallback == signerFallbackSkip:
+			printf("AWS Signer is not available in %s, deploying unsigned packages there.\n\n", t.region)
+			t.signingProfile = ""
+			t.signingProfileOverrides = map[string]string{}
+		default:
+			if regionPartition(fallback) != regionPartition(t.region) {
+				return fmt.Errorf("cannot sign for %s in %s: the regions are in different partitions", t.region, fallback)
+			}
+			for _, bucket := range []string{orDefault(*unsignedBucketFlag, *bucketFlag), orDefault(*stagingBucketFlag, *bucketFlag)} {
+				if !strings.Contains(bucket, regionPlaceholder) {
+					return fmt.Errorf(`signing in %s needs unsigned and staging bucket names that contain %s, got "%s"`, fallback, regionPlaceholder, bucket)
+				}
+			}
+			regional := cfg.Copy()
+			regional.Region = fallback
+			signing := t.inRegion(regional)
+			signing.unsignedBucket = regionalBucket(orDefault(*unsignedBucketFlag, *bucketFlag), fallback)
+			signing.stagingBucket = regionalBucket(orDefault(*stagingBucketFlag, *bucketFlag), fallback)
+			t.signingVia = signing
+			printf("AWS Signer is not available in %s, signing in %s instead.\n\n", t.region, fallback)
+		}
+	}
+	return nil
+}
+
+// Returns the copy of the run that signs the packages of this one, itself unless its region has no AWS Signer.
+func (d *data) signingRun() *data {
+	if d.signingVia != nil {
+		return d.signingVia
+	}
+	return d
+}
+
+// Returns true if some folders are signed in the region of the run itself.
+func (d *data) signsInRegion() bool {
+	return d.signingVia == nil && (d.signingProfile != "" || len(d.signingProfileOverrides) != 0)
+}
+
+// Uploads the package signed in the -signer-fallback region to the signed key in the region of the run.
+// Returns the version ID of the signed object, empty if the signed bucket is not versioned.
+func (d *data) replicateSigned(folder, signedKey string, reader io.Reader, metadata map[string]string) (string, error) {
+	if err := d.refuseInReadOnly(folder, "copying signed deployment package"); err != nil {
+		return "", err
+	}
+	d.logf(folder, "copy", "Copying signed deployment package from %s to signed/.", d.signingVia.region)
+	output, err := d.uploader.Upload(d.ctx, &s3.PutObjectInput{
+		Bucket:   aws.String(d.signedBucket),
+		Key:      aws.String(signedKey),
+		Body:     reader,
+		Metadata: metadata,
+		Tagging:  objectTagging(d.tags),
+	})
+	if err != nil {
+		d.failf(folder, "copy", err, "Failed to copy signed deployment package: %s", err.Error())
+		return "", err
+	}
+	d.donef(folder, "copy", "Copied signed deployment package from %s to signed/.", d.signingVia.region)
+	return aws.ToString(output.VersionID), nil
+}
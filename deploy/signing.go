@@ -0,0 +1,50 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Returns the signing profile to sign the folder's deployment package with.
+func (d *data) signingProfileFor(folder string) string {
+	if signingProfile, ok := d.signingProfileOverrides[folder]; ok {
+		return signingProfile
+	}
+	return d.signingProfile
+}
+
+// Returns true if any folder is signed, either by the signing profile flag or a folder block.
+func signingEnabled() bool {
+	if *signingProfileFlag != "" {
+		return true
+	}
+	for _, fc := range folderConfigs {
+		if fc.SigningProfile != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns an error if versioning is not enabled on the unsigned bucket.
+// Signer can only sign a specific version of an object, so signing needs a versioned bucket.
+// Folders without a signing profile never use the unsigned bucket, so they work with plain buckets.
+func checkUnsignedBucketVersioning(ctx context.Context, s3Client s3API, bucket string) error {
+	output, err := s3Client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		return err
+	}
+	if output.Status != s3Types.BucketVersioningStatusEnabled {
+		return fmt.Errorf(
+			"versioning is not enabled on bucket %s, which signing requires: enable it or do not pass a signing profile",
+			bucket,
+		)
+	}
+	return nil
+}
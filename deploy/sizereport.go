@@ -0,0 +1,100 @@
+package deploy
+
+import (
+	"debug/buildinfo"
+	"debug/elf"
+	"debug/gosym"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// How many packages the size report keeps.
+const sizeReportTop = 10
+
+// How much of an executable's code belongs to a package.
+type packageSize struct {
+	Package string `json:"package"`
+	// std for the standard library
+	Module string `json:"module"`
+	Bytes  uint64 `json:"bytes"`
+}
+
+// Returns the packages that contribute the most code to the executable, biggest first.
+// Reads the function table that Go keeps even in stripped executables, so it needs no symbols.
+func packageSizes(executablePath string) ([]packageSize, error) {
+	exe, err := elf.Open(executablePath)
+	if err != nil {
+		return nil, err
+	}
+	defer exe.Close()
+	pclntab := exe.Section(".gopclntab")
+	text := exe.Section(".text")
+	if pclntab == nil || text == nil {
+		return nil, errors.New("executable has no Go function table")
+	}
+	b, err := pclntab.Data()
+	if err != nil {
+		return nil, err
+	}
+	table, err := gosym.NewTable(nil, gosym.NewLineTable(b, text.Addr))
+	if err != nil {
+		return nil, err
+	}
+	info, err := buildinfo.ReadFile(executablePath)
+	if err != nil {
+		return nil, err
+	}
+	modules := []string{info.Main.Path}
+	for _, dep := range info.Deps {
+		modules = append(modules, dep.Path)
+	}
+	sizes := map[string]uint64{}
+	for _, fn := range table.Funcs {
+		pkg := fn.PackageName()
+		if pkg == "" {
+			pkg = "runtime"
+		}
+		sizes[pkg] += fn.End - fn.Entry
+	}
+	packages := []packageSize{}
+	for pkg, size := range sizes {
+		packages = append(packages, packageSize{Package: pkg, Module: moduleOf(pkg, modules), Bytes: size})
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Bytes > packages[j].Bytes })
+	if len(packages) > sizeReportTop {
+		packages = packages[:sizeReportTop]
+	}
+	return packages, nil
+}
+
+// Returns the module with the longest path the package is in, or std if it is in none.
+func moduleOf(pkg string, modules []string) string {
+	module := "std"
+	for _, m := range modules {
+		if (pkg == m || strings.HasPrefix(pkg, m+"/")) && (module == "std" || len(m) > len(module)) {
+			module = m
+		}
+	}
+	return module
+}
+
+// Logs the packages that contribute the most code to the executable and adds them to the report of each target.
+// A failed analysis does not fail the deploy.
+func (d *data) reportSize(folder, executablePath string, targets []*data) {
+	d.logf(folder, "size", "Analyzing size of executable.")
+	packages, err := packageSizes(executablePath)
+	if err != nil {
+		d.skipf(folder, "size", "Failed to analyze size of executable: %s.", err.Error())
+		return
+	}
+	lines := []string{}
+	for _, p := range packages {
+		lines = append(lines, fmt.Sprintf("%s %.2f M", p.Package, float64(p.Bytes)/1000000))
+	}
+	d.donef(folder, "size", "Biggest packages: %s.", strings.Join(lines, ", "))
+	for _, t := range targets {
+		t.report.update(folder, t.targetRegion, func(r *folderReport) { r.SizeReport = packages })
+	}
+}
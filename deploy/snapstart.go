@@ -0,0 +1,31 @@
+package deploy
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Returns whether publishing a version of the function takes a snapshot of it.
+func snapStartEnabled(snapStart *lambdaTypes.SnapStartResponse) bool {
+	return snapStart != nil && snapStart.ApplyOn == lambdaTypes.SnapStartApplyOnPublishedVersions
+}
+
+// Waits until the published version is active, so that the alias never points at a version that cannot be invoked yet.
+// With SnapStart, Lambda initializes the version and snapshots it first, which can take minutes.
+func (d *data) waitForPublishedVersion(folder, version string, snapStart bool) error {
+	if snapStart {
+		d.logf(folder, "publish", "Waiting up to %s for SnapStart to snapshot version %s.", d.publishTimeout, version)
+	} else {
+		d.logf(folder, "publish", "Waiting for version %s to become active.", version)
+	}
+	err := d.publishedVersionWaiter.Wait(d.ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(d.functionName(folder)),
+		Qualifier:    aws.String(version),
+	}, d.publishTimeout)
+	if err != nil {
+		d.failf(folder, "publish", err, "Failed to wait for version %s to become active: %s", version, err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package deploy
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Git metadata stamped into each executable with -stamp, e.g. with -stamp-package=main:
+//
+//	var gitCommit, gitBranch, gitTag, buildTime string
+//
+// The same values are added to the metadata of each signed deployment package and the tags of each function.
+type buildStamp struct {
+	commit string
+	branch string
+	// empty unless the commit is tagged
+	tag string
+	// the time of the commit, or SOURCE_DATE_EPOCH if set, so the same commit always builds the same executable
+	time string
+}
+
+// Returns the stamp of the commit being deployed.
+func newBuildStamp(commit string) buildStamp {
+	s := buildStamp{commit: commit}
+	// the checkout of a GitHub Actions workflow is detached
+	s.branch = orDefault(os.Getenv("GITHUB_HEAD_REF"), os.Getenv("GITHUB_REF_NAME"))
+	if s.branch == "" {
+		branch, err := git("rev-parse", "--abbrev-ref", "HEAD")
+		if err == nil && branch != "HEAD" {
+			s.branch = branch
+		}
+	}
+	// fails if the commit is not tagged
+	s.tag, _ = git("describe", "--tags", "--exact-match", commit)
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err == nil {
+			s.time = time.Unix(seconds, 0).UTC().Format(time.RFC3339)
+		}
+	}
+	if s.time == "" {
+		committed, err := git("log", "-1", "--format=%ct", commit)
+		if err == nil {
+			seconds, err := strconv.ParseInt(committed, 10, 64)
+			if err == nil {
+				s.time = time.Unix(seconds, 0).UTC().Format(time.RFC3339)
+			}
+		}
+	}
+	return s
+}
+
+// Returns the -X flags that set the variables of pkg, empty if not stamping.
+func (s buildStamp) ldflags(pkg string) []string {
+	flags := []string{}
+	for _, v := range []struct{ name, value string }{
+		{"gitCommit", s.commit},
+		{"gitBranch", s.branch},
+		{"gitTag", s.tag},
+		{"buildTime", s.time},
+	} {
+		if v.value != "" {
+			flags = append(flags, "-X", pkg+"."+v.name+"="+v.value)
+		}
+	}
+	return flags
+}
+
+// Returns the stamp as S3 metadata and Lambda tags, without the empty values.
+func (s buildStamp) tags() map[string]string {
+	tags := map[string]string{}
+	for key, value := range map[string]string{
+		"git-commit": s.commit,
+		"git-branch": s.branch,
+		"git-tag":    s.tag,
+		"build-time": s.time,
+	} {
+		if value != "" {
+			tags[key] = value
+		}
+	}
+	return tags
+}
@@ -0,0 +1,60 @@
+package deploy
+
+import (
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// Tags read from the tags block of the config file.
+// Applied to every function and S3 object the builder creates or updates.
+//
+//	tags {
+//	  cost-center = "1234"
+//	  owner       = "platform"
+//	}
+var defaultTags = map[string]string{}
+
+// Returns the tags in the URL-encoded form S3 expects, or nil if there are none.
+func objectTagging(tags map[string]string) *string {
+	if len(tags) == 0 {
+		return nil
+	}
+	values := url.Values{}
+	for key, value := range tags {
+		values.Set(key, value)
+	}
+	return aws.String(values.Encode())
+}
+
+// Returns a copy of tags with key set to value.
+func withTag(tags map[string]string, key, value string) map[string]string {
+	copied := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		copied[k] = v
+	}
+	copied[key] = value
+	return copied
+}
+
+// Adds tags to the function, keeping any other tags it has.
+func (d *data) tagFunction(folder, arn string, tags map[string]string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	if err := d.refuseInReadOnly(folder, "tagging Lambda function"); err != nil {
+		return err
+	}
+	d.logf(folder, "tag", "Tagging Lambda function with (%d) tags.", len(tags))
+	_, err := d.lambda.TagResource(d.ctx, &lambda.TagResourceInput{
+		Resource: aws.String(arn),
+		Tags:     tags,
+	})
+	if err != nil {
+		d.failf(folder, "tag", err, "Failed to tag Lambda function: %s", err.Error())
+		return err
+	}
+	d.donef(folder, "tag", "Tagged Lambda function.")
+	return nil
+}
@@ -0,0 +1,110 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// How long to wait for the telemetry endpoint before giving up.
+const telemetryTimeout = 5 * time.Second
+
+// What -telemetry-endpoint is sent at the end of a run.
+// Only counts and durations, never names of folders, functions, buckets, or accounts, or error messages.
+type telemetryReport struct {
+	Version     string  `json:"version"`
+	GoVersion   string  `json:"goVersion"`
+	OS          string  `json:"os"`
+	Arch        string  `json:"arch"`
+	Folders     int     `json:"folders"`
+	Regions     int     `json:"regions"`
+	Concurrency int     `json:"concurrency"`
+	ReadOnly    bool    `json:"readOnly"`
+	Duration    float64 `json:"duration"`
+	// succeeded, failed, or too many failures
+	Outcome string                   `json:"outcome"`
+	Steps   map[string]telemetryStep `json:"steps"`
+	// how many times each step failed, keyed by step
+	Failures map[string]int `json:"failures"`
+}
+
+// How long one step took across all folders, in seconds.
+type telemetryStep struct {
+	Count int     `json:"count"`
+	Sum   float64 `json:"sum"`
+	Max   float64 `json:"max"`
+}
+
+// Returns the anonymous report of the run, d is nil when the run failed before it started.
+func newTelemetryReport(d *data, m *metrics, err error) telemetryReport {
+	r := telemetryReport{
+		Version:   builderVersion(),
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Duration:  m.elapsed().Seconds(),
+		Outcome:   "succeeded",
+		Steps:     map[string]telemetryStep{},
+		Failures:  map[string]int{},
+	}
+	if d != nil {
+		r.Folders = len(d.folders)
+		r.Regions = len(d.targets())
+		r.Concurrency = *concurrencyFlag
+		r.ReadOnly = d.readOnly
+	}
+	if errors.Is(err, errTooManyFailures) {
+		r.Outcome = "too many failures"
+	} else if err != nil {
+		r.Outcome = "failed"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for step, h := range m.histograms {
+		r.Steps[step] = telemetryStep{Count: h.count, Sum: h.sum, Max: h.max}
+	}
+	for step, count := range m.failures {
+		r.Failures[step] = count
+	}
+	return r
+}
+
+// Posts the anonymous report of the run to -telemetry-endpoint, if it is set.
+// Telemetry is opt-in and never fails the run, it only prints why it could not be sent.
+func sendTelemetry(d *data, m *metrics, runErr error) {
+	if *telemetryEndpointFlag == "" {
+		return
+	}
+	err := postTelemetry(*telemetryEndpointFlag, newTelemetryReport(d, m, runErr))
+	if err != nil {
+		printf("Failed to send telemetry: %s.\n\n", err.Error())
+	}
+}
+
+func postTelemetry(endpoint string, r telemetryReport) error {
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("endpoint responded with %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package deploy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// The signed deployment package of a function, as Terraform's aws_lambda_function wants it.
+type tfvarsPackage struct {
+	S3Bucket        string `json:"s3_bucket"`
+	S3Key           string `json:"s3_key"`
+	S3ObjectVersion string `json:"s3_object_version,omitempty"`
+	SourceCodeHash  string `json:"source_code_hash"`
+}
+
+// Writes the signed deployment package of every folder that is deployed or up to date to a .tfvars.json file,
+// so Terraform points each function at what the builder deployed instead of drifting.
+//
+//	{
+//	  "lambda_packages": {
+//	    "testLambda01": {"s3_bucket": "...", "s3_key": "signed/testLambda01.zip", "s3_object_version": "...", "source_code_hash": "..."}
+//	  }
+//	}
+//
+// Multi-region runs key each package by region/folder. Folders that are not part of this run keep their entries.
+func (d *data) writeTfvars(path string) error {
+	vars := map[string]map[string]tfvarsPackage{}
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		err = json.Unmarshal(b, &vars)
+		if err != nil {
+			return fmt.Errorf("invalid tfvars file %s: %w", path, err)
+		}
+	}
+	if vars["lambda_packages"] == nil {
+		vars["lambda_packages"] = map[string]tfvarsPackage{}
+	}
+	d.report.mu.Lock()
+	defer d.report.mu.Unlock()
+	for _, key := range d.report.keys() {
+		fr := d.report.folders[key]
+		if fr.SignedKey == "" || fr.Status != reportDeployed && fr.Status != reportUpToDate {
+			continue
+		}
+		name := fr.Folder
+		if fr.Region != "" {
+			name = fr.Region + "/" + fr.Folder
+		}
+		vars["lambda_packages"][name] = tfvarsPackage{
+			S3Bucket:        regionalBucket(d.signedBucket, orDefault(fr.Region, d.region)),
+			S3Key:           fr.SignedKey,
+			S3ObjectVersion: fr.SignedVersionID,
+			SourceCodeHash:  fr.SignedHash,
+		}
+	}
+	b, err = json.MarshalIndent(vars, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
@@ -0,0 +1,109 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Returns the targets of each folder that is a tool, keyed by folder.
+// Tools are internal CLIs that are built for each target and uploaded to S3 instead of being deployed to Lambda.
+//
+//	folder "deploy-cli" {
+//	  targets = ["linux/amd64", "darwin/arm64", "windows/amd64"]
+//	}
+func toolTargets() (map[string][]string, error) {
+	tools := map[string][]string{}
+	for folder, fc := range folderConfigs {
+		if len(fc.Targets) == 0 {
+			continue
+		}
+		for _, target := range fc.Targets {
+			goos, arch, ok := strings.Cut(target, "/")
+			if !ok || goos == "" || arch == "" {
+				return nil, fmt.Errorf(`folder "%s": target "%s" is not GOOS/GOARCH, e.g. darwin/arm64`, folder, target)
+			}
+		}
+		tools[folder] = fc.Targets
+	}
+	return tools, nil
+}
+
+// Builds the tool for each target and uploads it to the signed bucket, e.g.
+//
+//	s3://kesav-go-lambda-builder-test/tools/deploy-cli/darwin-arm64.zip
+//
+// Tools are not signed, and each target is only built if its source code changed.
+func (d *data) runTool(folder string, targets []string) error {
+	unsignedHash, err := d.hashSourceCode(folder)
+	if err != nil {
+		return err
+	}
+	failures := []string{}
+	for _, target := range targets {
+		err := d.buildTool(folder, target, unsignedHash)
+		if err != nil {
+			failures = append(failures, target)
+		}
+	}
+	if len(failures) != 0 {
+		return fmt.Errorf("failed to build tool for: %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+func (d *data) buildTool(folder, target, unsignedHash string) error {
+	goos, arch, _ := strings.Cut(target, "/")
+	key := objectKey(d.toolsPrefix, folder, goos+"-"+arch+".zip")
+	if d.force {
+		d.skipf(folder, "run", "Not checking if previous %s build is up to date.", target)
+	} else {
+		isUpToDate, err := d.isUpToDate(folder, key, unsignedHash, target)
+		if err != nil {
+			return err
+		}
+		if isUpToDate {
+			return nil
+		}
+	}
+	executablePath := fmt.Sprintf("/tmp/%s-%s-%s", folder, goos, arch)
+	err := d.buildExecutable(folder, executablePath, goos, arch)
+	if err != nil {
+		return err
+	}
+	defer d.deleteFile(folder, executablePath)
+	name := folder
+	if goos == "windows" {
+		name += ".exe"
+	}
+	r, err := d.zipExecutable(folder, executablePath, name)
+	if err != nil {
+		return err
+	}
+	pkg, size, err := d.sizeExecutable(folder, r)
+	if err != nil {
+		return err
+	}
+	if d.readOnly {
+		d.donef(folder, "plan", "Plan: upload %s build (%.2f M) to s3://%s/%s.", target, float64(size)/1000000, d.signedBucket, key)
+		return nil
+	}
+	if d.noUpload {
+		d.skipf(folder, "run", "Not uploading %s build to S3.", target)
+		return nil
+	}
+	hash, err := d.hashObject(folder, bytes.NewReader(pkg.Bytes()))
+	if err != nil {
+		return err
+	}
+	metadata := map[string]string{
+		"unsignedHash": unsignedHash,
+		"signedHash":   hash,
+		"arch":         target,
+	}
+	if d.source != "" {
+		metadata[sourceMetadata] = d.source
+	}
+	_, err = d.putUnsignedAsSigned(folder, key, bytes.NewReader(pkg.Bytes()), metadata)
+	return err
+}
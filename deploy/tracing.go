@@ -0,0 +1,240 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long to wait for the OTLP endpoint before giving up.
+const otlpTimeout = 10 * time.Second
+
+// OpenTelemetry span kinds and status codes, as numbered by OTLP.
+const (
+	spanKindInternal = 1
+	spanStatusError  = 2
+)
+
+// Turns the events of the run into OpenTelemetry spans, exported with -otlp-endpoint at the end of the run:
+// one span for the run, one for each folder in each region, and one for each step of the folder.
+// Safe to use from multiple goroutines. Every run shares runTrace.
+type trace struct {
+	mu      sync.Mutex
+	traceID string
+	root    *span
+	// keyed by folder and region
+	folders map[string]*span
+	// the running step of each folder, keyed by folder, region, and step
+	steps map[string]*span
+	spans []*span
+}
+
+type span struct {
+	id         string
+	parent     string
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes map[string]string
+	err        string
+}
+
+var runTrace = newTrace()
+
+func newTrace() *trace {
+	t := &trace{
+		traceID: newTraceID(),
+		folders: map[string]*span{},
+		steps:   map[string]*span{},
+	}
+	t.root = &span{id: newSpanID(), name: "builder", start: time.Now(), attributes: map[string]string{}}
+	t.spans = append(t.spans, t.root)
+	return t
+}
+
+// Returns a trace ID that starts with the current Unix time in seconds, which X-Ray requires of trace IDs it accepts.
+func newTraceID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	binary.BigEndian.PutUint32(b, uint32(time.Now().Unix()))
+	return hex.EncodeToString(b)
+}
+
+func newSpanID() string {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Records the event in the span of its step, starting the spans of its folder and step if they have not started.
+// The step's span ends once the step is done, failed, or skipped, and the next event of the step starts a new one.
+func (t *trace) observe(e event) {
+	if t == nil || e.Folder == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	folderKey := e.Folder + "/" + e.Region
+	folder, ok := t.folders[folderKey]
+	if !ok {
+		name := e.Folder
+		if e.Region != "" {
+			name += " (" + e.Region + ")"
+		}
+		folder = &span{id: newSpanID(), parent: t.root.id, name: name, start: e.Time, attributes: map[string]string{
+			"builder.folder": e.Folder,
+			"faas.name":      functionName(e.Folder),
+		}}
+		if e.Region != "" {
+			folder.attributes["cloud.region"] = e.Region
+		}
+		t.folders[folderKey] = folder
+		t.spans = append(t.spans, folder)
+	}
+	folder.end = e.Time
+	stepKey := folderKey + "/" + e.Step
+	step, ok := t.steps[stepKey]
+	if !ok {
+		step = &span{id: newSpanID(), parent: folder.id, name: e.Step, start: e.Time, attributes: map[string]string{}}
+		t.steps[stepKey] = step
+		t.spans = append(t.spans, step)
+	}
+	step.end = e.Time
+	step.attributes["builder.status"] = e.Status
+	step.attributes["builder.message"] = e.Message
+	if e.Status == statusFailed {
+		step.err = e.Error
+		folder.err = e.Error
+	}
+	if e.Status != statusRunning {
+		delete(t.steps, stepKey)
+	}
+}
+
+// Exports the spans of the run to -otlp-endpoint, if it is set, over OTLP/HTTP with JSON encoding.
+// Send them to X-Ray through a collector, e.g. the AWS Distro for OpenTelemetry.
+// Tracing never fails the run, it only prints why the spans could not be exported.
+func (t *trace) export(d *data, runErr error) {
+	if *otlpEndpointFlag == "" {
+		return
+	}
+	t.mu.Lock()
+	t.root.end = time.Now()
+	if d != nil {
+		t.root.attributes["builder.run_id"] = d.runID
+		t.root.attributes["vcs.revision"] = d.gitSHA
+		if d.environment != "" {
+			t.root.attributes["deployment.environment"] = d.environment
+		}
+	}
+	if runErr != nil {
+		t.root.err = runErr.Error()
+	}
+	payload, err := json.Marshal(t.otlp())
+	t.mu.Unlock()
+	if err == nil {
+		err = postOTLP(*otlpEndpointFlag, payload)
+	}
+	if err != nil {
+		printf("Failed to export trace to %s: %s.\n\n", *otlpEndpointFlag, err.Error())
+		return
+	}
+	printf("Exported trace %s.\n\n", t.traceID)
+}
+
+// Returns the spans as an OTLP ExportTraceServiceRequest. Expects the lock to be held.
+// Spans of steps still running when the run ended, end with the run.
+func (t *trace) otlp() map[string]interface{} {
+	spans := []map[string]interface{}{}
+	for _, s := range t.spans {
+		end := s.end
+		if end.IsZero() || end.Before(s.start) {
+			end = t.root.end
+		}
+		o := map[string]interface{}{
+			"traceId":           t.traceID,
+			"spanId":            s.id,
+			"name":              s.name,
+			"kind":              spanKindInternal,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attributes),
+		}
+		if s.parent != "" {
+			o["parentSpanId"] = s.parent
+		}
+		if s.err != "" {
+			o["status"] = map[string]interface{}{"code": spanStatusError, "message": s.err}
+		}
+		spans = append(spans, o)
+	}
+	return map[string]interface{}{
+		"resourceSpans": []map[string]interface{}{{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]string{
+					"service.name":    "go-lambda-builder",
+					"service.version": builderVersion(),
+				}),
+			},
+			"scopeSpans": []map[string]interface{}{{
+				"scope": map[string]string{"name": "go-lambda-builder", "version": builderVersion()},
+				"spans": spans,
+			}},
+		}},
+	}
+}
+
+func otlpAttributes(attributes map[string]string) []map[string]interface{} {
+	list := []map[string]interface{}{}
+	for key, value := range attributes {
+		list = append(list, map[string]interface{}{"key": key, "value": map[string]string{"stringValue": value}})
+	}
+	return list
+}
+
+// Posts the spans to the collector's traces path, e.g. http://localhost:4318/v1/traces,
+// with the headers in OTEL_EXPORTER_OTLP_HEADERS, e.g. api-key=secret.
+func postOTLP(endpoint string, payload []byte) error {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), otlpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for _, header := range splitList(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")) {
+		key, value, ok := strings.Cut(header, "=")
+		if ok {
+			req.Header.Set(strings.TrimSpace(key), strings.TrimSpace(value))
+		}
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("collector responded with %s", res.Status)
+	}
+	return nil
+}
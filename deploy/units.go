@@ -0,0 +1,179 @@
+package deploy
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Release units group folders whose aliases move together or not at all, so a service made of
+// several functions is never left half updated.
+//
+//	folder "orders-api" {
+//	  unit = "orders"
+//	}
+//	folder "orders-worker" {
+//	  unit = "orders"
+//	}
+//
+// Every member publishes its version as usual, then waits. Once every folder of the run is done,
+// the aliases of a unit move only if none of its members failed.
+type releaseUnits struct {
+	mu sync.Mutex
+	// versions waiting for their unit, keyed by unit
+	pending map[string][]pendingAlias
+}
+
+// A published version whose alias has not moved yet.
+type pendingAlias struct {
+	// the copy of the run in the region the version was published in
+	d          *data
+	folder     string
+	version    string
+	signedHash string
+	// the version the alias pointed at before its unit moved it, empty if it did not exist
+	previous string
+}
+
+func newReleaseUnits() *releaseUnits {
+	return &releaseUnits{pending: map[string][]pendingAlias{}}
+}
+
+// Returns the release unit of the folder, or an empty string.
+// Previews and latest-only runs do not move aliases, so they have no units.
+func (d *data) unitOf(folder string) string {
+	if d.preview != "" || d.latestOnly {
+		return ""
+	}
+	return d.configs[folder].Unit
+}
+
+// Holds the alias of the folder until every member of its unit is done.
+func (d *data) holdAlias(unit, folder, version, signedHash string) {
+	d.units.mu.Lock()
+	defer d.units.mu.Unlock()
+	d.units.pending[unit] = append(d.units.pending[unit], pendingAlias{d: d, folder: folder, version: version, signedHash: signedHash})
+	d.skipf(folder, "alias", "Published version %s, waiting for the rest of unit %s to update alias %s.", version, unit, d.aliasFor(folder))
+}
+
+// Moves the aliases of every unit none of whose members failed.
+// If any alias fails to move, the aliases that already moved are moved back and every member of the unit fails.
+// Returns the members that failed to move, labelled like the failures of the run.
+func (d *data) releaseUnits(failures []string) []string {
+	d.units.mu.Lock()
+	defer d.units.mu.Unlock()
+	units := []string{}
+	for unit := range d.units.pending {
+		units = append(units, unit)
+	}
+	sort.Strings(units)
+	failed := []string{}
+	for _, unit := range units {
+		failedMembers := []string{}
+		for _, failure := range failures {
+			// failures of multi-region runs are labelled "folder (region)"
+			folder := strings.SplitN(failure, " ", 2)[0]
+			if d.configs[folder].Unit == unit {
+				failedMembers = append(failedMembers, failure)
+			}
+		}
+		if len(failedMembers) != 0 {
+			printf("Not updating aliases of unit %s, members failed: %s.\n\n", unit, strings.Join(failedMembers, ", "))
+			continue
+		}
+		printf("Updating aliases of unit %s.\n\n", unit)
+		members := d.units.pending[unit]
+		moved, err := moveUnitAliases(members)
+		if err != nil {
+			for i := len(moved) - 1; i >= 0; i-- {
+				moved[i].d.restoreAlias(moved[i])
+			}
+			for _, p := range members {
+				p.d.report.fail(p.folder, p.d.targetRegion)
+				failed = append(failed, p.d.folderInRegion(p.folder))
+			}
+			printf("\nFailed to update aliases of unit %s, moved (%d) aliases back: %s.\n\n", unit, len(moved), err.Error())
+			continue
+		}
+		for _, p := range members {
+			p.d.recordAliasMove(p.folder, p.version, p.signedHash)
+		}
+		printf("\n")
+	}
+	return failed
+}
+
+// Moves the alias of each member in turn, recording the version it pointed at before, until one fails.
+// Returns the members whose aliases were touched, the last of them the one that failed, if any.
+func moveUnitAliases(members []pendingAlias) ([]pendingAlias, error) {
+	moved := []pendingAlias{}
+	for _, p := range members {
+		previous, err := p.d.aliasVersion(p.folder)
+		if err != nil {
+			return moved, err
+		}
+		p.previous = previous
+		// the alias may have moved even if checking it or provisioning it fails
+		moved = append(moved, p)
+		err = p.d.switchAlias(p.folder, p.version)
+		if err != nil {
+			return moved, err
+		}
+	}
+	return moved, nil
+}
+
+// Returns the version the folder's alias points at, or an empty string if it does not exist.
+func (d *data) aliasVersion(folder string) (string, error) {
+	output, err := d.lambda.GetAlias(d.ctx, &lambda.GetAliasInput{
+		FunctionName: aws.String(d.functionName(folder)),
+		Name:         aws.String(d.aliasFor(folder)),
+	})
+	var notFound *lambdaTypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return "", nil
+	}
+	if err != nil {
+		d.failf(folder, "alias", err, "Failed to get alias of Lambda function: %s", err.Error())
+		return "", err
+	}
+	return aws.ToString(output.FunctionVersion), nil
+}
+
+// Moves the member's alias back to the version it pointed at before its unit moved it,
+// with its provisioned concurrency, or deletes the alias if the unit created it.
+// Only logs if it fails, since the unit has failed already.
+func (d *data) restoreAlias(p pendingAlias) {
+	alias := d.aliasFor(p.folder)
+	if p.previous == "" {
+		d.logf(p.folder, "alias", "Deleting alias %s, it did not exist before unit %s.", alias, d.configs[p.folder].Unit)
+		_, err := d.lambda.DeleteAlias(d.ctx, &lambda.DeleteAliasInput{
+			FunctionName: aws.String(d.functionName(p.folder)),
+			Name:         aws.String(alias),
+		})
+		// creating it failed
+		var notFound *lambdaTypes.ResourceNotFoundException
+		if err != nil && !errors.As(err, &notFound) {
+			d.failf(p.folder, "alias", err, "Failed to delete alias %s: %s", alias, err.Error())
+			return
+		}
+		d.donef(p.folder, "alias", "Deleted alias %s.", alias)
+		return
+	}
+	d.logf(p.folder, "alias", "Moving alias %s back: %s.", alias, aliasChange(alias, p.version, p.previous))
+	err := d.pointFunctionAlias(p.folder, alias, p.previous, nil)
+	if err != nil {
+		d.failf(p.folder, "alias", err, "Failed to move alias %s back to version %s: %s", alias, p.previous, err.Error())
+		return
+	}
+	err = d.provisionConcurrency(p.folder, p.previous, p.version)
+	if err != nil {
+		return
+	}
+	d.donef(p.folder, "alias", "Moved alias %s back to version %s.", alias, p.previous)
+}
@@ -0,0 +1,74 @@
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	"builder/testutil"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+func TestReleaseUnitsMovesAliasesBack(t *testing.T) {
+	chdirLambdas(t)
+	fakeS3 := testutil.NewFakeS3("us-east-1")
+	fakeSigner := testutil.NewFakeSigner(fakeS3)
+	fakeLambda := testutil.NewFakeLambda(fakeS3, "us-east-1")
+
+	d := newUnitRun(t, fakeS3, fakeSigner, fakeLambda)
+	for _, folder := range []string{"testLambda01", "testLambda02"} {
+		err := d.run(folder)
+		if err != nil {
+			t.Fatalf("%s: %s", folder, err)
+		}
+		if _, ok := fakeLambda.AliasVersion(folder, "LIVE"); ok {
+			t.Fatalf("%s: alias LIVE moved before the rest of its unit", folder)
+		}
+	}
+	if failed := d.releaseUnits(nil); len(failed) != 0 {
+		t.Fatalf("first release: failed %v", failed)
+	}
+	for _, folder := range []string{"testLambda01", "testLambda02"} {
+		if version, ok := fakeLambda.AliasVersion(folder, "LIVE"); !ok || version != "1" {
+			t.Fatalf("first release: %s alias LIVE points at %q, want 1", folder, version)
+		}
+		if result := d.result(folder); result.Status != reportDeployed {
+			t.Fatalf("first release: %s status %q, want deployed", folder, result.Status)
+		}
+	}
+
+	_, err := fakeLambda.UpdateFunctionCode(context.Background(), &lambda.UpdateFunctionCodeInput{
+		FunctionName: aws.String("testLambda01"),
+		ZipFile:      []byte("version 2"),
+		Publish:      true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = newUnitRun(t, fakeS3, fakeSigner, fakeLambda)
+	d.holdAlias("orders", "testLambda01", "2", "")
+	// never published, so its alias cannot move
+	d.holdAlias("orders", "testLambda02", "99", "")
+	failed := d.releaseUnits(nil)
+	if len(failed) != 2 {
+		t.Fatalf("second release: failed %v, want both members", failed)
+	}
+	for _, folder := range []string{"testLambda01", "testLambda02"} {
+		if version, ok := fakeLambda.AliasVersion(folder, "LIVE"); !ok || version != "1" {
+			t.Errorf("second release: %s alias LIVE points at %q, want 1", folder, version)
+		}
+		if result := d.result(folder); result.Status != reportFailed {
+			t.Errorf("second release: %s status %q, want failed", folder, result.Status)
+		}
+	}
+}
+
+// Returns a fake run of testLambda01 and testLambda02 as the release unit orders.
+func newUnitRun(t *testing.T, fakeS3 *testutil.FakeS3, fakeSigner *testutil.FakeSigner, fakeLambda *testutil.FakeLambda) *data {
+	d := newFakeRun(t, fakeS3, fakeSigner, fakeLambda)
+	for _, folder := range []string{"testLambda01", "testLambda02"} {
+		d.configs[folder] = folderConfig{Name: folder, Role: "arn:aws:iam::123456789012:role/" + folder, Unit: "orders"}
+	}
+	return d
+}
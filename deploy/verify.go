@@ -0,0 +1,228 @@
+package deploy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/signer"
+	signerTypes "github.com/aws/aws-sdk-go-v2/service/signer/types"
+)
+
+// Metadata of signed deployment packages that records the signing job that signed them, so builder verify can audit it later.
+const (
+	signingJobMetadata    = "signing-job-id"
+	signingRegionMetadata = "signing-region"
+)
+
+// Returns an error if the signing job was revoked, its signature expired, or its signing profile was revoked or canceled.
+// The revocation of individual certificates is checked by Lambda when it verifies the signature, since the SDK has no GetRevocationStatus.
+func (d *data) checkSigningJob(folder, jobID string) error {
+	d.logf(folder, "verify-signature", "Checking signing job %s.", jobID)
+	job, err := d.signer.DescribeSigningJob(d.ctx, &signer.DescribeSigningJobInput{
+		JobId: aws.String(jobID),
+	})
+	if err != nil {
+		d.failf(folder, "verify-signature", err, "Failed to describe signing job %s: %s", jobID, err.Error())
+		return err
+	}
+	err = signingJobProblem(job, time.Now())
+	if err == nil {
+		var profile *signer.GetSigningProfileOutput
+		profile, err = d.signer.GetSigningProfile(d.ctx, &signer.GetSigningProfileInput{
+			ProfileName: job.ProfileName,
+		})
+		if err != nil {
+			d.failf(folder, "verify-signature", err, "Failed to get signing profile %s: %s", aws.ToString(job.ProfileName), err.Error())
+			return err
+		}
+		err = signingProfileProblem(profile, job)
+	}
+	if err != nil {
+		d.failf(folder, "verify-signature", err, "Signing job %s is not valid: %s.", jobID, err.Error())
+		return err
+	}
+	d.donef(folder, "verify-signature", "Signing job %s signed with profile %s version %s, valid until %s.",
+		jobID, aws.ToString(job.ProfileName), aws.ToString(job.ProfileVersion), aws.ToTime(job.SignatureExpiresAt).Format(time.RFC3339))
+	return nil
+}
+
+// Returns why the signature of the job cannot be trusted at now, or nil if it can.
+func signingJobProblem(job *signer.DescribeSigningJobOutput, now time.Time) error {
+	if job.Status != signerTypes.SigningStatusSucceeded {
+		return fmt.Errorf("signing job is %s", job.Status)
+	}
+	if r := job.RevocationRecord; r != nil {
+		return fmt.Errorf("signature was revoked by %s at %s: %s",
+			aws.ToString(r.RevokedBy), aws.ToTime(r.RevokedAt).Format(time.RFC3339), aws.ToString(r.Reason))
+	}
+	if expires := job.SignatureExpiresAt; expires != nil && expires.Before(now) {
+		return fmt.Errorf("signature expired at %s", expires.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Returns why the signing profile no longer vouches for signatures of the job, or nil if it does.
+func signingProfileProblem(profile *signer.GetSigningProfileOutput, job *signer.DescribeSigningJobOutput) error {
+	switch profile.Status {
+	case signerTypes.SigningProfileStatusRevoked:
+		// signatures made before the revocation takes effect stay valid
+		r := profile.RevocationRecord
+		if r == nil || r.RevocationEffectiveFrom == nil || job.CompletedAt == nil || !job.CompletedAt.Before(*r.RevocationEffectiveFrom) {
+			return fmt.Errorf("signing profile %s was revoked", aws.ToString(profile.ProfileName))
+		}
+	case signerTypes.SigningProfileStatusCanceled:
+		return fmt.Errorf("signing profile %s was canceled", aws.ToString(profile.ProfileName))
+	}
+	return nil
+}
+
+// Downloads the signed deployment package and returns an error if its hash is not signedHash,
+// so a package that changed after it was copied is never deployed.
+func (d *data) checkSignedPackage(folder, signedKey, signedHash string) error {
+	d.logf(folder, "verify-signature", "Checking signed deployment package s3://%s/%s.", d.signedBucket, signedKey)
+	output, err := d.s3.GetObject(d.ctx, &s3.GetObjectInput{
+		Bucket: aws.String(d.signedBucket),
+		Key:    aws.String(signedKey),
+	})
+	if err != nil {
+		d.failf(folder, "verify-signature", err, "Failed to download signed deployment package: %s", err.Error())
+		return err
+	}
+	defer output.Body.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, output.Body)
+	if err != nil {
+		d.failf(folder, "verify-signature", err, "Failed to download signed deployment package: %s", err.Error())
+		return err
+	}
+	if hash := base64.StdEncoding.EncodeToString(h.Sum(nil)); hash != signedHash {
+		err := fmt.Errorf("signed deployment package hashes to %s, expected %s", hash, signedHash)
+		d.failf(folder, "verify-signature", err, "Signed deployment package changed: %s.", err.Error())
+		return err
+	}
+	d.donef(folder, "verify-signature", "Signed deployment package matches %s.", signedHash)
+	return nil
+}
+
+// Checks that the function of every selected folder runs the signed deployment package in the signed prefix,
+// that the package was not changed since it was signed, and that its signature is still valid.
+//
+//	builder verify -bucket=kesav-go-lambda-builder-test -signed-prefix=test/signed -environment=prod
+//
+// Checks the function the alias points at, or $LATEST if the function has no alias.
+func verify() error {
+	signedBucket := orDefault(*signedBucketFlag, *bucketFlag)
+	if signedBucket == "" {
+		return errors.New(`flag "signed-bucket" or "bucket" is required`)
+	}
+	if *signedPrefixFlag == "" {
+		return errors.New(`flag "signed-prefix" is required`)
+	}
+	folders, err := selectFolders()
+	if err != nil {
+		return err
+	}
+	aliasOverrides, err := parseOverrides(*aliasOverridesFlag)
+	if err != nil {
+		return err
+	}
+	err = mergeFolderConfigs(map[string]string{}, aliasOverrides, map[string]string{})
+	if err != nil {
+		return err
+	}
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return err
+	}
+	d := &data{
+		configs:        folderConfigs,
+		functionPrefix: *functionPrefixFlag,
+		functionSuffix: *functionSuffixFlag,
+		ctx:            context.TODO(),
+		metrics:        runMetrics,
+		region:         cfg.Region,
+		s3:             newS3Client(cfg),
+		signedBucket:   regionalBucket(signedBucket, cfg.Region),
+		signedPrefix:   *signedPrefixFlag,
+		signer:         signer.NewFromConfig(cfg),
+		lambda:         lambda.NewFromConfig(cfg),
+		alias:          *aliasFlag,
+		aliasOverrides: aliasOverrides,
+	}
+	printf("Verifying (%d) functions against s3://%s/%s.\n\n", len(folders), d.signedBucket, d.signedPrefix)
+	failures := []string{}
+	for _, folder := range folders {
+		err := d.verifyFunction(cfg, folder)
+		if err != nil {
+			failures = append(failures, folder)
+		}
+	}
+	if len(failures) != 0 {
+		return fmt.Errorf("failed to verify: %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+func (d *data) verifyFunction(cfg aws.Config, folder string) error {
+	qualifier := d.aliasFor(folder)
+	output, err := d.lambda.GetFunctionConfiguration(d.ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(d.functionName(folder)),
+		Qualifier:    aws.String(qualifier),
+	})
+	var notFound *lambdaTypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		qualifier = "$LATEST"
+		output, err = d.lambda.GetFunctionConfiguration(d.ctx, &lambda.GetFunctionConfigurationInput{
+			FunctionName: aws.String(d.functionName(folder)),
+		})
+	}
+	if err != nil {
+		d.failf(folder, "verify", err, "Failed to get Lambda function %s: %s", d.functionName(folder), err.Error())
+		return err
+	}
+	codeSha256 := aws.ToString(output.CodeSha256)
+	signedKey := objectKey(d.signedPrefix, folder+".zip")
+	head, err := d.s3.HeadObject(d.ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(d.signedBucket),
+		Key:    aws.String(signedKey),
+	})
+	if err != nil {
+		d.failf(folder, "verify", err, "Failed to get signed deployment package %s: %s", signedKey, err.Error())
+		return err
+	}
+	if signedHash := head.Metadata["signedhash"]; signedHash != codeSha256 {
+		err := fmt.Errorf("%s runs %s, but signed/ has %s", qualifier, codeSha256, signedHash)
+		d.failf(folder, "verify", err, "Lambda function does not run the signed deployment package: %s.", err.Error())
+		return err
+	}
+	d.donef(folder, "verify", "%s of Lambda function runs the signed deployment package %s.", qualifier, codeSha256)
+	err = d.checkSignedPackage(folder, signedKey, codeSha256)
+	if err != nil {
+		return err
+	}
+	jobID := head.Metadata[signingJobMetadata]
+	if jobID == "" {
+		d.skipf(folder, "verify-signature", "Signed deployment package does not record a signing job, not checking its signature.")
+		return nil
+	}
+	s := d
+	// packages of regions without AWS Signer were signed in the -signer-fallback region
+	if region := head.Metadata[signingRegionMetadata]; region != "" && region != d.region {
+		regional := cfg.Copy()
+		regional.Region = region
+		copied := *d
+		copied.signer = signer.NewFromConfig(regional)
+		s = &copied
+	}
+	return s.checkSigningJob(folder, jobID)
+}
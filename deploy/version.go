@@ -0,0 +1,176 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// The file that pins the builder versions a repo supports, if -required-version is not set.
+//
+//	>= 1.4.0, < 2
+const builderVersionFile = ".builderversion"
+
+// The version of builders built from a checkout, which is never checked against -required-version.
+const develVersion = "(devel)"
+
+// Returns the version of the builder, e.g. v1.4.0, or (devel) when built from a checkout.
+func builderVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" {
+		return develVersion
+	}
+	return info.Main.Version
+}
+
+// Prints the version of the builder.
+//
+//	builder version
+func version() error {
+	fmt.Println(builderVersion())
+	return nil
+}
+
+// Returns the required version range from -required-version, or from .builderversion in the working directory.
+func requiredVersion() (string, error) {
+	if *requiredVersionFlag != "" {
+		return *requiredVersionFlag, nil
+	}
+	b, err := os.ReadFile(builderVersionFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Returns an error if the builder does not satisfy the required version range,
+// so that CI runners with different releases installed do not deploy differently.
+// Only prints why with -required-version-warn.
+func checkRequiredVersion() error {
+	required, err := requiredVersion()
+	if err != nil || required == "" {
+		return err
+	}
+	current := builderVersion()
+	if current == develVersion {
+		printf("Not checking required version %s, builder was built from a checkout.\n\n", required)
+		return nil
+	}
+	ok, err := satisfiesVersion(current, required)
+	if err != nil {
+		return fmt.Errorf(`invalid required version "%s": %w`, required, err)
+	}
+	if ok {
+		return nil
+	}
+	err = fmt.Errorf("builder %s does not satisfy required version %s", current, required)
+	if *requiredVersionWarnFlag {
+		printf("Warning: %s.\n\n", err.Error())
+		return nil
+	}
+	return err
+}
+
+// Returns whether version satisfies every comma-separated constraint of the range.
+// Constraints compare with =, !=, >, >=, <, or <=, and omitted minor and patch versions are 0.
+//
+//	satisfiesVersion("v1.4.2", ">= 1.4, < 2") // true
+func satisfiesVersion(version, versionRange string) (bool, error) {
+	v, err := parseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	for _, constraint := range splitList(versionRange) {
+		i := strings.IndexAny(constraint, "v0123456789")
+		if i < 0 {
+			return false, fmt.Errorf(`invalid constraint "%s"`, constraint)
+		}
+		op := strings.TrimSpace(constraint[:i])
+		want, err := parseVersion(constraint[i:])
+		if err != nil {
+			return false, err
+		}
+		c := compareVersions(v, want)
+		var ok bool
+		switch op {
+		case "", "=":
+			ok = c == 0
+		case "!=":
+			ok = c != 0
+		case ">":
+			ok = c > 0
+		case ">=":
+			ok = c >= 0
+		case "<":
+			ok = c < 0
+		case "<=":
+			ok = c <= 0
+		default:
+			return false, fmt.Errorf(`invalid operator "%s"`, op)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// A semantic version, without its build metadata.
+type semver struct {
+	numbers    [3]int
+	prerelease string
+}
+
+// Parses a version like v1.4.2, 1.4, or v0.0.0-20230101000000-abcdef123456.
+func parseVersion(s string) (semver, error) {
+	v := semver{}
+	rest := strings.TrimPrefix(s, "v")
+	if i := strings.Index(rest, "+"); i >= 0 {
+		rest = rest[:i]
+	}
+	if i := strings.Index(rest, "-"); i >= 0 {
+		rest, v.prerelease = rest[:i], rest[i+1:]
+	}
+	parts := strings.Split(rest, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf(`invalid version "%s"`, s)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf(`invalid version "%s"`, s)
+		}
+		v.numbers[i] = n
+	}
+	return v, nil
+}
+
+// Returns -1, 0, or 1 if a is older than, the same as, or newer than b.
+// Pre-releases are older than their release, and compare as strings otherwise.
+func compareVersions(a, b semver) int {
+	for i := range a.numbers {
+		if a.numbers[i] != b.numbers[i] {
+			if a.numbers[i] < b.numbers[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case a.prerelease == b.prerelease:
+		return 0
+	case a.prerelease == "":
+		return 1
+	case b.prerelease == "":
+		return -1
+	case a.prerelease < b.prerelease:
+		return -1
+	}
+	return 1
+}
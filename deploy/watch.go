@@ -0,0 +1,108 @@
+package deploy
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Deploys each folder again whenever its source code changes, for a fast inner loop while developing.
+// Waits until the folder has not changed for -watch-debounce, so saving many files deploys once.
+//
+//	builder watch -include=testLambda01 -no-sign -no-update-functions
+//
+// Only watches the folders themselves, not the shared packages they import.
+func watch() error {
+	folders, err := selectFolders()
+	if err != nil {
+		return err
+	}
+	// matrix builds are deployed again together when the folder they are built from changes
+	sources := []string{}
+	for _, folder := range folders {
+		if !contains(sources, sourceFolder(folder)) {
+			sources = append(sources, sourceFolder(folder))
+		}
+	}
+	if len(sources) == 0 {
+		return errors.New("no folders found")
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	for _, folder := range sources {
+		err := filepath.WalkDir(folder, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || !entry.IsDir() {
+				return err
+			}
+			return watcher.Add(path)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	printf("Watching (%d) folders: %s.\n\n", len(sources), strings.Join(sources, ", "))
+	changed := map[string]bool{}
+	debounce := time.NewTimer(*watchDebounceFlag)
+	debounce.Stop()
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			// editors save by creating files, so new directories have to be watched too
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					err := watcher.Add(event.Name)
+					if err != nil {
+						printf("Failed to watch %s: %s.\n\n", event.Name, err.Error())
+					}
+				}
+			}
+			if !isSourceFile(event.Name) {
+				continue
+			}
+			folder, _, _ := strings.Cut(filepath.ToSlash(event.Name), "/")
+			changed[folder] = true
+			debounce.Reset(*watchDebounceFlag)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			printf("Failed to watch: %s.\n\n", err.Error())
+		case <-debounce.C:
+			names := []string{}
+			for folder := range changed {
+				names = append(names, folder)
+			}
+			sort.Strings(names)
+			changed = map[string]bool{}
+			printf("Changed (%d) folders: %s.\n\n", len(names), strings.Join(names, ", "))
+			// deploy selects the folders with the flags, like every run
+			err := commandLine.Set("include", strings.Join(names, ","))
+			if err != nil {
+				return err
+			}
+			_, err = deploy()
+			if err != nil {
+				printf("Failed to deploy: %s.\n\n", err.Error())
+			}
+			printf("Watching for changes.\n\n")
+		}
+	}
+}
+
+// Returns true if the file is part of the source code that hashSourceCode hashes.
+func isSourceFile(path string) bool {
+	name := filepath.Base(path)
+	return strings.HasSuffix(name, ".go") || strings.HasPrefix(name, "go.")
+}
@@ -0,0 +1,930 @@
+package testutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// The account of every fake ARN.
+const fakeAccount = "123456789012"
+
+// An in-memory Lambda that deploys packages from the buckets of S3.
+// Functions are active, and updates are successful, as soon as they are made, so waiters return at once.
+// The CodeSha256 of a function is the base64-encoded SHA-256 of its package, as Lambda computes it.
+type FakeLambda struct {
+	mu        sync.Mutex
+	S3        *FakeS3
+	Region    string
+	functions map[string]*fakeFunction
+	// keyed by ARN
+	codeSigningConfigs map[string]*lambdaTypes.CodeSigningConfig
+	layers             map[string][]lambdaTypes.LayerVersionsListItem
+	next               int
+}
+
+type fakeFunction struct {
+	latest lambdaTypes.FunctionConfiguration
+	// published versions, oldest first
+	versions          []lambdaTypes.FunctionConfiguration
+	aliases           map[string]*lambdaTypes.AliasConfiguration
+	tags              map[string]string
+	permissions       map[string]bool
+	codeSigningConfig string
+	// keyed by qualifier
+	provisioned map[string]int32
+	urls        map[string]*lambda.GetFunctionUrlConfigOutput
+}
+
+func NewFakeLambda(s3 *FakeS3, region string) *FakeLambda {
+	return &FakeLambda{
+		S3:                 s3,
+		Region:             region,
+		functions:          map[string]*fakeFunction{},
+		codeSigningConfigs: map[string]*lambdaTypes.CodeSigningConfig{},
+		layers:             map[string][]lambdaTypes.LayerVersionsListItem{},
+	}
+}
+
+// Returns the version the alias of the function points at, and false if either does not exist.
+func (f *FakeLambda) AliasVersion(function, alias string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fn, ok := f.functions[function]
+	if !ok || fn.aliases[alias] == nil {
+		return "", false
+	}
+	return aws.ToString(fn.aliases[alias].FunctionVersion), true
+}
+
+// Returns the published versions of the function, oldest first.
+func (f *FakeLambda) Versions(function string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	versions := []string{}
+	if fn, ok := f.functions[function]; ok {
+		for _, v := range fn.versions {
+			versions = append(versions, aws.ToString(v.Version))
+		}
+	}
+	return versions
+}
+
+func (f *FakeLambda) arn(resource string) string {
+	return fmt.Sprintf("arn:aws:lambda:%s:%s:%s", f.Region, fakeAccount, resource)
+}
+
+// Returns the name and qualifier of a function given by name, name:qualifier, or ARN.
+// The qualifier parameter, if set, takes precedence.
+func splitFunctionName(name, qualifier *string) (string, string) {
+	function := aws.ToString(name)
+	if strings.HasPrefix(function, "arn:") {
+		parts := strings.Split(function, ":")
+		if len(parts) >= 7 {
+			function = strings.Join(parts[6:], ":")
+		}
+	}
+	function, q, _ := strings.Cut(function, ":")
+	if qualifier != nil {
+		q = *qualifier
+	}
+	return function, q
+}
+
+func notFound(format string, args ...interface{}) error {
+	return &lambdaTypes.ResourceNotFoundException{Message: aws.String(fmt.Sprintf(format, args...)), Type: aws.String("User")}
+}
+
+func conflict(format string, args ...interface{}) error {
+	return &lambdaTypes.ResourceConflictException{Message: aws.String(fmt.Sprintf(format, args...)), Type: aws.String("User")}
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &lambdaTypes.InvalidParameterValueException{Message: aws.String(fmt.Sprintf(format, args...)), Type: aws.String("User")}
+}
+
+// Returns the function, or ResourceNotFoundException. Expects the lock to be held.
+func (f *FakeLambda) function(name string) (*fakeFunction, error) {
+	fn, ok := f.functions[name]
+	if !ok {
+		return nil, notFound("Function not found: %s", f.arn("function:"+name))
+	}
+	return fn, nil
+}
+
+// Returns the configuration of $LATEST, a version, or the version an alias points at. Expects the lock to be held.
+func (f *FakeLambda) configuration(name, qualifier string) (*lambdaTypes.FunctionConfiguration, error) {
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	if alias, ok := fn.aliases[qualifier]; ok {
+		qualifier = aws.ToString(alias.FunctionVersion)
+	}
+	if qualifier == "" || qualifier == "$LATEST" {
+		return &fn.latest, nil
+	}
+	for i := range fn.versions {
+		if aws.ToString(fn.versions[i].Version) == qualifier {
+			return &fn.versions[i], nil
+		}
+	}
+	return nil, notFound("Function not found: %s", f.arn("function:"+name+":"+qualifier))
+}
+
+// Returns the base64-encoded SHA-256 and size of the package in S3. Expects the lock to be held.
+func (f *FakeLambda) code(bucket, key, version *string, zipFile []byte) (string, int64, error) {
+	body := zipFile
+	if body == nil {
+		f.S3.mu.Lock()
+		o := f.S3.version(aws.ToString(bucket), aws.ToString(key), version)
+		f.S3.mu.Unlock()
+		if o == nil {
+			return "", 0, invalid("Error occurred while GetObject. S3 Error Code: NoSuchKey. S3 Error Message: The specified key does not exist.")
+		}
+		body = o.body
+	}
+	sum := sha256.Sum256(body)
+	return base64.StdEncoding.EncodeToString(sum[:]), int64(len(body)), nil
+}
+
+// Returns a new revision ID. Expects the lock to be held.
+func (f *FakeLambda) revision() *string {
+	f.next++
+	return aws.String(fmt.Sprintf("00000000-0000-0000-0000-%012d", f.next))
+}
+
+func now() *string {
+	return aws.String(time.Now().UTC().Format("2006-01-02T15:04:05.000+0000"))
+}
+
+// Copies the fields the output has in common with the configuration, e.g. into a GetFunctionConfigurationOutput.
+func copyConfiguration(configuration *lambdaTypes.FunctionConfiguration, output interface{}) {
+	b, err := json.Marshal(configuration)
+	if err != nil {
+		panic(err)
+	}
+	err = json.Unmarshal(b, output)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (f *FakeLambda) CreateFunction(ctx context.Context, params *lambda.CreateFunctionInput, optFns ...func(*lambda.Options)) (*lambda.CreateFunctionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, _ := splitFunctionName(params.FunctionName, nil)
+	if _, ok := f.functions[name]; ok {
+		return nil, conflict("Function already exist: %s", name)
+	}
+	if params.Code == nil {
+		return nil, invalid("Code is required")
+	}
+	hash, size, err := f.code(params.Code.S3Bucket, params.Code.S3Key, params.Code.S3ObjectVersion, params.Code.ZipFile)
+	if err != nil {
+		return nil, err
+	}
+	architectures := params.Architectures
+	if len(architectures) == 0 {
+		architectures = []lambdaTypes.Architecture{lambdaTypes.ArchitectureX8664}
+	}
+	fn := &fakeFunction{
+		latest: lambdaTypes.FunctionConfiguration{
+			FunctionName:     aws.String(name),
+			FunctionArn:      aws.String(f.arn("function:" + name)),
+			Architectures:    architectures,
+			CodeSha256:       aws.String(hash),
+			CodeSize:         size,
+			Description:      params.Description,
+			Handler:          params.Handler,
+			MemorySize:       params.MemorySize,
+			Role:             params.Role,
+			Runtime:          params.Runtime,
+			Timeout:          params.Timeout,
+			PackageType:      lambdaTypes.PackageTypeZip,
+			Version:          aws.String("$LATEST"),
+			State:            lambdaTypes.StateActive,
+			LastUpdateStatus: lambdaTypes.LastUpdateStatusSuccessful,
+			LastModified:     now(),
+			RevisionId:       f.revision(),
+		},
+		aliases:           map[string]*lambdaTypes.AliasConfiguration{},
+		tags:              map[string]string{},
+		permissions:       map[string]bool{},
+		codeSigningConfig: aws.ToString(params.CodeSigningConfigArn),
+		provisioned:       map[string]int32{},
+		urls:              map[string]*lambda.GetFunctionUrlConfigOutput{},
+	}
+	for key, value := range params.Tags {
+		fn.tags[key] = value
+	}
+	f.functions[name] = fn
+	output := &lambda.CreateFunctionOutput{}
+	copyConfiguration(&fn.latest, output)
+	if params.Publish {
+		fn.versions = append(fn.versions, f.publish(fn, nil))
+	}
+	return output, nil
+}
+
+func (f *FakeLambda) DeleteFunction(ctx context.Context, params *lambda.DeleteFunctionInput, optFns ...func(*lambda.Options)) (*lambda.DeleteFunctionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, qualifier := splitFunctionName(params.FunctionName, params.Qualifier)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	if qualifier == "" || qualifier == "$LATEST" {
+		delete(f.functions, name)
+		return &lambda.DeleteFunctionOutput{}, nil
+	}
+	for _, alias := range fn.aliases {
+		if aws.ToString(alias.FunctionVersion) == qualifier {
+			return nil, conflict("Unable to delete version because the following aliases reference it: [%s]", aws.ToString(alias.Name))
+		}
+	}
+	if _, ok := fn.provisioned[qualifier]; ok {
+		return nil, conflict("Unable to delete version %s because it has provisioned concurrency", qualifier)
+	}
+	for i, v := range fn.versions {
+		if aws.ToString(v.Version) == qualifier {
+			fn.versions = append(fn.versions[:i:i], fn.versions[i+1:]...)
+			return &lambda.DeleteFunctionOutput{}, nil
+		}
+	}
+	return nil, notFound("Function not found: %s", f.arn("function:"+name+":"+qualifier))
+}
+
+func (f *FakeLambda) GetFunction(ctx context.Context, params *lambda.GetFunctionInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, qualifier := splitFunctionName(params.FunctionName, params.Qualifier)
+	configuration, err := f.configuration(name, qualifier)
+	if err != nil {
+		return nil, err
+	}
+	c := *configuration
+	tags := map[string]string{}
+	for key, value := range f.functions[name].tags {
+		tags[key] = value
+	}
+	return &lambda.GetFunctionOutput{
+		Configuration: &c,
+		Code:          &lambdaTypes.FunctionCodeLocation{RepositoryType: aws.String("S3")},
+		Tags:          tags,
+	}, nil
+}
+
+func (f *FakeLambda) GetFunctionConfiguration(ctx context.Context, params *lambda.GetFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionConfigurationOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, qualifier := splitFunctionName(params.FunctionName, params.Qualifier)
+	configuration, err := f.configuration(name, qualifier)
+	if err != nil {
+		return nil, err
+	}
+	output := &lambda.GetFunctionConfigurationOutput{}
+	copyConfiguration(configuration, output)
+	return output, nil
+}
+
+// Updates $LATEST to the package in S3. Publishes a version with Publish.
+func (f *FakeLambda) UpdateFunctionCode(ctx context.Context, params *lambda.UpdateFunctionCodeInput, optFns ...func(*lambda.Options)) (*lambda.UpdateFunctionCodeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, _ := splitFunctionName(params.FunctionName, nil)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	hash, size, err := f.code(params.S3Bucket, params.S3Key, params.S3ObjectVersion, params.ZipFile)
+	if err != nil {
+		return nil, err
+	}
+	fn.latest.CodeSha256 = aws.String(hash)
+	fn.latest.CodeSize = size
+	if len(params.Architectures) != 0 {
+		fn.latest.Architectures = params.Architectures
+	}
+	fn.latest.LastModified = now()
+	fn.latest.RevisionId = f.revision()
+	output := &lambda.UpdateFunctionCodeOutput{}
+	copyConfiguration(&fn.latest, output)
+	if params.Publish {
+		v := f.publish(fn, nil)
+		fn.versions = append(fn.versions, v)
+		copyConfiguration(&v, output)
+	}
+	return output, nil
+}
+
+func (f *FakeLambda) UpdateFunctionConfiguration(ctx context.Context, params *lambda.UpdateFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.UpdateFunctionConfigurationOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, _ := splitFunctionName(params.FunctionName, nil)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	c := &fn.latest
+	if params.Description != nil {
+		c.Description = params.Description
+	}
+	if params.Handler != nil {
+		c.Handler = params.Handler
+	}
+	if params.MemorySize != nil {
+		c.MemorySize = params.MemorySize
+	}
+	if params.Timeout != nil {
+		c.Timeout = params.Timeout
+	}
+	if params.Role != nil {
+		c.Role = params.Role
+	}
+	if params.Runtime != "" {
+		c.Runtime = params.Runtime
+	}
+	if params.Environment != nil {
+		c.Environment = &lambdaTypes.EnvironmentResponse{Variables: params.Environment.Variables}
+	}
+	if params.EphemeralStorage != nil {
+		c.EphemeralStorage = params.EphemeralStorage
+	}
+	if params.TracingConfig != nil {
+		c.TracingConfig = &lambdaTypes.TracingConfigResponse{Mode: params.TracingConfig.Mode}
+	}
+	if params.SnapStart != nil {
+		c.SnapStart = &lambdaTypes.SnapStartResponse{ApplyOn: params.SnapStart.ApplyOn, OptimizationStatus: lambdaTypes.SnapStartOptimizationStatusOff}
+	}
+	if params.Layers != nil {
+		c.Layers = []lambdaTypes.Layer{}
+		for _, arn := range params.Layers {
+			c.Layers = append(c.Layers, lambdaTypes.Layer{Arn: aws.String(arn)})
+		}
+	}
+	c.LastModified = now()
+	c.RevisionId = f.revision()
+	output := &lambda.UpdateFunctionConfigurationOutput{}
+	copyConfiguration(c, output)
+	return output, nil
+}
+
+// Returns a new version of $LATEST. Expects the lock to be held.
+func (f *FakeLambda) publish(fn *fakeFunction, description *string) lambdaTypes.FunctionConfiguration {
+	number := 1
+	if len(fn.versions) != 0 {
+		last, _ := strconv.Atoi(aws.ToString(fn.versions[len(fn.versions)-1].Version))
+		number = last + 1
+	}
+	v := fn.latest
+	v.Version = aws.String(strconv.Itoa(number))
+	v.FunctionArn = aws.String(aws.ToString(fn.latest.FunctionArn) + ":" + strconv.Itoa(number))
+	if description != nil {
+		v.Description = description
+	}
+	v.RevisionId = f.revision()
+	return v
+}
+
+// Publishes $LATEST, or returns the latest version if $LATEST has not changed since, as Lambda does.
+func (f *FakeLambda) PublishVersion(ctx context.Context, params *lambda.PublishVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishVersionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, _ := splitFunctionName(params.FunctionName, nil)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	if params.CodeSha256 != nil && aws.ToString(params.CodeSha256) != aws.ToString(fn.latest.CodeSha256) {
+		return nil, invalid("CodeSHA256 (%s) is different from current CodeSHA256 in $LATEST (%s)", aws.ToString(params.CodeSha256), aws.ToString(fn.latest.CodeSha256))
+	}
+	output := &lambda.PublishVersionOutput{}
+	if n := len(fn.versions); n != 0 && aws.ToString(fn.versions[n-1].CodeSha256) == aws.ToString(fn.latest.CodeSha256) && aws.ToString(fn.versions[n-1].LastModified) == aws.ToString(fn.latest.LastModified) {
+		copyConfiguration(&fn.versions[n-1], output)
+		return output, nil
+	}
+	v := f.publish(fn, params.Description)
+	fn.versions = append(fn.versions, v)
+	copyConfiguration(&v, output)
+	return output, nil
+}
+
+// Lists $LATEST and every published version in one page.
+func (f *FakeLambda) ListVersionsByFunction(ctx context.Context, params *lambda.ListVersionsByFunctionInput, optFns ...func(*lambda.Options)) (*lambda.ListVersionsByFunctionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, _ := splitFunctionName(params.FunctionName, nil)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	versions := append([]lambdaTypes.FunctionConfiguration{fn.latest}, fn.versions...)
+	return &lambda.ListVersionsByFunctionOutput{Versions: versions}, nil
+}
+
+func (f *FakeLambda) AddPermission(ctx context.Context, params *lambda.AddPermissionInput, optFns ...func(*lambda.Options)) (*lambda.AddPermissionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, qualifier := splitFunctionName(params.FunctionName, params.Qualifier)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	key := qualifier + "/" + aws.ToString(params.StatementId)
+	if fn.permissions[key] {
+		return nil, conflict("The statement id (%s) provided already exists. Please provide a new statement id, or remove the existing statement.", aws.ToString(params.StatementId))
+	}
+	fn.permissions[key] = true
+	statement, err := json.Marshal(map[string]interface{}{
+		"Sid":       aws.ToString(params.StatementId),
+		"Effect":    "Allow",
+		"Principal": aws.ToString(params.Principal),
+		"Action":    aws.ToString(params.Action),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &lambda.AddPermissionOutput{Statement: aws.String(string(statement))}, nil
+}
+
+// Returns the ARN of the function or its version or alias.
+func (f *FakeLambda) functionARN(name, qualifier string) string {
+	if qualifier == "" {
+		return f.arn("function:" + name)
+	}
+	return f.arn("function:" + name + ":" + qualifier)
+}
+
+// Returns the function of an ARN, e.g. the resource of TagResource. Expects the lock to be held.
+func (f *FakeLambda) resource(arn *string) (*fakeFunction, error) {
+	name, _ := splitFunctionName(arn, nil)
+	return f.function(name)
+}
+
+func (f *FakeLambda) TagResource(ctx context.Context, params *lambda.TagResourceInput, optFns ...func(*lambda.Options)) (*lambda.TagResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fn, err := f.resource(params.Resource)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range params.Tags {
+		fn.tags[key] = value
+	}
+	return &lambda.TagResourceOutput{}, nil
+}
+
+func (f *FakeLambda) UntagResource(ctx context.Context, params *lambda.UntagResourceInput, optFns ...func(*lambda.Options)) (*lambda.UntagResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fn, err := f.resource(params.Resource)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range params.TagKeys {
+		delete(fn.tags, key)
+	}
+	return &lambda.UntagResourceOutput{}, nil
+}
+
+// Returns the version, or InvalidParameterValueException if the function has not published it. Expects the lock to be held.
+func (f *FakeLambda) checkVersion(fn *fakeFunction, version string) error {
+	if version == "$LATEST" {
+		return nil
+	}
+	for _, v := range fn.versions {
+		if aws.ToString(v.Version) == version {
+			return nil
+		}
+	}
+	return notFound("Function not found: %s:%s", aws.ToString(fn.latest.FunctionArn), version)
+}
+
+func aliasOutput(alias *lambdaTypes.AliasConfiguration) lambdaTypes.AliasConfiguration {
+	a := *alias
+	if alias.RoutingConfig != nil {
+		weights := map[string]float64{}
+		for version, weight := range alias.RoutingConfig.AdditionalVersionWeights {
+			weights[version] = weight
+		}
+		a.RoutingConfig = &lambdaTypes.AliasRoutingConfiguration{AdditionalVersionWeights: weights}
+	}
+	return a
+}
+
+func (f *FakeLambda) CreateAlias(ctx context.Context, params *lambda.CreateAliasInput, optFns ...func(*lambda.Options)) (*lambda.CreateAliasOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, _ := splitFunctionName(params.FunctionName, nil)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	aliasName := aws.ToString(params.Name)
+	if _, ok := fn.aliases[aliasName]; ok {
+		return nil, conflict("Alias already exists: %s", f.functionARN(name, aliasName))
+	}
+	if err := f.checkVersion(fn, aws.ToString(params.FunctionVersion)); err != nil {
+		return nil, err
+	}
+	alias := &lambdaTypes.AliasConfiguration{
+		AliasArn:        aws.String(f.functionARN(name, aliasName)),
+		Name:            params.Name,
+		FunctionVersion: params.FunctionVersion,
+		Description:     params.Description,
+		RoutingConfig:   params.RoutingConfig,
+		RevisionId:      f.revision(),
+	}
+	fn.aliases[aliasName] = alias
+	a := aliasOutput(alias)
+	return &lambda.CreateAliasOutput{
+		AliasArn:        a.AliasArn,
+		Name:            a.Name,
+		FunctionVersion: a.FunctionVersion,
+		Description:     a.Description,
+		RoutingConfig:   a.RoutingConfig,
+		RevisionId:      a.RevisionId,
+	}, nil
+}
+
+func (f *FakeLambda) DeleteAlias(ctx context.Context, params *lambda.DeleteAliasInput, optFns ...func(*lambda.Options)) (*lambda.DeleteAliasOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, _ := splitFunctionName(params.FunctionName, nil)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	delete(fn.aliases, aws.ToString(params.Name))
+	delete(fn.urls, aws.ToString(params.Name))
+	delete(fn.provisioned, aws.ToString(params.Name))
+	return &lambda.DeleteAliasOutput{}, nil
+}
+
+// Returns the alias, or ResourceNotFoundException. Expects the lock to be held.
+func (f *FakeLambda) alias(functionName, aliasName *string) (*fakeFunction, *lambdaTypes.AliasConfiguration, error) {
+	name, _ := splitFunctionName(functionName, nil)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	alias, ok := fn.aliases[aws.ToString(aliasName)]
+	if !ok {
+		return nil, nil, notFound("Alias not found: %s", f.functionARN(name, aws.ToString(aliasName)))
+	}
+	return fn, alias, nil
+}
+
+func (f *FakeLambda) GetAlias(ctx context.Context, params *lambda.GetAliasInput, optFns ...func(*lambda.Options)) (*lambda.GetAliasOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, alias, err := f.alias(params.FunctionName, params.Name)
+	if err != nil {
+		return nil, err
+	}
+	a := aliasOutput(alias)
+	return &lambda.GetAliasOutput{
+		AliasArn:        a.AliasArn,
+		Name:            a.Name,
+		FunctionVersion: a.FunctionVersion,
+		Description:     a.Description,
+		RoutingConfig:   a.RoutingConfig,
+		RevisionId:      a.RevisionId,
+	}, nil
+}
+
+func (f *FakeLambda) UpdateAlias(ctx context.Context, params *lambda.UpdateAliasInput, optFns ...func(*lambda.Options)) (*lambda.UpdateAliasOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fn, alias, err := f.alias(params.FunctionName, params.Name)
+	if err != nil {
+		return nil, err
+	}
+	if params.RevisionId != nil && aws.ToString(params.RevisionId) != aws.ToString(alias.RevisionId) {
+		return nil, &lambdaTypes.PreconditionFailedException{Message: aws.String("The Revision Id provided does not match the latest Revision Id.")}
+	}
+	if params.FunctionVersion != nil {
+		if err := f.checkVersion(fn, aws.ToString(params.FunctionVersion)); err != nil {
+			return nil, err
+		}
+		alias.FunctionVersion = params.FunctionVersion
+	}
+	if params.Description != nil {
+		alias.Description = params.Description
+	}
+	// an empty routing config shifts every request to the version of the alias
+	if params.RoutingConfig != nil {
+		alias.RoutingConfig = params.RoutingConfig
+		if len(params.RoutingConfig.AdditionalVersionWeights) == 0 {
+			alias.RoutingConfig = nil
+		}
+	}
+	alias.RevisionId = f.revision()
+	a := aliasOutput(alias)
+	return &lambda.UpdateAliasOutput{
+		AliasArn:        a.AliasArn,
+		Name:            a.Name,
+		FunctionVersion: a.FunctionVersion,
+		Description:     a.Description,
+		RoutingConfig:   a.RoutingConfig,
+		RevisionId:      a.RevisionId,
+	}, nil
+}
+
+// Lists every alias in one page, in order of name.
+func (f *FakeLambda) ListAliases(ctx context.Context, params *lambda.ListAliasesInput, optFns ...func(*lambda.Options)) (*lambda.ListAliasesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, _ := splitFunctionName(params.FunctionName, nil)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	output := &lambda.ListAliasesOutput{Aliases: []lambdaTypes.AliasConfiguration{}}
+	for _, alias := range fn.aliases {
+		if params.FunctionVersion != nil && aws.ToString(alias.FunctionVersion) != aws.ToString(params.FunctionVersion) {
+			continue
+		}
+		output.Aliases = append(output.Aliases, aliasOutput(alias))
+	}
+	sort.Slice(output.Aliases, func(i, j int) bool {
+		return aws.ToString(output.Aliases[i].Name) < aws.ToString(output.Aliases[j].Name)
+	})
+	return output, nil
+}
+
+// Allocates the provisioned concurrency at once, so its status is READY.
+func (f *FakeLambda) PutProvisionedConcurrencyConfig(ctx context.Context, params *lambda.PutProvisionedConcurrencyConfigInput, optFns ...func(*lambda.Options)) (*lambda.PutProvisionedConcurrencyConfigOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, qualifier := splitFunctionName(params.FunctionName, params.Qualifier)
+	if _, err := f.configuration(name, qualifier); err != nil {
+		return nil, err
+	}
+	if qualifier == "" || qualifier == "$LATEST" {
+		return nil, invalid("Provisioned concurrency is not supported on $LATEST")
+	}
+	executions := aws.ToInt32(params.ProvisionedConcurrentExecutions)
+	f.functions[name].provisioned[qualifier] = executions
+	return &lambda.PutProvisionedConcurrencyConfigOutput{
+		AllocatedProvisionedConcurrentExecutions: aws.Int32(executions),
+		AvailableProvisionedConcurrentExecutions: aws.Int32(executions),
+		RequestedProvisionedConcurrentExecutions: aws.Int32(executions),
+		Status:                                   lambdaTypes.ProvisionedConcurrencyStatusEnumReady,
+		LastModified:                             now(),
+	}, nil
+}
+
+func (f *FakeLambda) GetProvisionedConcurrencyConfig(ctx context.Context, params *lambda.GetProvisionedConcurrencyConfigInput, optFns ...func(*lambda.Options)) (*lambda.GetProvisionedConcurrencyConfigOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, qualifier := splitFunctionName(params.FunctionName, params.Qualifier)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	executions, ok := fn.provisioned[qualifier]
+	if !ok {
+		return nil, &lambdaTypes.ProvisionedConcurrencyConfigNotFoundException{
+			Message: aws.String("No Provisioned Concurrency Config found for this function"),
+			Type:    aws.String("User"),
+		}
+	}
+	return &lambda.GetProvisionedConcurrencyConfigOutput{
+		AllocatedProvisionedConcurrentExecutions: aws.Int32(executions),
+		AvailableProvisionedConcurrentExecutions: aws.Int32(executions),
+		RequestedProvisionedConcurrentExecutions: aws.Int32(executions),
+		Status:                                   lambdaTypes.ProvisionedConcurrencyStatusEnumReady,
+	}, nil
+}
+
+func (f *FakeLambda) DeleteProvisionedConcurrencyConfig(ctx context.Context, params *lambda.DeleteProvisionedConcurrencyConfigInput, optFns ...func(*lambda.Options)) (*lambda.DeleteProvisionedConcurrencyConfigOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, qualifier := splitFunctionName(params.FunctionName, params.Qualifier)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	delete(fn.provisioned, qualifier)
+	return &lambda.DeleteProvisionedConcurrencyConfigOutput{}, nil
+}
+
+// Lists every provisioned concurrency config of the function in one page.
+func (f *FakeLambda) ListProvisionedConcurrencyConfigs(ctx context.Context, params *lambda.ListProvisionedConcurrencyConfigsInput, optFns ...func(*lambda.Options)) (*lambda.ListProvisionedConcurrencyConfigsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, _ := splitFunctionName(params.FunctionName, nil)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	qualifiers := []string{}
+	for qualifier := range fn.provisioned {
+		qualifiers = append(qualifiers, qualifier)
+	}
+	sort.Strings(qualifiers)
+	output := &lambda.ListProvisionedConcurrencyConfigsOutput{ProvisionedConcurrencyConfigs: []lambdaTypes.ProvisionedConcurrencyConfigListItem{}}
+	for _, qualifier := range qualifiers {
+		executions := fn.provisioned[qualifier]
+		output.ProvisionedConcurrencyConfigs = append(output.ProvisionedConcurrencyConfigs, lambdaTypes.ProvisionedConcurrencyConfigListItem{
+			FunctionArn:                              aws.String(f.functionARN(name, qualifier)),
+			AllocatedProvisionedConcurrentExecutions: aws.Int32(executions),
+			AvailableProvisionedConcurrentExecutions: aws.Int32(executions),
+			RequestedProvisionedConcurrentExecutions: aws.Int32(executions),
+			Status:                                   lambdaTypes.ProvisionedConcurrencyStatusEnumReady,
+		})
+	}
+	return output, nil
+}
+
+func (f *FakeLambda) CreateFunctionUrlConfig(ctx context.Context, params *lambda.CreateFunctionUrlConfigInput, optFns ...func(*lambda.Options)) (*lambda.CreateFunctionUrlConfigOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, qualifier := splitFunctionName(params.FunctionName, params.Qualifier)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := fn.urls[qualifier]; ok {
+		return nil, conflict("Failed to create function url config for [functionArn = %s]. Error message:  FunctionUrlConfig exists for this Lambda function", f.functionARN(name, qualifier))
+	}
+	f.next++
+	url := &lambda.GetFunctionUrlConfigOutput{
+		AuthType:         params.AuthType,
+		Cors:             params.Cors,
+		CreationTime:     now(),
+		LastModifiedTime: now(),
+		FunctionArn:      aws.String(f.functionARN(name, qualifier)),
+		FunctionUrl:      aws.String(fmt.Sprintf("https://%032d.lambda-url.%s.on.aws/", f.next, f.Region)),
+	}
+	fn.urls[qualifier] = url
+	return &lambda.CreateFunctionUrlConfigOutput{
+		AuthType:     url.AuthType,
+		Cors:         url.Cors,
+		CreationTime: url.CreationTime,
+		FunctionArn:  url.FunctionArn,
+		FunctionUrl:  url.FunctionUrl,
+	}, nil
+}
+
+func (f *FakeLambda) GetFunctionUrlConfig(ctx context.Context, params *lambda.GetFunctionUrlConfigInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionUrlConfigOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, qualifier := splitFunctionName(params.FunctionName, params.Qualifier)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	url, ok := fn.urls[qualifier]
+	if !ok {
+		return nil, notFound("The resource you requested does not exist.")
+	}
+	output := *url
+	return &output, nil
+}
+
+func (f *FakeLambda) DeleteFunctionUrlConfig(ctx context.Context, params *lambda.DeleteFunctionUrlConfigInput, optFns ...func(*lambda.Options)) (*lambda.DeleteFunctionUrlConfigOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, qualifier := splitFunctionName(params.FunctionName, params.Qualifier)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := fn.urls[qualifier]; !ok {
+		return nil, notFound("The resource you requested does not exist.")
+	}
+	delete(fn.urls, qualifier)
+	return &lambda.DeleteFunctionUrlConfigOutput{}, nil
+}
+
+func (f *FakeLambda) CreateCodeSigningConfig(ctx context.Context, params *lambda.CreateCodeSigningConfigInput, optFns ...func(*lambda.Options)) (*lambda.CreateCodeSigningConfigOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.next++
+	id := fmt.Sprintf("csc-%017d", f.next)
+	config := &lambdaTypes.CodeSigningConfig{
+		AllowedPublishers:    params.AllowedPublishers,
+		CodeSigningConfigArn: aws.String(f.arn("code-signing-config:" + id)),
+		CodeSigningConfigId:  aws.String(id),
+		CodeSigningPolicies:  params.CodeSigningPolicies,
+		Description:          params.Description,
+		LastModified:         now(),
+	}
+	if config.CodeSigningPolicies == nil {
+		config.CodeSigningPolicies = &lambdaTypes.CodeSigningPolicies{UntrustedArtifactOnDeployment: lambdaTypes.CodeSigningPolicyWarn}
+	}
+	f.codeSigningConfigs[aws.ToString(config.CodeSigningConfigArn)] = config
+	output := *config
+	return &lambda.CreateCodeSigningConfigOutput{CodeSigningConfig: &output}, nil
+}
+
+// Lists every code signing config in one page, in the order they were created.
+func (f *FakeLambda) ListCodeSigningConfigs(ctx context.Context, params *lambda.ListCodeSigningConfigsInput, optFns ...func(*lambda.Options)) (*lambda.ListCodeSigningConfigsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	output := &lambda.ListCodeSigningConfigsOutput{CodeSigningConfigs: []lambdaTypes.CodeSigningConfig{}}
+	for _, config := range f.codeSigningConfigs {
+		output.CodeSigningConfigs = append(output.CodeSigningConfigs, *config)
+	}
+	sort.Slice(output.CodeSigningConfigs, func(i, j int) bool {
+		return aws.ToString(output.CodeSigningConfigs[i].CodeSigningConfigId) < aws.ToString(output.CodeSigningConfigs[j].CodeSigningConfigId)
+	})
+	return output, nil
+}
+
+func (f *FakeLambda) GetFunctionCodeSigningConfig(ctx context.Context, params *lambda.GetFunctionCodeSigningConfigInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionCodeSigningConfigOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, _ := splitFunctionName(params.FunctionName, nil)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	output := &lambda.GetFunctionCodeSigningConfigOutput{FunctionName: aws.String(name)}
+	if fn.codeSigningConfig != "" {
+		output.CodeSigningConfigArn = aws.String(fn.codeSigningConfig)
+	}
+	return output, nil
+}
+
+func (f *FakeLambda) PutFunctionCodeSigningConfig(ctx context.Context, params *lambda.PutFunctionCodeSigningConfigInput, optFns ...func(*lambda.Options)) (*lambda.PutFunctionCodeSigningConfigOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, _ := splitFunctionName(params.FunctionName, nil)
+	fn, err := f.function(name)
+	if err != nil {
+		return nil, err
+	}
+	arn := aws.ToString(params.CodeSigningConfigArn)
+	if _, ok := f.codeSigningConfigs[arn]; !ok {
+		return nil, &lambdaTypes.CodeSigningConfigNotFoundException{Message: aws.String("Code signing config not found: " + arn)}
+	}
+	fn.codeSigningConfig = arn
+	return &lambda.PutFunctionCodeSigningConfigOutput{CodeSigningConfigArn: params.CodeSigningConfigArn, FunctionName: aws.String(name)}, nil
+}
+
+func (f *FakeLambda) PublishLayerVersion(ctx context.Context, params *lambda.PublishLayerVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishLayerVersionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if params.Content == nil {
+		return nil, invalid("Content is required")
+	}
+	hash, size, err := f.code(params.Content.S3Bucket, params.Content.S3Key, params.Content.S3ObjectVersion, params.Content.ZipFile)
+	if err != nil {
+		return nil, err
+	}
+	layer := aws.ToString(params.LayerName)
+	version := int64(len(f.layers[layer]) + 1)
+	layerARN := f.arn("layer:" + layer)
+	item := lambdaTypes.LayerVersionsListItem{
+		CompatibleArchitectures: params.CompatibleArchitectures,
+		CompatibleRuntimes:      params.CompatibleRuntimes,
+		CreatedDate:             now(),
+		Description:             params.Description,
+		LayerVersionArn:         aws.String(layerARN + ":" + strconv.FormatInt(version, 10)),
+		LicenseInfo:             params.LicenseInfo,
+		Version:                 version,
+	}
+	f.layers[layer] = append(f.layers[layer], item)
+	return &lambda.PublishLayerVersionOutput{
+		CompatibleArchitectures: item.CompatibleArchitectures,
+		CompatibleRuntimes:      item.CompatibleRuntimes,
+		Content:                 &lambdaTypes.LayerVersionContentOutput{CodeSha256: aws.String(hash), CodeSize: size},
+		CreatedDate:             item.CreatedDate,
+		Description:             item.Description,
+		LayerArn:                aws.String(layerARN),
+		LayerVersionArn:         item.LayerVersionArn,
+		LicenseInfo:             item.LicenseInfo,
+		Version:                 version,
+	}, nil
+}
+
+// Lists the versions of the layer, newest first, up to MaxItems.
+func (f *FakeLambda) ListLayerVersions(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	versions := f.layers[aws.ToString(params.LayerName)]
+	output := &lambda.ListLayerVersionsOutput{LayerVersions: []lambdaTypes.LayerVersionsListItem{}}
+	for i := len(versions) - 1; i >= 0; i-- {
+		if params.MaxItems != nil && len(output.LayerVersions) == int(*params.MaxItems) {
+			break
+		}
+		output.LayerVersions = append(output.LayerVersions, versions[i])
+	}
+	return output, nil
+}
@@ -0,0 +1,396 @@
+// Package testutil has in-memory fakes of the AWS clients the builder uses,
+// so the pipeline can run in tests without AWS:
+//
+//	fakeS3 := testutil.NewFakeS3("us-east-1")
+//	d, err := newFolderRun(ctx, opts, "us-east-1", fakeS3, testutil.NewFakeSigner(fakeS3), testutil.NewFakeLambda(fakeS3, "us-east-1"))
+//
+// FakeS3, FakeSigner, and FakeLambda implement the s3API, signerAPI, and lambdaAPI interfaces of package deploy.
+// They are safe to use from multiple goroutines, and return the errors of the real services where the builder checks for them.
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// An in-memory S3 with versioning enabled on every bucket.
+// Buckets are created the first time an object is put in them.
+type FakeS3 struct {
+	mu sync.Mutex
+	// the region of every bucket, for GetBucketLocation
+	Region  string
+	buckets map[string]map[string][]*fakeObject
+	uploads map[string]*fakeUpload
+	// counts version and upload IDs, so they are unique and sort in the order they were made
+	next int
+}
+
+// One version of an object, or a delete marker.
+type fakeObject struct {
+	versionID    string
+	body         []byte
+	metadata     map[string]string
+	lastModified time.Time
+	deleteMarker bool
+}
+
+type fakeUpload struct {
+	bucket   string
+	key      string
+	metadata map[string]string
+	parts    map[int32][]byte
+}
+
+func NewFakeS3(region string) *FakeS3 {
+	return &FakeS3{
+		Region:  region,
+		buckets: map[string]map[string][]*fakeObject{},
+		uploads: map[string]*fakeUpload{},
+	}
+}
+
+// Puts the object in the bucket and returns its version ID, e.g. to set up a previous deploy.
+func (f *FakeS3) Put(bucket, key string, body []byte, metadata map[string]string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.put(bucket, key, body, metadata)
+}
+
+// Returns the body of the latest version of the object, and false if there is none.
+func (f *FakeS3) Body(bucket, key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o := f.latest(bucket, key)
+	if o == nil {
+		return nil, false
+	}
+	return o.body, true
+}
+
+// Expects the lock to be held.
+func (f *FakeS3) put(bucket, key string, body []byte, metadata map[string]string) string {
+	objects, ok := f.buckets[bucket]
+	if !ok {
+		objects = map[string][]*fakeObject{}
+		f.buckets[bucket] = objects
+	}
+	o := &fakeObject{versionID: f.id(), body: body, metadata: lowerKeys(metadata), lastModified: time.Now()}
+	objects[key] = append(objects[key], o)
+	return o.versionID
+}
+
+// Returns a new version or upload ID. Expects the lock to be held.
+func (f *FakeS3) id() string {
+	f.next++
+	return fmt.Sprintf("%016d", f.next)
+}
+
+// Returns the latest version of the object, or nil if there is none or it was deleted. Expects the lock to be held.
+func (f *FakeS3) latest(bucket, key string) *fakeObject {
+	versions := f.buckets[bucket][key]
+	if len(versions) == 0 || versions[len(versions)-1].deleteMarker {
+		return nil
+	}
+	return versions[len(versions)-1]
+}
+
+// Returns the version of the object, or the latest version if versionID is nil. Expects the lock to be held.
+func (f *FakeS3) version(bucket, key string, versionID *string) *fakeObject {
+	if versionID == nil {
+		return f.latest(bucket, key)
+	}
+	for _, o := range f.buckets[bucket][key] {
+		if o.versionID == *versionID && !o.deleteMarker {
+			return o
+		}
+	}
+	return nil
+}
+
+// S3 returns metadata keys in lower case, whatever case they were put in.
+func lowerKeys(metadata map[string]string) map[string]string {
+	lower := map[string]string{}
+	for key, value := range metadata {
+		lower[strings.ToLower(key)] = value
+	}
+	return lower
+}
+
+func etag(body []byte) *string {
+	sum := md5.Sum(body)
+	return aws.String(`"` + hex.EncodeToString(sum[:]) + `"`)
+}
+
+func (f *FakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, err := readBody(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	versionID := f.put(aws.ToString(params.Bucket), aws.ToString(params.Key), body, params.Metadata)
+	return &s3.PutObjectOutput{ETag: etag(body), VersionId: aws.String(versionID)}, nil
+}
+
+func readBody(r io.Reader) ([]byte, error) {
+	if r == nil {
+		return []byte{}, nil
+	}
+	return io.ReadAll(r)
+}
+
+func (f *FakeS3) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	uploadID := f.id()
+	f.uploads[uploadID] = &fakeUpload{
+		bucket:   aws.ToString(params.Bucket),
+		key:      aws.ToString(params.Key),
+		metadata: params.Metadata,
+		parts:    map[int32][]byte{},
+	}
+	return &s3.CreateMultipartUploadOutput{Bucket: params.Bucket, Key: params.Key, UploadId: aws.String(uploadID)}, nil
+}
+
+func (f *FakeS3) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	body, err := readBody(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	upload, ok := f.uploads[aws.ToString(params.UploadId)]
+	if !ok {
+		return nil, &s3Types.NoSuchUpload{Message: params.UploadId}
+	}
+	upload.parts[params.PartNumber] = body
+	return &s3.UploadPartOutput{ETag: etag(body)}, nil
+}
+
+func (f *FakeS3) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	upload, ok := f.uploads[aws.ToString(params.UploadId)]
+	if !ok {
+		return nil, &s3Types.NoSuchUpload{Message: params.UploadId}
+	}
+	delete(f.uploads, aws.ToString(params.UploadId))
+	numbers := []int{}
+	for number := range upload.parts {
+		numbers = append(numbers, int(number))
+	}
+	sort.Ints(numbers)
+	body := []byte{}
+	for _, number := range numbers {
+		body = append(body, upload.parts[int32(number)]...)
+	}
+	versionID := f.put(upload.bucket, upload.key, body, upload.metadata)
+	return &s3.CompleteMultipartUploadOutput{
+		Bucket:    params.Bucket,
+		Key:       params.Key,
+		ETag:      etag(body),
+		VersionId: aws.String(versionID),
+	}, nil
+}
+
+func (f *FakeS3) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.uploads, aws.ToString(params.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func (f *FakeS3) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o := f.version(aws.ToString(params.Bucket), aws.ToString(params.Key), params.VersionId)
+	if o == nil {
+		return nil, &s3Types.NotFound{}
+	}
+	return &s3.HeadObjectOutput{
+		ContentLength: int64(len(o.body)),
+		ETag:          etag(o.body),
+		LastModified:  aws.Time(o.lastModified),
+		Metadata:      o.metadata,
+		VersionId:     aws.String(o.versionID),
+	}, nil
+}
+
+func (f *FakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o := f.version(aws.ToString(params.Bucket), aws.ToString(params.Key), params.VersionId)
+	if o == nil {
+		return nil, &s3Types.NoSuchKey{Message: params.Key}
+	}
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(bytes.NewReader(o.body)),
+		ContentLength: int64(len(o.body)),
+		ETag:          etag(o.body),
+		LastModified:  aws.Time(o.lastModified),
+		Metadata:      o.metadata,
+		VersionId:     aws.String(o.versionID),
+	}, nil
+}
+
+// Copies bucket/key or bucket/key?versionId=id, replacing the metadata with MetadataDirective REPLACE.
+func (f *FakeS3) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	source, query, _ := strings.Cut(aws.ToString(params.CopySource), "?")
+	source, err := url.PathUnescape(source)
+	if err != nil {
+		return nil, err
+	}
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid copy source %s", aws.ToString(params.CopySource))
+	}
+	var versionID *string
+	if values, err := url.ParseQuery(query); err == nil && values.Get("versionId") != "" {
+		versionID = aws.String(values.Get("versionId"))
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o := f.version(bucket, key, versionID)
+	if o == nil {
+		return nil, &s3Types.NoSuchKey{Message: aws.String(key)}
+	}
+	metadata := o.metadata
+	if params.MetadataDirective == s3Types.MetadataDirectiveReplace {
+		metadata = params.Metadata
+	}
+	newVersionID := f.put(aws.ToString(params.Bucket), aws.ToString(params.Key), o.body, metadata)
+	return &s3.CopyObjectOutput{
+		CopyObjectResult:    &s3Types.CopyObjectResult{ETag: etag(o.body), LastModified: aws.Time(time.Now())},
+		CopySourceVersionId: aws.String(o.versionID),
+		VersionId:           aws.String(newVersionID),
+	}, nil
+}
+
+// Deletes the version of the object, or puts a delete marker on it without a version ID.
+func (f *FakeS3) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bucket, key := aws.ToString(params.Bucket), aws.ToString(params.Key)
+	objects, ok := f.buckets[bucket]
+	if !ok {
+		return &s3.DeleteObjectOutput{}, nil
+	}
+	if params.VersionId == nil {
+		marker := &fakeObject{versionID: f.id(), lastModified: time.Now(), deleteMarker: true}
+		objects[key] = append(objects[key], marker)
+		return &s3.DeleteObjectOutput{DeleteMarker: true, VersionId: aws.String(marker.versionID)}, nil
+	}
+	versions := objects[key]
+	for i, o := range versions {
+		if o.versionID == *params.VersionId {
+			objects[key] = append(versions[:i:i], versions[i+1:]...)
+			return &s3.DeleteObjectOutput{DeleteMarker: o.deleteMarker, VersionId: params.VersionId}, nil
+		}
+	}
+	return &s3.DeleteObjectOutput{VersionId: params.VersionId}, nil
+}
+
+func (f *FakeS3) GetBucketLocation(ctx context.Context, params *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error) {
+	// us-east-1 has no location constraint
+	if f.Region == "us-east-1" {
+		return &s3.GetBucketLocationOutput{}, nil
+	}
+	return &s3.GetBucketLocationOutput{LocationConstraint: s3Types.BucketLocationConstraint(f.Region)}, nil
+}
+
+func (f *FakeS3) GetBucketVersioning(ctx context.Context, params *s3.GetBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error) {
+	return &s3.GetBucketVersioningOutput{Status: s3Types.BucketVersioningStatusEnabled}, nil
+}
+
+// Returns the keys of the bucket under the prefix, in order. Expects the lock to be held.
+func (f *FakeS3) keys(bucket, prefix string) []string {
+	keys := []string{}
+	for key := range f.buckets[bucket] {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Lists every object under the prefix in one page.
+func (f *FakeS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bucket := aws.ToString(params.Bucket)
+	output := &s3.ListObjectsV2Output{Name: params.Bucket, Prefix: params.Prefix}
+	for _, key := range f.keys(bucket, aws.ToString(params.Prefix)) {
+		o := f.latest(bucket, key)
+		if o == nil {
+			continue
+		}
+		output.Contents = append(output.Contents, s3Types.Object{
+			Key:          aws.String(key),
+			ETag:         etag(o.body),
+			LastModified: aws.Time(o.lastModified),
+			Size:         int64(len(o.body)),
+		})
+	}
+	output.KeyCount = int32(len(output.Contents))
+	return output, nil
+}
+
+// Lists every version and delete marker under the prefix in one page, newest first for each key.
+func (f *FakeS3) ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bucket := aws.ToString(params.Bucket)
+	output := &s3.ListObjectVersionsOutput{Name: params.Bucket, Prefix: params.Prefix}
+	for _, key := range f.keys(bucket, aws.ToString(params.Prefix)) {
+		versions := f.buckets[bucket][key]
+		for i := len(versions) - 1; i >= 0; i-- {
+			o := versions[i]
+			latest := i == len(versions)-1
+			if o.deleteMarker {
+				output.DeleteMarkers = append(output.DeleteMarkers, s3Types.DeleteMarkerEntry{
+					IsLatest:     latest,
+					Key:          aws.String(key),
+					LastModified: aws.Time(o.lastModified),
+					VersionId:    aws.String(o.versionID),
+				})
+				continue
+			}
+			output.Versions = append(output.Versions, s3Types.ObjectVersion{
+				ETag:         etag(o.body),
+				IsLatest:     latest,
+				Key:          aws.String(key),
+				LastModified: aws.Time(o.lastModified),
+				Size:         int64(len(o.body)),
+				VersionId:    aws.String(o.versionID),
+			})
+		}
+	}
+	return output, nil
+}
+
+// Returns how many versions the object has, not counting delete markers, e.g. to check what builder clean kept.
+func (f *FakeS3) VersionCount(bucket, key string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := 0
+	for _, o := range f.buckets[bucket][key] {
+		if !o.deleteMarker {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,129 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/signer"
+	signerTypes "github.com/aws/aws-sdk-go-v2/service/signer/types"
+)
+
+// The platform of every fake signing profile.
+const fakeSigningPlatform = "AWSLambda-SHA384-ECDSA"
+
+// An in-memory AWS Signer that signs packages in the buckets of S3.
+// Every profile exists and is active, unless it is in RevokedProfiles.
+// Signing jobs succeed at once, copying the source package to the destination prefix as <job id>.zip,
+// with the profile in its metadata.
+type FakeSigner struct {
+	mu sync.Mutex
+	S3 *FakeS3
+	// profiles to report as revoked
+	RevokedProfiles map[string]bool
+	jobs            map[string]*signer.DescribeSigningJobOutput
+	next            int
+}
+
+func NewFakeSigner(s3 *FakeS3) *FakeSigner {
+	return &FakeSigner{S3: s3, RevokedProfiles: map[string]bool{}, jobs: map[string]*signer.DescribeSigningJobOutput{}}
+}
+
+// Returns how many signing jobs were started, e.g. to check that packages that are up to date are not signed again.
+func (f *FakeSigner) JobCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.jobs)
+}
+
+func (f *FakeSigner) StartSigningJob(ctx context.Context, params *signer.StartSigningJobInput, optFns ...func(*signer.Options)) (*signer.StartSigningJobOutput, error) {
+	if params.Source == nil || params.Source.S3 == nil || params.Destination == nil || params.Destination.S3 == nil {
+		return nil, &signerTypes.ValidationException{Message: aws.String("source and destination must be in S3")}
+	}
+	profile := aws.ToString(params.ProfileName)
+	if f.RevokedProfiles[profile] {
+		return nil, &signerTypes.ValidationException{Message: aws.String("signing profile " + profile + " is revoked")}
+	}
+	source := params.Source.S3
+	destination := params.Destination.S3
+	f.S3.mu.Lock()
+	o := f.S3.version(aws.ToString(source.BucketName), aws.ToString(source.Key), source.Version)
+	f.S3.mu.Unlock()
+	if o == nil {
+		return nil, &signerTypes.ResourceNotFoundException{Message: aws.String("source object not found: " + aws.ToString(source.Key))}
+	}
+
+	f.mu.Lock()
+	f.next++
+	jobID := fmt.Sprintf("00000000-0000-0000-0000-%012d", f.next)
+	f.mu.Unlock()
+	key := aws.ToString(destination.Prefix) + jobID + ".zip"
+	f.S3.Put(aws.ToString(destination.BucketName), key, o.body, map[string]string{"signing-profile": profile})
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	f.jobs[jobID] = &signer.DescribeSigningJobOutput{
+		JobId:          aws.String(jobID),
+		ProfileName:    aws.String(profile),
+		ProfileVersion: aws.String(fakeProfileVersion),
+		PlatformId:     aws.String(fakeSigningPlatform),
+		Status:         signerTypes.SigningStatusSucceeded,
+		CreatedAt:      aws.Time(now),
+		CompletedAt:    aws.Time(now),
+		Source:         params.Source,
+		SignedObject: &signerTypes.SignedObject{S3: &signerTypes.S3SignedObject{
+			BucketName: destination.BucketName,
+			Key:        aws.String(key),
+		}},
+	}
+	return &signer.StartSigningJobOutput{JobId: aws.String(jobID), JobOwner: aws.String(fakeAccount)}, nil
+}
+
+func (f *FakeSigner) DescribeSigningJob(ctx context.Context, params *signer.DescribeSigningJobInput, optFns ...func(*signer.Options)) (*signer.DescribeSigningJobOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	job, ok := f.jobs[aws.ToString(params.JobId)]
+	if !ok {
+		return nil, &signerTypes.ResourceNotFoundException{Message: aws.String("signing job not found: " + aws.ToString(params.JobId))}
+	}
+	output := *job
+	return &output, nil
+}
+
+// The version of every fake signing profile.
+const fakeProfileVersion = "a1b2c3d4e5"
+
+func (f *FakeSigner) GetSigningProfile(ctx context.Context, params *signer.GetSigningProfileInput, optFns ...func(*signer.Options)) (*signer.GetSigningProfileOutput, error) {
+	profile := aws.ToString(params.ProfileName)
+	if profile == "" || strings.Contains(profile, "/") {
+		return nil, &signerTypes.ValidationException{Message: aws.String("invalid signing profile name: " + profile)}
+	}
+	status := signerTypes.SigningProfileStatusActive
+	if f.RevokedProfiles[profile] {
+		status = signerTypes.SigningProfileStatusRevoked
+	}
+	arn := fmt.Sprintf("arn:aws:signer:%s:%s:/signing-profiles/%s", f.S3.Region, fakeAccount, profile)
+	return &signer.GetSigningProfileOutput{
+		Arn:                 aws.String(arn),
+		PlatformId:          aws.String(fakeSigningPlatform),
+		PlatformDisplayName: aws.String("AWS Lambda"),
+		ProfileName:         aws.String(profile),
+		ProfileVersion:      aws.String(fakeProfileVersion),
+		ProfileVersionArn:   aws.String(arn + "/" + fakeProfileVersion),
+		Status:              status,
+	}, nil
+}
+
+// Lists the Lambda platform, so the region is taken to have AWS Signer.
+func (f *FakeSigner) ListSigningPlatforms(ctx context.Context, params *signer.ListSigningPlatformsInput, optFns ...func(*signer.Options)) (*signer.ListSigningPlatformsOutput, error) {
+	return &signer.ListSigningPlatformsOutput{Platforms: []signerTypes.SigningPlatform{{
+		Category:    signerTypes.CategoryAWSIoT,
+		DisplayName: aws.String("AWS Lambda"),
+		PlatformId:  aws.String(fakeSigningPlatform),
+		Target:      aws.String("AWSLambda"),
+	}}}, nil
+}